@@ -5,96 +5,549 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"go-to-do-app/to-do-lib/apiclient"
+	"go-to-do-app/to-do-lib/config"
+	"go-to-do-app/to-do-lib/datastores"
+	todoerrors "go-to-do-app/to-do-lib/errors"
 	"go-to-do-app/to-do-lib/logging"
+	"go-to-do-app/to-do-lib/models"
 )
 
-var (
-	post       = flag.Bool("post", false, "Add new Todo")
-	put        = flag.Bool("put", false, "updateTodo")
-	get        = flag.Bool("get", false, "Get existing Todo")
-	id         = flag.String("id", "", "UUID of ToDo item")
-	userId     = flag.String("user-id", "", "UUID representing user id")
-	title      = flag.String("title", "", "Title of ToDo item")
-	priority   = flag.String("priority", "", "Priority of ToDo item")
-	complete   = flag.Bool("complete", false, "Completion status of ToDo item")
-	version    = flag.String("version", "", "version of the api to use")
-	cliactions = []CliAction{
-		{flag: post, do: cliPost},
-		{flag: put, do: cliPut},
-		{flag: get, do: cliGet},
+// options are the flags of every command. Item flags are only defined for
+// the commands that take them.
+type options struct {
+	profileName string
+	profile     profile
+	output      string
+	title       string
+	descr       string
+	priority    string
+	complete    bool
+	tags        string
+	due         string
+	literal     bool
+	sort        string
+	refresh     time.Duration
+	offline     bool
+}
+
+// the flags setting the fields of an item
+const (
+	flagTitle       = "title"
+	flagDescription = "description"
+	flagPriority    = "priority"
+	flagComplete    = "complete"
+	flagTags        = "tags"
+	flagDue         = "due"
+)
+
+var itemFlags = []string{flagTitle, flagDescription, flagPriority, flagComplete, flagTags, flagDue}
+
+type command struct {
+	name    string
+	args    string
+	summary string
+	// itemFlags are the item fields the command accepts as flags
+	itemFlags []string
+	// nargs is the number of positional arguments required, -1 for any
+	nargs int
+	// local commands do not call the server
+	local bool
+	// flags defines the flags of the command other than item flags
+	flags func(fs *flag.FlagSet, opts *options)
+	run   func(ctx context.Context, env *cmdEnv) error
+}
+
+// cmdEnv is what a command runs with.
+type cmdEnv struct {
+	opts   *options
+	fs     *flag.FlagSet
+	args   []string
+	client apiclient.APIClient
+	// cache is the local copy of the server, nil if it could not be opened
+	cache *localCache
+	// offline is set once the cache is used in place of the server
+	offline bool
+	out     io.Writer
+	errOut  io.Writer
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{name: "add", args: "[text]", summary: "Add a new item, reading its priority, tags & due date from the text (v2)", itemFlags: itemFlags, nargs: -1, flags: addFlags, run: cliAdd},
+		{name: "get", args: "<id>", summary: "Show an item", nargs: 1, run: cliGet},
+		{name: "update", args: "<id>", summary: "Change the fields of an item given as flags", itemFlags: itemFlags, nargs: 1, run: cliUpdate},
+		{name: "done", args: "<id>", summary: "Mark an item complete", nargs: 1, run: cliDone},
+		{name: "delete", args: "<id>", summary: "Move an item to the trash", nargs: 1, run: cliDelete},
+		{name: "list", summary: "List your items (v2)", nargs: 0, flags: listFlags, run: cliList},
+		{name: "search", args: "<words>...", summary: "Find items by the words of their title or description (v2)", nargs: -1, run: cliSearch},
+		{name: "history", args: "<id>", summary: "Show the changes made to an item (v2)", nargs: 1, run: cliHistory},
+		{name: "sync", summary: "Push the changes made offline to the server and pull its items (v2)", nargs: 0, run: cliSync},
+		{name: "tui", summary: "Browse and edit your items in a full-screen terminal interface (v2)", nargs: 0, flags: tuiFlags, run: cliTUI},
+		{name: "config", args: "set <setting> <value> | get [setting]", summary: "Set or show the settings of a profile", nargs: -1, local: true, run: cliConfig},
+		{name: "completion", args: "<bash|zsh|fish>", summary: "Print a shell completion script", nargs: 1, local: true, run: cliCompletion},
 	}
+}
+
+// Exit codes, so scripts can tell failures apart.
+const (
+	exitOK = iota
+	// exitError is an unexpected failure, including server errors
+	exitError
+	// exitUsage is a bad command line
+	exitUsage
+	// exitNotFound is an item that does not exist
+	exitNotFound
+	// exitRejected is a request the server refused, such as an invalid item
+	exitRejected
+	// exitUnavailable is a server that could not be reached
+	exitUnavailable
+	// exitConflict is a change made to an item that has changed since
+	exitConflict
 )
 
-type CliAction struct {
-	flag *bool
-	do   func(todoflags map[string]string, client apiclient.APIClient, ctx context.Context)
+// usageError reports a bad command line.
+type usageError struct {
+	msg string
 }
 
-func cliPost(todoflags map[string]string, client apiclient.APIClient, ctx context.Context) {
-	item, err := client.Req(ctx, "POST", todoflags)
-	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+func usagef(format string, a ...any) error {
+	return &usageError{msg: fmt.Sprintf(format, a...)}
+}
+
+func exitCode(err error) int {
+	var (
+		usage      *usageError
+		validation *todoerrors.ValidationError
+		notFound   *todoerrors.NotFoundError
+		conflict   *todoerrors.ConflictError
+		problem    *todoerrors.Problem
+		urlErr     *url.Error
+	)
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &usage), errors.As(err, &validation):
+		return exitUsage
+	case errors.As(err, &notFound):
+		return exitNotFound
+	case errors.As(err, &conflict):
+		return exitConflict
+	case errors.As(err, &problem):
+		switch problem.Status {
+		case http.StatusNotFound:
+			return exitNotFound
+		case http.StatusConflict:
+			return exitConflict
+		}
+		if problem.Status < http.StatusInternalServerError {
+			return exitRejected
+		}
+	case errors.As(err, &urlErr):
+		return exitUnavailable
+	}
+	return exitError
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// newFlagSet defines the flags of cmd, storing them in opts. The profile
+// settings given as flags are returned, to be applied over the config file.
+func newFlagSet(cmd command, opts *options, stderr io.Writer) (*flag.FlagSet, *config.Flags) {
+	fs := flag.NewFlagSet("todo "+cmd.name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&opts.profileName, "profile", "", fmt.Sprintf("profile of the config file to use (env %s)", profileEnv))
+	opts.profile = defaultProfile()
+	// the flags are taken from a struct with valid tags, so cannot fail
+	profileFlags, _ := config.NewFlags(fs, &opts.profile)
+	fs.StringVar(&opts.output, "output", outputTable, "output format (table, json, yaml)")
+	if !cmd.local {
+		fs.BoolVar(&opts.offline, "offline", false, "use the local copy of the server without trying to reach it, queueing changes for todo sync")
+	}
+	for _, name := range cmd.itemFlags {
+		switch name {
+		case flagTitle:
+			fs.StringVar(&opts.title, flagTitle, "", "title of the item")
+		case flagDescription:
+			fs.StringVar(&opts.descr, flagDescription, "", "Markdown description of the item (v2)")
+		case flagPriority:
+			fs.StringVar(&opts.priority, flagPriority, "", "priority of the item, by name or rank, one of the priorities setting (Low, Medium, High by default)")
+		case flagComplete:
+			fs.BoolVar(&opts.complete, flagComplete, false, "completion status of the item")
+		case flagTags:
+			fs.StringVar(&opts.tags, flagTags, "", "comma separated tags of the item (v2)")
+		case flagDue:
+			fs.StringVar(&opts.due, flagDue, "", "day the item is due, such as tomorrow, friday, in 3 days or 2006-01-02 (v2)")
+		}
+	}
+	if cmd.flags != nil {
+		cmd.flags(fs, opts)
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: todo %s [flags] %s\n\n%s\n\nFlags:\n", cmd.name, cmd.args, cmd.summary)
+		fs.PrintDefaults()
+	}
+	return fs, profileFlags
+}
+
+// parseArgs parses flags given before, after or between the positional
+// arguments, returning the positional arguments.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func newClient(p profile) (apiclient.APIClient, error) {
+	baseURL := p.URL
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+	client := apiclient.NewAPIClient(baseURL)
+	if strings.HasPrefix(baseURL, "https://") {
+		var err error
+		client, err = apiclient.NewTLSAPIClient(baseURL, apiclient.TLSOptions{
+			CAFile:   p.CACert,
+			CertFile: p.ClientCert,
+			KeyFile:  p.ClientKey,
+		})
+		if err != nil {
+			return apiclient.APIClient{}, err
+		}
+	}
+	client.Token = p.Token
+	client.UserId = p.UserId
+	return client, nil
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: todo <command> [flags] [args]\n\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-11s %s\n", cmd.name, cmd.summary)
 	}
-	fmt.Println("POST success! API response:\n", item)
+	fmt.Fprintln(w, "\nRun 'todo <command> --help' for the flags of a command.")
 }
 
-func cliPut(todoflags map[string]string, client apiclient.APIClient, ctx context.Context) {
-	item, err := client.Req(ctx, "PUT", todoflags)
+// cli runs the command line args, returning the exit code.
+func cli(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return exitUsage
+	}
+	if args[0] == "help" || args[0] == "--help" || args[0] == "-h" {
+		usage(stdout)
+		return exitOK
+	}
+	cmd, ok := findCommand(args[0])
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n\n", args[0])
+		usage(stderr)
+		return exitUsage
+	}
+
+	opts := &options{}
+	fs, profileFlags := newFlagSet(cmd, opts, stderr)
+	positional, err := parseArgs(fs, args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return exitUsage
+	}
+	if err := loadProfile(opts, profileFlags, cmd.name != "config"); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitCode(err)
+	}
+	if err := checkArgs(cmd, opts, positional); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	env := &cmdEnv{opts: opts, fs: fs, args: positional, out: stdout, errOut: stderr}
+	if !cmd.local {
+		if env.client, err = newClient(opts.profile); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitUsage
+		}
+		if env.cache, err = openCache(opts.profileName); err != nil {
+			if opts.offline {
+				fmt.Fprintln(stderr, "Error: unable to open the local copy:", err)
+				return exitError
+			}
+			fmt.Fprintln(stderr, "Warning: unable to open the local copy, offline use is unavailable:", err)
+		}
 	}
-	fmt.Println("PUT success! API response:\n", item)
+	if env.cache != nil {
+		defer env.cache.close()
+	}
+	env.offline = opts.offline
+
+	ctx := logging.AddTraceID(context.Background())
+	err = cmd.run(ctx, env)
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+	}
+	if env.cache != nil && !env.offline && cmd.name != "sync" && len(env.cache.queue) > 0 {
+		fmt.Fprintf(stderr, "%d changes made offline are waiting, run 'todo sync' to push them.\n", len(env.cache.queue))
+	}
+	return exitCode(err)
 }
 
-func cliGet(todoflags map[string]string, client apiclient.APIClient, ctx context.Context) {
-	item, err := client.Req(ctx, "GET", todoflags)
+func checkArgs(cmd command, opts *options, args []string) error {
+	if cmd.nargs >= 0 && len(args) != cmd.nargs {
+		return usagef("usage: todo %s [flags] %s", cmd.name, cmd.args)
+	}
+	if opts.profile.Version != models.V1 && opts.profile.Version != models.V2 {
+		return usagef("--version must be %s or %s", models.V1, models.V2)
+	}
+	// the priorities of the server, to check items offline & to offer them
+	levels, err := models.ParsePriorityLevels(opts.profile.Priorities)
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return usagef("--priorities: %v", err)
+	}
+	models.SetPriorityLevels(levels)
+	switch opts.output {
+	case outputTable, outputJSON, outputYAML:
+	default:
+		return usagef("--output must be %s, %s or %s", outputTable, outputJSON, outputYAML)
 	}
-	fmt.Println("GET success! API response:\n", item)
+	return nil
 }
 
-func cli() {
+// itemArgs are the apiclient args for item.
+func itemArgs(opts *options, item models.ToDo) map[string]string {
+	args := map[string]string{
+		"user-id":     opts.profile.UserId,
+		"id":          item.Id.String(),
+		"title":       item.Title,
+		"description": item.Description,
+		"priority":    string(item.Priority),
+		"complete":    strconv.FormatBool(item.Complete),
+		"revision":    strconv.FormatInt(item.Revision, 10),
+		"tags":        strings.Join(item.Tags, ","),
+		"version":     opts.profile.Version,
+	}
+	if item.Due != nil {
+		args["due"] = item.Due.Format(time.RFC3339)
+	}
+	return args
+}
+
+func idArgs(opts *options, id string) map[string]string {
+	return map[string]string{"user-id": opts.profile.UserId, "id": id, "version": opts.profile.Version}
+}
+
+func requireV2(env *cmdEnv, name string) error {
+	if env.opts.profile.Version != models.V2 {
+		return usagef("%s requires --version=v2", name)
+	}
+	return nil
+}
 
+func addFlags(fs *flag.FlagSet, opts *options) {
+	fs.BoolVar(&opts.literal, "literal", false, "use the text as the title as it is, without reading a priority, tags or due date from it")
+}
+
+// cliAdd adds an item. In v2 the text given as arguments is read as quick add
+// text, see models.ParseQuickAdd, with flags overriding what it gives.
+func cliAdd(ctx context.Context, env *cmdEnv) error {
+	item := models.ToDo{Title: env.opts.title, Description: env.opts.descr, Priority: models.PriorityLow, Complete: env.opts.complete}
+	if len(env.args) > 0 {
+		item.Title = strings.Join(env.args, " ")
+		if !env.opts.literal && env.opts.profile.Version == models.V2 {
+			parsed, err := models.ParseQuickAdd(item.Title, time.Now())
+			if err != nil {
+				return usagef("%v, or add it as it is with --literal", err)
+			}
+			item.Title, item.Priority, item.Tags, item.Due = parsed.Title, parsed.Priority, parsed.Tags, parsed.Due
+		}
+	}
+	if item.Title == "" {
+		return usagef("a title is required, as an argument or --title")
+	}
 	var err error
+	env.fs.Visit(func(f *flag.Flag) {
+		if err == nil {
+			_, err = setItemFlag(env.opts, &item, f.Name)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	created, err := env.addItem(ctx, item)
+	if err != nil {
+		return err
+	}
+	return render(env.out, env.opts.output, created)
+}
 
-	flag.Parse()
-	todoflags := map[string]string{
-		"user-id":  *userId,
-		"id":       *id,
-		"title":    *title,
-		"priority": *priority,
-		"complete": strconv.FormatBool(*complete),
-		"version":  *version,
+// setItemFlag sets the field of item named by an item flag, reporting whether
+// name is one.
+func setItemFlag(opts *options, item *models.ToDo, name string) (bool, error) {
+	switch name {
+	case flagTitle:
+		item.Title = opts.title
+	case flagDescription:
+		item.Description = opts.descr
+	case flagPriority:
+		item.Priority = models.Priority(opts.priority)
+	case flagComplete:
+		item.Complete = opts.complete
+	case flagTags:
+		item.Tags = nil
+		for _, tag := range strings.Split(opts.tags, ",") {
+			if tag = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(tag), "#")); tag != "" {
+				item.Tags = append(item.Tags, tag)
+			}
+		}
+	case flagDue:
+		item.Due = nil
+		if opts.due != "" {
+			due, err := models.ParseDue(opts.due, time.Now())
+			if err != nil {
+				return true, usagef("%v", err)
+			}
+			item.Due = &due
+		}
+	default:
+		return false, nil
 	}
-	ctx := logging.AddTraceID(context.Background())
-	client := apiclient.NewAPIClient("http://localhost:8081/")
+	return true, nil
+}
 
-	if *version != "v1" && *version != "v2" {
-		err = errors.New("missing required flag of --version=<v1|v2>")
-		fmt.Println(err)
-		os.Exit(1)
+func cliGet(ctx context.Context, env *cmdEnv) error {
+	item, err := env.getItem(ctx, env.args[0])
+	if err != nil {
+		return err
 	}
+	return render(env.out, env.opts.output, item)
+}
 
-	for _, action := range cliactions {
-		if *action.flag {
-			action.do(todoflags, client, ctx)
-			os.Exit(0)
+// cliUpdate changes only the fields given as flags.
+func cliUpdate(ctx context.Context, env *cmdEnv) error {
+	item, err := env.getItem(ctx, env.args[0])
+	if err != nil {
+		return err
+	}
+	changed := false
+	env.fs.Visit(func(f *flag.Flag) {
+		if err == nil {
+			var set bool
+			set, err = setItemFlag(env.opts, &item, f.Name)
+			changed = changed || set
 		}
+	})
+	if err != nil {
+		return err
+	}
+	if !changed {
+		return usagef("nothing to update, give at least one of --title, --description, --priority, --complete, --tags or --due")
+	}
+	updated, err := env.updateItem(ctx, item)
+	if err != nil {
+		return err
 	}
+	return render(env.out, env.opts.output, updated)
+}
+
+func cliDone(ctx context.Context, env *cmdEnv) error {
+	item, err := env.getItem(ctx, env.args[0])
+	if err != nil {
+		return err
+	}
+	item.Complete = true
+	updated, err := env.updateItem(ctx, item)
+	if err != nil {
+		return err
+	}
+	return render(env.out, env.opts.output, updated)
+}
+
+func cliDelete(ctx context.Context, env *cmdEnv) error {
+	item, err := env.deleteItem(ctx, env.args[0])
+	if err != nil {
+		return err
+	}
+	return render(env.out, env.opts.output, item)
+}
+
+func listFlags(fs *flag.FlagSet, opts *options) {
+	fs.StringVar(&opts.sort, "sort", string(datastores.OrderByTitle), "order of the items: title, or priority with the most pressing first")
+}
+
+func cliList(ctx context.Context, env *cmdEnv) error {
+	if err := requireV2(env, "list"); err != nil {
+		return err
+	}
+	order, err := datastores.ParseItemOrder(env.opts.sort)
+	if err != nil {
+		return usagef("--sort must be %s or %s", datastores.OrderByTitle, datastores.OrderByPriority)
+	}
+	items, err := env.listItems(ctx, order)
+	if err != nil {
+		return err
+	}
+	return render(env.out, env.opts.output, items)
+}
+
+func cliSearch(ctx context.Context, env *cmdEnv) error {
+	if err := requireV2(env, "search"); err != nil {
+		return err
+	}
+	if len(env.args) == 0 {
+		return usagef("usage: todo search [flags] <words>...")
+	}
+	items, err := env.searchItems(ctx, strings.Join(env.args, " "))
+	if err != nil {
+		return err
+	}
+	return render(env.out, env.opts.output, items)
+}
+
+func cliHistory(ctx context.Context, env *cmdEnv) error {
+	if err := requireV2(env, "history"); err != nil {
+		return err
+	}
+	entries, err := env.client.History(ctx, idArgs(env.opts, env.args[0]))
+	if err != nil {
+		return err
+	}
+	return render(env.out, env.opts.output, entries)
+}
 
-	err = errors.New("no method flag provided. requires 1 of --<post|put|get>")
-	fmt.Println(err)
-	os.Exit(1)
+func cliCompletion(ctx context.Context, env *cmdEnv) error {
+	return writeCompletion(env.out, env.args[0])
 }
 
 func main() {
-	cli()
+	os.Exit(cli(os.Args[1:], os.Stdout, os.Stderr))
 }
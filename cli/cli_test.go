@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"go-to-do-app/to-do-lib/config"
+	"go-to-do-app/to-do-lib/datastores"
+	todoerrors "go-to-do-app/to-do-lib/errors"
+	"go-to-do-app/to-do-lib/models"
+)
+
+// testHome points the config file & the local copies at a directory of the
+// test, so commands neither read nor change the user's own, and unsets the
+// TODO_* environment variables.
+func testHome(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir+"/config")
+	t.Setenv("XDG_CACHE_HOME", dir+"/cache")
+	p := defaultProfile()
+	settings, _ := config.Dump(&p, false)
+	unsetenv(t, profileEnv)
+	for key := range settings {
+		unsetenv(t, config.EnvName(key))
+	}
+	return dir
+}
+
+// unsetenv unsets name for the rest of the test.
+func unsetenv(t *testing.T, name string) {
+	t.Setenv(name, "")
+	os.Unsetenv(name)
+}
+
+// run runs the command line args, returning the exit code & what it wrote.
+func run(args ...string) (int, string, string) {
+	var stdout, stderr strings.Builder
+	code := cli(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+// testAPI serves the v2 item routes the CLI calls from datastore, as the
+// server does.
+func testAPI(t *testing.T, datastore datastores.DataStore) *httptest.Server {
+	t.Helper()
+	var mut sync.Mutex
+	keys := map[string]models.ToDo{}
+	write := func(w http.ResponseWriter, status int, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(v)
+	}
+	fail := func(w http.ResponseWriter, err error) {
+		problem := todoerrors.ProblemFor(err)
+		write(w, problem.Status, problem)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/todo", func(w http.ResponseWriter, r *http.Request) {
+		mut.Lock()
+		defer mut.Unlock()
+		userId := r.Header.Get("X-User-Id")
+		switch r.Method {
+		case http.MethodGet, http.MethodDelete:
+			id, err := datastore.ResolveId(userId, r.URL.Query().Get("id"))
+			if err != nil {
+				fail(w, err)
+				return
+			}
+			var item models.ToDo
+			if r.Method == http.MethodGet {
+				item, err = datastore.GetItem(userId, id)
+			} else {
+				item, err = datastore.DeleteItem(userId, id)
+			}
+			if err != nil {
+				fail(w, err)
+				return
+			}
+			write(w, http.StatusOK, item)
+		case http.MethodPost:
+			if item, seen := keys[r.Header.Get("Idempotency-Key")]; seen {
+				write(w, http.StatusCreated, item)
+				return
+			}
+			var item models.ToDo
+			json.NewDecoder(r.Body).Decode(&item)
+			item.UserId = userId
+			if err := item.Validate(models.V2); err != nil {
+				fail(w, err)
+				return
+			}
+			item = datastore.AddItem(item)
+			if key := r.Header.Get("Idempotency-Key"); key != "" {
+				keys[key] = item
+			}
+			write(w, http.StatusCreated, item)
+		case http.MethodPut:
+			var ref models.ToDoRef
+			json.NewDecoder(r.Body).Decode(&ref)
+			item := ref.ToDo
+			item.UserId = userId
+			id, err := datastore.ResolveId(userId, ref.Id)
+			if err == nil {
+				item.Id = id
+				err = item.Validate(models.V2)
+			}
+			if err == nil {
+				item, err = datastore.UpdateItem(item)
+			}
+			if err != nil {
+				fail(w, err)
+				return
+			}
+			write(w, http.StatusOK, item)
+		}
+	})
+	mux.HandleFunc("/v2/todos", func(w http.ResponseWriter, r *http.Request) {
+		mut.Lock()
+		defer mut.Unlock()
+		order, _ := datastores.ParseItemOrder(r.URL.Query().Get("sort"))
+		items, err := datastore.ListItems(r.Header.Get("X-User-Id"), order)
+		if err != nil {
+			fail(w, err)
+			return
+		}
+		write(w, http.StatusOK, items)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		args       []string
+		positional []string
+		title      string
+		complete   bool
+	}{
+		{[]string{"abc"}, []string{"abc"}, "", false},
+		{[]string{"--title=x", "abc"}, []string{"abc"}, "x", false},
+		{[]string{"abc", "--title", "x"}, []string{"abc"}, "x", false},
+		{[]string{"pay", "--complete", "rent", "--title=x", "now"}, []string{"pay", "rent", "now"}, "x", true},
+		{[]string{"--", "--title=x"}, []string{"--title=x"}, "", false},
+		{nil, nil, "", false},
+	}
+	for _, test := range tests {
+		cmd, _ := findCommand("add")
+		opts := &options{}
+		fs, _ := newFlagSet(cmd, opts, &strings.Builder{})
+		positional, err := parseArgs(fs, test.args)
+		if err != nil {
+			t.Errorf("%q: Expected: %+v, Got: %+v", test.args, nil, err)
+			continue
+		}
+		if !reflect.DeepEqual(positional, test.positional) || opts.title != test.title || opts.complete != test.complete {
+			t.Errorf("%q: Expected: %q %q %t, Got: %q %q %t", test.args, test.positional, test.title, test.complete, positional, opts.title, opts.complete)
+		}
+	}
+}
+
+func TestCommandLines(t *testing.T) {
+	testHome(t)
+	datastore := datastores.NewInMemDataStore()
+	item := datastore.AddItem(models.ToDo{UserId: "alice", Title: "pay rent", Priority: models.PriorityHigh})
+	srv := testAPI(t, datastore)
+	server := "--url=" + srv.URL
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	tests := []struct {
+		args   []string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{nil, exitUsage, "", "Usage: todo <command>"},
+		{[]string{"help"}, exitOK, "Usage: todo <command>", ""},
+		{[]string{"frobnicate"}, exitUsage, "", `unknown command "frobnicate"`},
+		{[]string{"get", "--help"}, exitOK, "", "Usage: todo get [flags] <id>"},
+		{[]string{"get", "--colour"}, exitUsage, "", "flag provided but not defined: -colour"},
+		{[]string{"get"}, exitUsage, "", "usage: todo get [flags] <id>"},
+		{[]string{"get", "a", "b"}, exitUsage, "", "usage: todo get [flags] <id>"},
+		{[]string{"list", "--output=xml"}, exitUsage, "", "--output must be table, json or yaml"},
+		{[]string{"list", "--version=v3"}, exitUsage, "", "--version must be v1 or v2"},
+		{[]string{"list", "--version=v1", server}, exitUsage, "", "list requires --version=v2"},
+		{[]string{"list", "--sort=due", server}, exitUsage, "", "--sort must be title or priority"},
+		{[]string{"add", "--user-id=alice", server}, exitUsage, "", "a title is required"},
+		{[]string{"update", item.ShortId(), "--user-id=alice", server}, exitUsage, "", "nothing to update"},
+		{[]string{"completion", "tcsh"}, exitUsage, "", "tcsh"},
+		{[]string{"get", item.ShortId(), "--user-id=alice", server}, exitOK, "TITLE:     pay rent", ""},
+		{[]string{"get", item.ShortId(), "--user-id=bob", server}, exitNotFound, "", "404 Not Found"},
+		{[]string{"add", "--user-id=alice", "--priority=Urgent", "--literal", "x", server}, exitRejected, "", "400 Validation Failed"},
+		{[]string{"add", "x", "--literal", "--user-id=alice", "--due=someday", server}, exitUsage, "", "someday"},
+		// found in the local copy, which the get above mirrored the item into
+		{[]string{"search", "rent", "--user-id=alice", "--url=" + unreachable.URL, "--offline"}, exitOK, "pay rent", ""},
+	}
+	for _, test := range tests {
+		code, stdout, stderr := run(test.args...)
+		if code != test.code || !strings.Contains(stdout, test.stdout) || !strings.Contains(stderr, test.stderr) {
+			t.Errorf("todo %s: Expected: %d %q %q, Got: %d %q %q", strings.Join(test.args, " "), test.code, test.stdout, test.stderr, code, stdout, stderr)
+		}
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		code int
+	}{
+		{nil, exitOK},
+		{usagef("bad"), exitUsage},
+		{&todoerrors.ValidationError{Field: "title", Err: errors.New("invalid title")}, exitUsage},
+		{&todoerrors.NotFoundError{Message: "ToDo Not Found"}, exitNotFound},
+		{&todoerrors.ConflictError{Message: "changed"}, exitConflict},
+		{todoerrors.NewProblem(http.StatusNotFound, ""), exitNotFound},
+		{todoerrors.NewProblem(http.StatusConflict, ""), exitConflict},
+		{todoerrors.NewProblem(http.StatusBadRequest, ""), exitRejected},
+		{todoerrors.NewProblem(http.StatusForbidden, ""), exitRejected},
+		{todoerrors.NewProblem(http.StatusTooManyRequests, ""), exitRejected},
+		{todoerrors.NewProblem(http.StatusInternalServerError, ""), exitError},
+		{&url.Error{Op: "Get", URL: "http://localhost:1/", Err: errors.New("connection refused")}, exitUnavailable},
+		{errors.New("unexpected"), exitError},
+	}
+	for _, test := range tests {
+		if code := exitCode(test.err); code != test.code {
+			t.Errorf("%v: Expected: %d, Got: %d", test.err, test.code, code)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"go-to-do-app/to-do-lib/models"
+)
+
+// flagValues are the values completed for flags taking a fixed set, and
+// for the first argument of commands.
+var flagValues = map[string][]string{
+	"output":        {outputTable, outputJSON, outputYAML},
+	"version":       {models.V1, models.V2},
+	"priority":      models.PriorityNames(),
+	"sort":          {"title", "priority"},
+	"completion":    {"bash", "zsh", "fish"},
+	"config":        {"set", "get"},
+	"sync-strategy": syncStrategies,
+}
+
+// commandFlags returns the flags of cmd.
+func commandFlags(cmd command) []*flag.Flag {
+	var flags []*flag.Flag
+	fs, _ := newFlagSet(cmd, &options{}, io.Discard)
+	fs.VisitAll(func(f *flag.Flag) {
+		flags = append(flags, f)
+	})
+	return flags
+}
+
+// isFileFlag reports whether f takes a path.
+func isFileFlag(f *flag.Flag) bool {
+	return strings.HasSuffix(f.Name, "-cert") || strings.HasSuffix(f.Name, "-key")
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+func commandNames() []string {
+	names := make([]string, len(commands))
+	for i, cmd := range commands {
+		names[i] = cmd.name
+	}
+	return names
+}
+
+// writeCompletion writes the completion script of shell.
+func writeCompletion(w io.Writer, shell string) error {
+	// complete the priorities of the profile, rather than the defaults
+	flagValues["priority"] = models.PriorityNames()
+	switch shell {
+	case "bash":
+		return writeBashCompletion(w)
+	case "zsh":
+		return writeZshCompletion(w)
+	case "fish":
+		return writeFishCompletion(w)
+	}
+	return usagef("unsupported shell %q, expected bash, zsh or fish", shell)
+}
+
+func writeBashCompletion(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("# bash completion for todo\n_todo() {\n")
+	b.WriteString("    local cur prev cmd\n")
+	b.WriteString("    cur=\"${COMP_WORDS[COMP_CWORD]}\"\n    prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n    cmd=\"${COMP_WORDS[1]}\"\n")
+	fmt.Fprintf(&b, "    if [[ $COMP_CWORD -eq 1 ]]; then\n        COMPREPLY=($(compgen -W %q -- \"$cur\"))\n        return\n    fi\n",
+		strings.Join(commandNames(), " "))
+	b.WriteString("    case \"$prev\" in\n")
+	for _, name := range []string{"output", "version", "priority", "sort", "sync-strategy"} {
+		fmt.Fprintf(&b, "        --%s)\n            COMPREPLY=($(compgen -W %q -- \"$cur\"))\n            return\n            ;;\n",
+			name, strings.Join(flagValues[name], " "))
+	}
+	b.WriteString("        --ca-cert|--client-cert|--client-key)\n            COMPREPLY=($(compgen -f -- \"$cur\"))\n            return\n            ;;\n")
+	b.WriteString("    esac\n    case \"$cmd\" in\n")
+	for _, cmd := range commands {
+		var words []string
+		for _, f := range commandFlags(cmd) {
+			words = append(words, "--"+f.Name)
+		}
+		words = append(words, flagValues[cmd.name]...)
+		fmt.Fprintf(&b, "        %s)\n            COMPREPLY=($(compgen -W %q -- \"$cur\"))\n            ;;\n",
+			cmd.name, strings.Join(words, " "))
+	}
+	b.WriteString("    esac\n}\ncomplete -F _todo todo\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// zshQuote escapes s for a single quoted zsh string.
+func zshQuote(s string) string {
+	return strings.NewReplacer("'", "'\\''", "[", "\\[", "]", "\\]", ":", "\\:").Replace(s)
+}
+
+func writeZshCompletion(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("#compdef todo\n\n_todo() {\n    local -a commands\n    commands=(\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "        '%s:%s'\n", cmd.name, zshQuote(cmd.summary))
+	}
+	b.WriteString("    )\n    if (( CURRENT == 2 )); then\n        _describe 'command' commands\n        return\n    fi\n")
+	b.WriteString("    local cmd=$words[2]\n    shift words\n    (( CURRENT-- ))\n    case $cmd in\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "        %s)\n            _arguments \\\n", cmd.name)
+		for _, f := range commandFlags(cmd) {
+			var action string
+			if values, ok := flagValues[f.Name]; ok {
+				action = ":" + f.Name + ":(" + strings.Join(values, " ") + ")"
+			} else if isFileFlag(f) {
+				action = ":" + f.Name + ":_files"
+			} else if !isBoolFlag(f) {
+				action = ":" + f.Name + ": "
+			}
+			fmt.Fprintf(&b, "                '--%s=[%s]%s' \\\n", f.Name, zshQuote(f.Usage), action)
+		}
+		if values, ok := flagValues[cmd.name]; ok {
+			fmt.Fprintf(&b, "                '1:argument:(%s)' \\\n", strings.Join(values, " "))
+		}
+		b.WriteString("                '*::arg: '\n            ;;\n")
+	}
+	// run when autoloaded from $fpath, register when sourced
+	b.WriteString("    esac\n}\n\nif [[ $funcstack[1] == _todo ]]; then\n    _todo \"$@\"\nelse\n    compdef _todo todo\nfi\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// fishQuote escapes s for a single quoted fish string.
+func fishQuote(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "'", "\\'").Replace(s)
+}
+
+func writeFishCompletion(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("# fish completion for todo\ncomplete -c todo -f\n")
+	names := strings.Join(commandNames(), " ")
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "complete -c todo -n 'not __fish_seen_subcommand_from %s' -a %s -d '%s'\n", names, cmd.name, fishQuote(cmd.summary))
+	}
+	for _, cmd := range commands {
+		cond := "__fish_seen_subcommand_from " + cmd.name
+		for _, f := range commandFlags(cmd) {
+			line := fmt.Sprintf("complete -c todo -n '%s' -l %s -d '%s'", cond, f.Name, fishQuote(f.Usage))
+			if values, ok := flagValues[f.Name]; ok {
+				line += " -x -a '" + strings.Join(values, " ") + "'"
+			} else if isFileFlag(f) {
+				line += " -r -F"
+			} else if !isBoolFlag(f) {
+				line += " -x"
+			}
+			b.WriteString(line + "\n")
+		}
+		if values, ok := flagValues[cmd.name]; ok {
+			fmt.Fprintf(&b, "complete -c todo -n '%s' -a '%s'\n", cond, strings.Join(values, " "))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
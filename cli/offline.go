@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"go-to-do-app/to-do-lib/datastores"
+	"go-to-do-app/to-do-lib/models"
+
+	"github.com/google/uuid"
+)
+
+// cacheStore is the local copy of the server's items.
+type cacheStore interface {
+	datastores.DataStore
+	datastores.ReplicaStore
+}
+
+// localCache keeps a copy of the items of a profile's server, so commands
+// can work while the server is unreachable. Changes made offline are queued
+// until they are pushed by todo sync.
+type localCache struct {
+	store     cacheStore
+	queuePath string
+	// queue holds the changes not yet pushed, oldest first. The revision of
+	// an updated or deleted item is the server revision it was changed
+	// from, which sync uses to detect conflicting changes.
+	queue []datastores.BatchOp
+}
+
+// cacheDir is the directory of a profile's cache, ~/.cache/todo/<profile>
+// on Linux.
+func cacheDir(profileName string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "todo", profileName), nil
+}
+
+func openCache(profileName string) (*localCache, error) {
+	dir, err := cacheDir(profileName)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	itemsPath := filepath.Join(dir, "items.json")
+	if _, err := os.Stat(itemsPath); errors.Is(err, fs.ErrNotExist) {
+		if err := os.WriteFile(itemsPath, []byte(`{"items": []}`), 0o600); err != nil {
+			return nil, err
+		}
+	}
+	c := &localCache{
+		store:     datastores.NewJsonDatastore(itemsPath).(cacheStore),
+		queuePath: filepath.Join(dir, "queue.json"),
+	}
+	data, err := os.ReadFile(c.queuePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &c.queue); err != nil {
+		return nil, fmt.Errorf("%s: %w", c.queuePath, err)
+	}
+	return c, nil
+}
+
+func (c *localCache) close() {
+	c.store.Close()
+}
+
+func (c *localCache) saveQueue() error {
+	data, err := json.MarshalIndent(c.queue, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.queuePath, data, 0o600)
+}
+
+// queued returns the index of the pending change to an item, or -1.
+func (c *localCache) queued(id uuid.UUID) int {
+	for i, op := range c.queue {
+		if op.Item.Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// enqueue records a change made offline, folding it into any change to the
+// same item still waiting, so that each item is pushed once from the server
+// revision it was first changed from.
+func (c *localCache) enqueue(op datastores.BatchOp) error {
+	i := c.queued(op.Item.Id)
+	switch {
+	case i < 0:
+		c.queue = append(c.queue, op)
+	case c.queue[i].Op == datastores.OpCreate && op.Op == datastores.OpDelete:
+		// the server never saw the item
+		c.queue = append(c.queue[:i], c.queue[i+1:]...)
+	case c.queue[i].Op == datastores.OpCreate:
+		c.queue[i].Item = op.Item
+	default:
+		op.Item.Revision = c.queue[i].Item.Revision
+		c.queue[i] = op
+	}
+	return c.saveQueue()
+}
+
+// mirror copies items fetched from the server into the cache, leaving
+// items with pending changes as they are.
+func (c *localCache) mirror(items ...models.ToDo) {
+	for _, item := range items {
+		if c.queued(item.Id) < 0 {
+			c.store.PutItem(item)
+		}
+	}
+}
+
+// refresh replaces the cached items of a user with the full list from the
+// server, keeping the local copy of the items with pending changes.
+func (c *localCache) refresh(userId string, items []models.ToDo) {
+	var replicas []models.ToDo
+	for _, item := range items {
+		if c.queued(item.Id) < 0 {
+			replicas = append(replicas, item)
+		}
+	}
+	for _, op := range c.queue {
+		if local, err := c.store.GetItem(userId, op.Item.Id); err == nil {
+			replicas = append(replicas, local)
+		}
+	}
+	c.store.ReplaceItems(userId, replicas)
+}
+
+// unreachable reports whether err is a failure to reach the server, rather
+// than an error the server returned.
+func unreachable(err error) bool {
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// online reports whether to try the server, falling back to the cache when
+// it cannot be reached.
+func (env *cmdEnv) online() bool {
+	return !env.offline
+}
+
+// goOffline switches to the cache after the server could not be reached,
+// returning err when there is no cache to fall back to.
+func (env *cmdEnv) goOffline(err error) error {
+	if env.cache == nil {
+		return err
+	}
+	if !env.offline {
+		fmt.Fprintf(env.errOut, "Server unreachable, using the local copy. Run 'todo sync' once it is back.\n(%v)\n", err)
+	}
+	env.offline = true
+	return nil
+}
+
+func (env *cmdEnv) localUser() string {
+	return env.opts.profile.UserId
+}
+
+func (env *cmdEnv) getItem(ctx context.Context, id string) (models.ToDo, error) {
+	if env.online() {
+		item, err := env.client.Req(ctx, http.MethodGet, idArgs(env.opts, id))
+		if !unreachable(err) {
+			if err == nil && env.cache != nil {
+				env.cache.mirror(item)
+			}
+			return item, err
+		}
+		if err := env.goOffline(err); err != nil {
+			return models.ToDo{}, err
+		}
+	}
+	itemId, err := env.cache.store.ResolveId(env.localUser(), id)
+	if err != nil {
+		return models.ToDo{}, err
+	}
+	return env.cache.store.GetItem(env.localUser(), itemId)
+}
+
+func (env *cmdEnv) addItem(ctx context.Context, item models.ToDo) (models.ToDo, error) {
+	if env.online() {
+		created, err := env.client.Req(ctx, http.MethodPost, itemArgs(env.opts, item))
+		if !unreachable(err) {
+			if err == nil && env.cache != nil {
+				env.cache.mirror(created)
+			}
+			return created, err
+		}
+		if err := env.goOffline(err); err != nil {
+			return models.ToDo{}, err
+		}
+	}
+	item.UserId = env.localUser()
+	if err := item.Validate(env.opts.profile.Version); err != nil {
+		return models.ToDo{}, err
+	}
+	created := env.cache.store.AddItem(item)
+	return created, env.cache.enqueue(datastores.BatchOp{Op: datastores.OpCreate, Item: created})
+}
+
+func (env *cmdEnv) updateItem(ctx context.Context, item models.ToDo) (models.ToDo, error) {
+	if env.online() {
+		updated, err := env.client.Req(ctx, http.MethodPut, itemArgs(env.opts, item))
+		if !unreachable(err) {
+			if err == nil && env.cache != nil {
+				env.cache.mirror(updated)
+			}
+			return updated, err
+		}
+		if err := env.goOffline(err); err != nil {
+			return models.ToDo{}, err
+		}
+	}
+	if err := item.Validate(env.opts.profile.Version); err != nil {
+		return models.ToDo{}, err
+	}
+	updated, err := env.cache.store.UpdateItem(item)
+	if err != nil {
+		return models.ToDo{}, err
+	}
+	// queued from the revision it was changed from
+	return updated, env.cache.enqueue(datastores.BatchOp{Op: datastores.OpUpdate, Item: item})
+}
+
+func (env *cmdEnv) deleteItem(ctx context.Context, id string) (models.ToDo, error) {
+	if env.online() {
+		deleted, err := env.client.Req(ctx, http.MethodDelete, idArgs(env.opts, id))
+		if !unreachable(err) {
+			if err == nil && env.cache != nil {
+				env.cache.mirror(deleted)
+			}
+			return deleted, err
+		}
+		if err := env.goOffline(err); err != nil {
+			return models.ToDo{}, err
+		}
+	}
+	item, err := env.getItem(ctx, id)
+	if err != nil {
+		return models.ToDo{}, err
+	}
+	deleted, err := env.cache.store.DeleteItem(item.UserId, item.Id)
+	if err != nil {
+		return models.ToDo{}, err
+	}
+	return deleted, env.cache.enqueue(datastores.BatchOp{Op: datastores.OpDelete, Item: item})
+}
+
+func (env *cmdEnv) listItems(ctx context.Context, order datastores.ItemOrder) ([]models.ToDo, error) {
+	if env.online() {
+		items, err := env.client.List(ctx, map[string]string{"user-id": env.opts.profile.UserId, "sort": string(order)})
+		if !unreachable(err) {
+			if err == nil && env.cache != nil {
+				env.cache.refresh(env.opts.profile.UserId, items)
+			}
+			return items, err
+		}
+		if err := env.goOffline(err); err != nil {
+			return nil, err
+		}
+	}
+	return env.cache.store.ListItems(env.localUser(), order)
+}
+
+func (env *cmdEnv) searchItems(ctx context.Context, query string) ([]models.ToDo, error) {
+	if env.online() {
+		items, err := env.client.Search(ctx, map[string]string{"user-id": env.opts.profile.UserId, "query": query})
+		if !unreachable(err) {
+			return items, err
+		}
+		if err := env.goOffline(err); err != nil {
+			return nil, err
+		}
+	}
+	return env.cache.store.Search(env.localUser(), query)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go-to-do-app/to-do-lib/datastores"
+	"go-to-do-app/to-do-lib/models"
+
+	"github.com/google/uuid"
+)
+
+func TestEnqueueFolds(t *testing.T) {
+	a, b := uuid.New(), uuid.New()
+	op := func(kind string, id uuid.UUID, revision int64, title string) datastores.BatchOp {
+		return datastores.BatchOp{Op: kind, Item: models.ToDo{Id: id, Revision: revision, Title: title}}
+	}
+	tests := []struct {
+		ops   []datastores.BatchOp
+		queue []datastores.BatchOp
+	}{
+		// an item added offline is pushed once, as it is last
+		{
+			[]datastores.BatchOp{op(datastores.OpCreate, a, 1, "x"), op(datastores.OpUpdate, a, 1, "y"), op(datastores.OpUpdate, a, 2, "z")},
+			[]datastores.BatchOp{op(datastores.OpCreate, a, 2, "z")},
+		},
+		// and not at all when deleted again
+		{
+			[]datastores.BatchOp{op(datastores.OpCreate, a, 1, "x"), op(datastores.OpCreate, b, 1, "y"), op(datastores.OpDelete, a, 1, "x")},
+			[]datastores.BatchOp{op(datastores.OpCreate, b, 1, "y")},
+		},
+		// changes to a server item keep the revision first changed from
+		{
+			[]datastores.BatchOp{op(datastores.OpUpdate, a, 3, "x"), op(datastores.OpUpdate, a, 4, "y")},
+			[]datastores.BatchOp{op(datastores.OpUpdate, a, 3, "y")},
+		},
+		{
+			[]datastores.BatchOp{op(datastores.OpUpdate, a, 3, "x"), op(datastores.OpDelete, a, 4, "x")},
+			[]datastores.BatchOp{op(datastores.OpDelete, a, 3, "x")},
+		},
+		// in the order the items were first changed
+		{
+			[]datastores.BatchOp{op(datastores.OpUpdate, a, 3, "x"), op(datastores.OpCreate, b, 1, "y"), op(datastores.OpUpdate, a, 4, "z")},
+			[]datastores.BatchOp{op(datastores.OpUpdate, a, 3, "z"), op(datastores.OpCreate, b, 1, "y")},
+		},
+	}
+	for i, test := range tests {
+		c := &localCache{queuePath: filepath.Join(t.TempDir(), "queue.json")}
+		for _, op := range test.ops {
+			if err := c.enqueue(op); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !reflect.DeepEqual(c.queue, test.queue) {
+			t.Errorf("%d: Expected: %+v, Got: %+v", i, test.queue, c.queue)
+		}
+		var saved []datastores.BatchOp
+		data, _ := os.ReadFile(c.queuePath)
+		if err := json.Unmarshal(data, &saved); err != nil || len(saved) != len(test.queue) {
+			t.Errorf("%d: Expected %d saved changes, Got: %s %v", i, len(test.queue), data, err)
+		}
+	}
+}
+
+func TestOfflineFallback(t *testing.T) {
+	testHome(t)
+	datastore := datastores.NewInMemDataStore()
+	srv := testAPI(t, datastore)
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	online, offline := "--url="+srv.URL, "--url="+down.URL
+
+	tests := []struct {
+		args   []string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{[]string{"add", "pay rent", offline}, exitOK, "pay rent", "Server unreachable, using the local copy"},
+		{[]string{"add", "call bank", online, "--offline"}, exitOK, "call bank", ""},
+		{[]string{"list", offline}, exitOK, "call bank", "Server unreachable"},
+		{[]string{"search", "rent", offline}, exitOK, "pay rent", ""},
+		{[]string{"add", "!urgent", "--literal", offline}, exitOK, "!urgent", ""},
+		{[]string{"add", "x", "--priority=Urgent", offline}, exitUsage, "", "priority"},
+		// the server has none of them, and they wait for todo sync
+		{[]string{"list", online}, exitOK, "", "3 changes made offline are waiting"},
+		{[]string{"sync", "--offline", online}, exitUsage, "", "sync needs the server"},
+	}
+	for _, test := range tests {
+		code, stdout, stderr := run(append(test.args, "--user-id=alice")...)
+		if code != test.code || !strings.Contains(stdout, test.stdout) || !strings.Contains(stderr, test.stderr) {
+			t.Errorf("todo %s: Expected: %d %q %q, Got: %d %q %q", strings.Join(test.args, " "), test.code, test.stdout, test.stderr, code, stdout, stderr)
+		}
+	}
+	if items, _ := datastore.ListItems("alice", datastores.OrderByTitle); len(items) != 0 {
+		t.Errorf("Expected no items on the server, Got: %+v", items)
+	}
+}
+
+func TestUnreachableWithoutLocalCopy(t *testing.T) {
+	dir := testHome(t)
+	// a file where the cache directory should be
+	if err := os.WriteFile(filepath.Join(dir, "cache"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	code, _, stderr := run("list", "--url="+down.URL)
+	if code != exitUnavailable || !strings.Contains(stderr, "offline use is unavailable") {
+		t.Errorf("Expected: %d, Got: %d %q", exitUnavailable, code, stderr)
+	}
+	code, _, stderr = run("list", "--url="+down.URL, "--offline")
+	if code != exitError || !strings.Contains(stderr, "unable to open the local copy") {
+		t.Errorf("Expected: %d, Got: %d %q", exitError, code, stderr)
+	}
+}
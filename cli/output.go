@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"go-to-do-app/to-do-lib/models"
+)
+
+// the formats of --output
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+// render writes v in format.
+func render(w io.Writer, format string, v any) error {
+	switch format {
+	case outputJSON:
+		out, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", out)
+		return err
+	case outputYAML:
+		return writeYAML(w, v)
+	default:
+		return writeTable(w, v)
+	}
+}
+
+func writeTable(w io.Writer, v any) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	switch v := v.(type) {
+	case models.ToDo:
+		fmt.Fprintf(tw, "ID:\t%s\n", v.Id)
+		fmt.Fprintf(tw, "SHORT ID:\t%s\n", v.ShortId())
+		if v.UserId != "" {
+			fmt.Fprintf(tw, "USER:\t%s\n", v.UserId)
+		}
+		fmt.Fprintf(tw, "TITLE:\t%s\n", v.Title)
+		fmt.Fprintf(tw, "PRIORITY:\t%s\n", v.Priority)
+		fmt.Fprintf(tw, "COMPLETE:\t%t\n", v.Complete)
+		if v.Status != "" {
+			fmt.Fprintf(tw, "STATUS:\t%s\n", v.Status)
+		}
+		if len(v.Tags) > 0 {
+			fmt.Fprintf(tw, "TAGS:\t%s\n", formatTags(v.Tags))
+		}
+		if v.Due != nil {
+			fmt.Fprintf(tw, "DUE:\t%s\n", v.Due.Format(time.DateOnly))
+		}
+		if v.Assignee != "" {
+			fmt.Fprintf(tw, "ASSIGNEE:\t%s\n", v.Assignee)
+		}
+		if v.DeletedAt != nil {
+			fmt.Fprintf(tw, "DELETED:\t%s\n", v.DeletedAt.Format(time.RFC3339))
+		}
+		if v.Description != "" {
+			fmt.Fprintf(tw, "DESCRIPTION:\t%s\n", strings.ReplaceAll(v.Description, "\n", "\n\t"))
+		}
+	case []models.ToDo:
+		fmt.Fprintln(tw, "ID\tTITLE\tPRIORITY\tCOMPLETE\tDUE\tTAGS")
+		for _, item := range v {
+			due := ""
+			if item.Due != nil {
+				due = item.Due.Format(time.DateOnly)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%t\t%s\t%s\n", item.ShortId(), item.Title, item.Priority, item.Complete, due, formatTags(item.Tags))
+		}
+	case []models.HistoryEntry:
+		fmt.Fprintln(tw, "TIME\tACTION\tACTOR\tTRACE")
+		for _, entry := range v {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", entry.Time.Format(time.RFC3339), entry.Action, entry.Actor, entry.TraceId)
+		}
+	case syncReport:
+		if len(v.Changes) > 0 {
+			fmt.Fprintln(tw, "OP\tID\tTITLE\tRESULT\tDETAIL")
+		}
+		for _, change := range v.Changes {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", change.Op, change.Id, change.Title, change.Result, change.Detail)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintf(w, "Pulled %d items, %d changes pending\n", v.Pulled, v.Pending)
+		return err
+	default:
+		return fmt.Errorf("no table format for %T", v)
+	}
+	return tw.Flush()
+}
+
+// formatTags writes tags as they are typed, "#home #work".
+func formatTags(tags []string) string {
+	written := make([]string, len(tags))
+	for i, tag := range tags {
+		written[i] = "#" + tag
+	}
+	return strings.Join(written, " ")
+}
+
+// writeYAML writes v as block YAML, keeping the field order of its JSON.
+func writeYAML(w io.Writer, v any) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	value, err := decodeOrdered(dec)
+	if err != nil {
+		return err
+	}
+	var out strings.Builder
+	emitYAML(&out, value, 0)
+	_, err = io.WriteString(w, out.String())
+	return err
+}
+
+// field is a member of a JSON object, which decodeOrdered keeps in order.
+type field struct {
+	key   string
+	value any
+}
+
+func decodeOrdered(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		fields := []field{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, field{key: key.(string), value: value})
+		}
+		_, err = dec.Token()
+		return fields, err
+	case json.Delim('['):
+		items := []any{}
+		for dec.More() {
+			value, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+		}
+		_, err = dec.Token()
+		return items, err
+	}
+	return tok, nil
+}
+
+func emitYAML(out *strings.Builder, value any, indent int) {
+	pad := strings.Repeat("  ", indent)
+	switch v := value.(type) {
+	case []field:
+		if len(v) == 0 {
+			out.WriteString(pad + "{}\n")
+		}
+		for _, f := range v {
+			emitMember(out, pad+yamlScalar(f.key)+":", f.value, indent)
+		}
+	case []any:
+		if len(v) == 0 {
+			out.WriteString(pad + "[]\n")
+		}
+		for _, item := range v {
+			fields, isObject := item.([]field)
+			if !isObject || len(fields) == 0 {
+				emitMember(out, pad+"-", item, indent)
+				continue
+			}
+			// the first field of an object shares the line of its dash
+			var first strings.Builder
+			emitYAML(&first, fields[:1], indent+1)
+			out.WriteString(pad + "- " + strings.TrimPrefix(first.String(), pad+"  "))
+			emitYAML(out, fields[1:], indent+1)
+		}
+	default:
+		out.WriteString(pad + yamlScalar(v) + "\n")
+	}
+}
+
+// emitMember writes a value after its key or dash.
+func emitMember(out *strings.Builder, prefix string, value any, indent int) {
+	switch v := value.(type) {
+	case []field:
+		if len(v) > 0 {
+			out.WriteString(prefix + "\n")
+			emitYAML(out, v, indent+1)
+			return
+		}
+		out.WriteString(prefix + " {}\n")
+	case []any:
+		if len(v) > 0 {
+			out.WriteString(prefix + "\n")
+			emitYAML(out, v, indent+1)
+			return
+		}
+		out.WriteString(prefix + " []\n")
+	default:
+		out.WriteString(prefix + " " + yamlScalar(v) + "\n")
+	}
+}
+
+func yamlScalar(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case json.Number:
+		return v.String()
+	case string:
+		if yamlNeedsQuotes(v) {
+			return strconv.Quote(v)
+		}
+		return v
+	}
+	return fmt.Sprint(value)
+}
+
+// yamlNeedsQuotes reports whether s would not read back as the same string.
+func yamlNeedsQuotes(s string) bool {
+	if s == "" || strings.TrimSpace(s) != s {
+		return true
+	}
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "null", "~", "y", "n":
+		return true
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return true
+	}
+	if strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`") {
+		return true
+	}
+	return strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") ||
+		strings.ContainsAny(s, "\n\r\t")
+}
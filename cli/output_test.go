@@ -0,0 +1,102 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go-to-do-app/to-do-lib/models"
+
+	"github.com/google/uuid"
+)
+
+func TestRender(t *testing.T) {
+	due := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	item := models.ToDo{
+		UserId:      "alice",
+		Id:          uuid.MustParse("0b5a8f3e-1c2d-4e5f-8a9b-0c1d2e3f4a5b"),
+		Title:       "pay rent",
+		Description: "by transfer\nnot cash",
+		Priority:    models.PriorityHigh,
+		Tags:        []string{"home", "money"},
+		Due:         &due,
+		Revision:    2,
+	}
+	tests := []struct {
+		format string
+		v      any
+		out    string
+	}{
+		{outputTable, item, `ID:           0b5a8f3e-1c2d-4e5f-8a9b-0c1d2e3f4a5b
+SHORT ID:     0b5a8f3e
+USER:         alice
+TITLE:        pay rent
+PRIORITY:     High
+COMPLETE:     false
+TAGS:         #home #money
+DUE:          2024-06-01
+DESCRIPTION:  by transfer
+              not cash
+`},
+		{outputTable, []models.ToDo{item, {Id: uuid.Max, Title: "call: bank", Priority: models.PriorityLow, Complete: true, Tags: []string{"x"}}}, `ID        TITLE       PRIORITY  COMPLETE  DUE         TAGS
+0b5a8f3e  pay rent    High      false     2024-06-01  #home #money
+ffffffff  call: bank  Low       true                  #x
+`},
+		{outputTable, syncReport{Changes: []syncChange{{Op: "update", Id: item.Id, Title: "pay rent", Result: syncConflict, Detail: "409 Conflict"}}, Pulled: 3, Pending: 1}, `OP      ID                                    TITLE     RESULT    DETAIL
+update  0b5a8f3e-1c2d-4e5f-8a9b-0c1d2e3f4a5b  pay rent  conflict  409 Conflict
+Pulled 3 items, 1 changes pending
+`},
+		{outputTable, syncReport{}, "Pulled 0 items, 0 changes pending\n"},
+		{outputJSON, item, `{
+  "user_id": "alice",
+  "id": "0b5a8f3e-1c2d-4e5f-8a9b-0c1d2e3f4a5b",
+  "title": "pay rent",
+  "description": "by transfer\nnot cash",
+  "priority": "High",
+  "complete": false,
+  "tags": [
+    "home",
+    "money"
+  ],
+  "due": "2024-06-01T00:00:00Z",
+  "revision": 2
+}
+`},
+		{outputJSON, []models.ToDo{}, "[]\n"},
+		{outputYAML, item, `user_id: alice
+id: 0b5a8f3e-1c2d-4e5f-8a9b-0c1d2e3f4a5b
+title: pay rent
+description: "by transfer\nnot cash"
+priority: High
+complete: false
+tags:
+  - home
+  - money
+due: 2024-06-01T00:00:00Z
+revision: 2
+`},
+		{outputYAML, []models.ToDo{{Id: uuid.Nil, Title: "call: bank", Priority: "true"}}, `- id: 00000000-0000-0000-0000-000000000000
+  title: "call: bank"
+  priority: "true"
+  complete: false
+`},
+		{outputYAML, syncReport{}, "changes: null\npulled: 0\npending: 0\n"},
+		{outputYAML, []models.ToDo{}, "[]\n"},
+	}
+	for i, test := range tests {
+		var out strings.Builder
+		if err := render(&out, test.format, test.v); err != nil {
+			t.Errorf("%d %s: Expected: %+v, Got: %+v", i, test.format, nil, err)
+			continue
+		}
+		if out.String() != test.out {
+			t.Errorf("%d %s: Expected:\n%s\nGot:\n%s", i, test.format, test.out, out.String())
+		}
+	}
+}
+
+func TestRenderTableOfUnknownType(t *testing.T) {
+	if err := render(&strings.Builder{}, outputTable, 42); err == nil {
+		t.Errorf("Expected an error for a table of an int")
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"go-to-do-app/to-do-lib/config"
+	"go-to-do-app/to-do-lib/models"
+)
+
+// profile is the server a command talks to and who as, read from the named
+// profile of the config file, TODO_* environment variables and flags, in
+// order of precedence.
+type profile struct {
+	URL        string `config:"url" flag:"url" usage:"base url of the to do server"`
+	Version    string `config:"version" flag:"version" usage:"version of the api to use (v1, v2)"`
+	UserId     string `config:"user-id" flag:"user-id" usage:"id of the user the items belong to (v2)"`
+	Token      string `config:"token" flag:"token" usage:"token sent to the server to authenticate" secret:"true"`
+	CACert     string `config:"ca-cert" flag:"ca-cert" usage:"PEM file of a CA to trust for https, such as the server's self-signed certificate"`
+	ClientCert string `config:"client-cert" flag:"client-cert" usage:"PEM certificate to present to servers using mutual TLS"`
+	ClientKey  string `config:"client-key" flag:"client-key" usage:"PEM private key of --client-cert"`
+	// SyncStrategy resolves the conflicts found by todo sync
+	SyncStrategy string `config:"sync-strategy" flag:"sync-strategy" usage:"how sync handles changes made offline to items that have also changed on the server: manual keeps them to resolve later, server discards them, client overwrites the server"`
+	// Priorities are the priority levels of the server
+	Priorities []string `config:"priorities" flag:"priorities" usage:"comma separated priority levels of the server, least pressing first, each a name or name=rank"`
+}
+
+func defaultProfile() profile {
+	return profile{URL: "http://localhost:8081/", Version: models.V2, SyncStrategy: strategyManual, Priorities: models.PriorityNames()}
+}
+
+const (
+	// defaultProfileName is used when no profile is selected
+	defaultProfileName = "default"
+	// currentProfileKey is the setting of the config file selecting a
+	// profile when neither --profile nor TODO_PROFILE are given
+	currentProfileKey = "current-profile"
+	// profilesKey prefixes the settings of each profile in the config file
+	profilesKey = "profiles"
+)
+
+var profileEnv = config.EnvPrefix + "PROFILE"
+
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// configPath is the config file, ~/.config/todo/config on Linux.
+func configPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "todo", "config"), nil
+}
+
+// readConfig reads the YAML config file at path, which need not exist.
+func readConfig(path string) (config.Values, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return config.Values{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	values, err := config.ParseYAML(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return values, nil
+}
+
+// writeConfig replaces the config file, which is private to the user as it
+// holds tokens.
+func writeConfig(path string, values config.Values) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	var out strings.Builder
+	if err := config.WriteYAML(&out, values); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(out.String()), 0o600)
+}
+
+// profileName is the profile selected by --profile, TODO_PROFILE or the
+// config file, in that order.
+func profileName(flagValue string, values config.Values) (string, error) {
+	name := flagValue
+	if name == "" {
+		name = os.Getenv(profileEnv)
+	}
+	if name == "" {
+		name = values[currentProfileKey]
+	}
+	if name == "" {
+		name = defaultProfileName
+	}
+	if !profileNamePattern.MatchString(name) {
+		return "", usagef("invalid profile name %q, use letters, digits, - and _", name)
+	}
+	return name, nil
+}
+
+// profileValues returns the settings of the named profile, keyed as in the
+// profile struct, and whether the profile exists.
+func profileValues(values config.Values, name string) (config.Values, bool) {
+	prefix := profilesKey + "." + name + "."
+	out := config.Values{}
+	for key, value := range values {
+		if setting, found := strings.CutPrefix(key, prefix); found {
+			out[setting] = value
+		}
+	}
+	return out, len(out) > 0
+}
+
+// loadProfile sets opts.profile from the config file, the environment and
+// the flags set on the command line.
+func loadProfile(opts *options, flags *config.Flags, requireProfile bool) error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	values, err := readConfig(path)
+	if err != nil {
+		return err
+	}
+	name, err := profileName(opts.profileName, values)
+	if err != nil {
+		return err
+	}
+	fromFile, exists := profileValues(values, name)
+	if !exists && requireProfile && name != defaultProfileName {
+		return usagef("profile %q is not in %s, add it with 'todo config set --profile=%s url <url>'", name, path, name)
+	}
+	env, err := config.FromEnv(&opts.profile)
+	if err != nil {
+		return err
+	}
+	if err := config.Apply(&opts.profile, fromFile, env, flags.Values()); err != nil {
+		return fmt.Errorf("profile %s: %w", name, err)
+	}
+	opts.profileName = name
+	return nil
+}
+
+// cliConfig gets and sets the settings of the selected profile.
+func cliConfig(ctx context.Context, env *cmdEnv) error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	values, err := readConfig(path)
+	if err != nil {
+		return err
+	}
+	prefix := profilesKey + "." + env.opts.profileName + "."
+	switch {
+	case len(env.args) == 3 && env.args[0] == "set":
+		key, value := env.args[1], env.args[2]
+		if key == currentProfileKey {
+			if _, err := profileName(value, nil); err != nil {
+				return err
+			}
+			values[key] = value
+			return writeConfig(path, values)
+		}
+		if err := checkSetting(key, value); err != nil {
+			return err
+		}
+		values[prefix+key] = value
+		return writeConfig(path, values)
+	case len(env.args) == 2 && env.args[0] == "get":
+		key := env.args[1]
+		if key == currentProfileKey {
+			_, err := fmt.Fprintln(env.out, env.opts.profileName)
+			return err
+		}
+		settings, err := config.Dump(&env.opts.profile, false)
+		if err != nil {
+			return err
+		}
+		value, exists := settings[key]
+		if !exists {
+			return usagef("unknown setting %s", key)
+		}
+		_, err = fmt.Fprintln(env.out, value)
+		return err
+	case len(env.args) == 1 && env.args[0] == "get":
+		settings, err := config.Dump(&env.opts.profile, true)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(env.out, "# profile %s\n", env.opts.profileName)
+		return config.WriteYAML(env.out, settings)
+	}
+	return usagef("usage: todo config [flags] set <setting> <value> | get [setting]")
+}
+
+// checkSetting reports a setting that is unknown or has an invalid value.
+func checkSetting(key, value string) error {
+	scratch := defaultProfile()
+	if err := config.Apply(&scratch, config.Values{key: value}); err != nil {
+		return usagef("%v", err)
+	}
+	if key == "version" && value != models.V1 && value != models.V2 {
+		return usagef("version must be %s or %s", models.V1, models.V2)
+	}
+	if key == "sync-strategy" {
+		return checkStrategy(value)
+	}
+	if key == "priorities" {
+		if _, err := models.ParsePriorityLevels(scratch.Priorities); err != nil {
+			return usagef("priorities: %v", err)
+		}
+	}
+	return nil
+}
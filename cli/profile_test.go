@@ -0,0 +1,137 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"go-to-do-app/to-do-lib/config"
+)
+
+func TestProfilePrecedence(t *testing.T) {
+	testHome(t)
+	path, _ := configPath()
+	err := writeConfig(path, config.Values{
+		currentProfileKey:       "work",
+		"profiles.default.url":  "http://default/",
+		"profiles.work.url":     "http://work/",
+		"profiles.work.user-id": "bob",
+		"profiles.work.version": "v1",
+		"profiles.home.url":     "http://home/",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		env     map[string]string
+		args    []string
+		profile string
+		url     string
+		userId  string
+		version string
+		err     string
+	}{
+		// the current profile of the file
+		{nil, nil, "work", "http://work/", "bob", "v1", ""},
+		// over which TODO_PROFILE, then --profile, select another
+		{map[string]string{profileEnv: "home"}, nil, "home", "http://home/", "", "v2", ""},
+		{map[string]string{profileEnv: "home"}, []string{"--profile=default"}, "default", "http://default/", "", "v2", ""},
+		// settings from the file are overridden by TODO_*, then by flags
+		{map[string]string{"TODO_URL": "http://env/"}, nil, "work", "http://env/", "bob", "v1", ""},
+		{map[string]string{"TODO_URL": "http://env/", "TODO_USER_ID": "carol"}, []string{"--url=http://flag/"}, "work", "http://flag/", "carol", "v1", ""},
+		{nil, []string{"--version=v2", "--user-id=dave"}, "work", "http://work/", "dave", "v2", ""},
+		// only the default profile need not be in the file
+		{nil, []string{"--profile=missing"}, "", "", "", "", `profile "missing" is not in`},
+		{nil, []string{"--profile=../work"}, "", "", "", "", `invalid profile name "../work"`},
+	}
+	for i, test := range tests {
+		t.Run("", func(t *testing.T) {
+			for name, value := range test.env {
+				t.Setenv(name, value)
+			}
+			cmd, _ := findCommand("list")
+			opts := &options{}
+			fs, flags := newFlagSet(cmd, opts, &strings.Builder{})
+			if _, err := parseArgs(fs, test.args); err != nil {
+				t.Fatal(err)
+			}
+			err := loadProfile(opts, flags, true)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) || exitCode(err) != exitUsage {
+					t.Errorf("%d: Expected: %q, Got: %+v", i, test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%d: Expected: %+v, Got: %+v", i, nil, err)
+			}
+			p := opts.profile
+			if opts.profileName != test.profile || p.URL != test.url || p.UserId != test.userId || p.Version != test.version {
+				t.Errorf("%d: Expected: %s %s %q %s, Got: %s %s %q %s", i, test.profile, test.url, test.userId, test.version, opts.profileName, p.URL, p.UserId, p.Version)
+			}
+		})
+	}
+}
+
+func TestConfigSetGet(t *testing.T) {
+	testHome(t)
+	tests := []struct {
+		args   []string
+		code   int
+		stdout string
+	}{
+		{[]string{"set", "url", "http://work/", "--profile=work"}, exitOK, ""},
+		{[]string{"set", "user-id", "bob", "--profile=work"}, exitOK, ""},
+		{[]string{"set", "token", "s3cret", "--profile=work"}, exitOK, ""},
+		{[]string{"set", "version", "v3", "--profile=work"}, exitUsage, ""},
+		{[]string{"set", "sync-strategy", "eager", "--profile=work"}, exitUsage, ""},
+		{[]string{"set", "priorities", "Low,Low", "--profile=work"}, exitUsage, ""},
+		{[]string{"set", "colour", "red", "--profile=work"}, exitUsage, ""},
+		{[]string{"set", "current-profile", "../work"}, exitUsage, ""},
+		{[]string{"get", "url"}, exitOK, "http://localhost:8081/\n"},
+		{[]string{"set", "current-profile", "work"}, exitOK, ""},
+		{[]string{"get", "current-profile"}, exitOK, "work\n"},
+		{[]string{"get", "url"}, exitOK, "http://work/\n"},
+		{[]string{"get", "url", "--url=http://flag/"}, exitOK, "http://flag/\n"},
+		{[]string{"get", "token"}, exitOK, "s3cret\n"},
+		{[]string{"get"}, exitOK, "# profile work\n"},
+		{[]string{"get"}, exitOK, `token: "` + config.Redacted + `"`},
+		{[]string{"get"}, exitOK, "user-id: bob\n"},
+		{[]string{"get", "colour"}, exitUsage, ""},
+		{[]string{"get", "url", "--profile=home"}, exitOK, "http://localhost:8081/\n"},
+		{[]string{"unset", "url"}, exitUsage, ""},
+	}
+	for _, test := range tests {
+		code, stdout, stderr := run(append([]string{"config"}, test.args...)...)
+		if code != test.code || !strings.Contains(stdout, test.stdout) {
+			t.Errorf("todo config %s: Expected: %d %q, Got: %d %q %q", strings.Join(test.args, " "), test.code, test.stdout, code, stdout, stderr)
+		}
+	}
+
+	path, _ := configPath()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected: %v, Got: %v", os.FileMode(0o600), info.Mode().Perm())
+	}
+	values, err := readConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := config.Values{
+		currentProfileKey:       "work",
+		"profiles.work.url":     "http://work/",
+		"profiles.work.user-id": "bob",
+		"profiles.work.token":   "s3cret",
+	}
+	if len(values) != len(expected) {
+		t.Errorf("Expected: %+v, Got: %+v", expected, values)
+	}
+	for key, value := range expected {
+		if values[key] != value {
+			t.Errorf("%s: Expected: %q, Got: %q", key, value, values[key])
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"go-to-do-app/to-do-lib/datastores"
+	todoerrors "go-to-do-app/to-do-lib/errors"
+	"go-to-do-app/to-do-lib/models"
+
+	"github.com/google/uuid"
+)
+
+// The strategies of todo sync for a change to an item that has also changed
+// on the server since it was made.
+const (
+	// strategyManual keeps the change queued and reports it, to be resolved
+	// by syncing again with another strategy
+	strategyManual = "manual"
+	// strategyServer discards the change, keeping the server's item
+	strategyServer = "server"
+	// strategyClient applies the change over the server's item
+	strategyClient = "client"
+)
+
+var syncStrategies = []string{strategyManual, strategyServer, strategyClient}
+
+// the results of pushing a change
+const (
+	syncPushed      = "pushed"
+	syncConflict    = "conflict"
+	syncRejected    = "rejected"
+	syncDiscarded   = "discarded"
+	syncOverwritten = "overwritten"
+)
+
+// syncChange is the outcome of pushing one queued change.
+type syncChange struct {
+	Op     string    `json:"op"`
+	Id     uuid.UUID `json:"id"`
+	Title  string    `json:"title"`
+	Result string    `json:"result"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+type syncReport struct {
+	Changes []syncChange `json:"changes"`
+	// Pulled is the number of items fetched from the server
+	Pulled int `json:"pulled"`
+	// Pending is the number of changes still queued
+	Pending int `json:"pending"`
+}
+
+func checkStrategy(strategy string) error {
+	for _, s := range syncStrategies {
+		if s == strategy {
+			return nil
+		}
+	}
+	return usagef("sync-strategy must be %s, %s or %s", strategyManual, strategyServer, strategyClient)
+}
+
+// cliSync pushes the queued changes in order, then replaces the local copy
+// with the server's items.
+func cliSync(ctx context.Context, env *cmdEnv) error {
+	if err := requireV2(env, "sync"); err != nil {
+		return err
+	}
+	strategy := env.opts.profile.SyncStrategy
+	if err := checkStrategy(strategy); err != nil {
+		return err
+	}
+	if env.cache == nil {
+		return errors.New("there is no local copy to sync")
+	}
+	if env.opts.offline {
+		return usagef("sync needs the server, remove --offline")
+	}
+
+	var report syncReport
+	var kept []datastores.BatchOp
+	for i, op := range env.cache.queue {
+		change, keep, err := pushChange(ctx, env, op, strategy)
+		if unreachable(err) {
+			// stop where we are, keeping the changes not yet pushed
+			env.cache.queue = append(kept, env.cache.queue[i:]...)
+			if err := env.cache.saveQueue(); err != nil {
+				return err
+			}
+			return err
+		}
+		report.Changes = append(report.Changes, change)
+		if keep {
+			kept = append(kept, op)
+		}
+	}
+	env.cache.queue = kept
+	if err := env.cache.saveQueue(); err != nil {
+		return err
+	}
+
+	items, err := env.client.List(ctx, map[string]string{"user-id": env.opts.profile.UserId})
+	if err != nil {
+		return err
+	}
+	env.cache.refresh(env.opts.profile.UserId, items)
+	report.Pulled = len(items)
+	report.Pending = len(kept)
+	if err := render(env.out, env.opts.output, report); err != nil {
+		return err
+	}
+	if len(kept) > 0 {
+		return &todoerrors.ConflictError{Message: fmt.Sprintf(
+			"%d changes were not pushed, sync again with --sync-strategy=%s to keep the server's items or --sync-strategy=%s to overwrite them",
+			len(kept), strategyServer, strategyClient,
+		)}
+	}
+	return nil
+}
+
+// pushChange sends a queued change to the server, reporting whether it is to
+// stay queued. Only a failure to reach the server is returned as an error.
+func pushChange(ctx context.Context, env *cmdEnv, op datastores.BatchOp, strategy string) (syncChange, bool, error) {
+	change := syncChange{Op: op.Op, Id: op.Item.Id, Title: op.Item.Title, Result: syncPushed}
+	var err error
+	switch op.Op {
+	case datastores.OpCreate:
+		// keyed by the id it was added offline as, so a sync retried after
+		// the server added it, but before it was dropped from the queue,
+		// does not add it twice
+		args := itemArgs(env.opts, op.Item)
+		args["idempotency-key"] = op.Item.Id.String()
+		var created models.ToDo
+		if created, err = env.client.Req(ctx, http.MethodPost, args); err == nil {
+			change.Id = created.Id
+			change.Detail = fmt.Sprintf("added offline as %s", op.Item.Id)
+		}
+	case datastores.OpUpdate:
+		// the item carries the revision it was changed from
+		if _, err = env.client.Req(ctx, http.MethodPut, itemArgs(env.opts, op.Item)); changedOnServer(err) {
+			return resolveConflict(ctx, env, op, strategy, change, err)
+		}
+	case datastores.OpDelete:
+		var current models.ToDo
+		current, err = env.client.Req(ctx, http.MethodGet, idArgs(env.opts, op.Item.Id.String()))
+		switch {
+		case isNotFound(err):
+			change.Detail = "already deleted"
+			return change, false, nil
+		case err == nil && current.Revision != op.Item.Revision:
+			return resolveConflict(ctx, env, op, strategy, change, fmt.Errorf("changed on the server since revision %d", op.Item.Revision))
+		case err == nil:
+			_, err = env.client.Req(ctx, http.MethodDelete, idArgs(env.opts, op.Item.Id.String()))
+		}
+	}
+	if unreachable(err) {
+		return change, true, err
+	}
+	if err != nil {
+		// the server will not accept the change however often it is sent
+		change.Result, change.Detail = syncRejected, err.Error()
+		return change, strategy == strategyManual, nil
+	}
+	return change, false, nil
+}
+
+// resolveConflict applies strategy to a change made to an item that has
+// also changed on the server.
+func resolveConflict(ctx context.Context, env *cmdEnv, op datastores.BatchOp, strategy string, change syncChange, conflict error) (syncChange, bool, error) {
+	change.Detail = conflict.Error()
+	switch strategy {
+	case strategyServer:
+		change.Result = syncDiscarded
+		return change, false, nil
+	case strategyClient:
+		change.Result = syncOverwritten
+		var err error
+		if op.Op == datastores.OpDelete {
+			_, err = env.client.Req(ctx, http.MethodDelete, idArgs(env.opts, op.Item.Id.String()))
+		} else {
+			item := op.Item
+			// apply over whatever the server has now
+			item.Revision = 0
+			_, err = env.client.Req(ctx, http.MethodPut, itemArgs(env.opts, item))
+			if isNotFound(err) {
+				// deleted on the server, so add it back
+				var created models.ToDo
+				if created, err = env.client.Req(ctx, http.MethodPost, itemArgs(env.opts, item)); err == nil {
+					change.Id, change.Detail = created.Id, "deleted on the server, added again"
+				}
+			}
+		}
+		if unreachable(err) {
+			return change, true, err
+		}
+		if err != nil {
+			change.Result, change.Detail = syncRejected, err.Error()
+		}
+		return change, false, nil
+	default:
+		change.Result = syncConflict
+		return change, true, nil
+	}
+}
+
+// changedOnServer reports whether the server refused an update because the
+// item has changed or been deleted since the revision sent.
+func changedOnServer(err error) bool {
+	var problem *todoerrors.Problem
+	return errors.As(err, &problem) && (problem.Status == http.StatusConflict || problem.Status == http.StatusNotFound)
+}
+
+func isNotFound(err error) bool {
+	var problem *todoerrors.Problem
+	return errors.As(err, &problem) && problem.Status == http.StatusNotFound
+}
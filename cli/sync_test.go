@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"go-to-do-app/to-do-lib/datastores"
+	"go-to-do-app/to-do-lib/models"
+)
+
+// syncAs runs todo sync for alice with strategy, returning its exit code &
+// report.
+func syncAs(t *testing.T, url string, strategy string) (int, syncReport) {
+	t.Helper()
+	code, stdout, stderr := run("sync", "--user-id=alice", "--url="+url, "--sync-strategy="+strategy, "--output=json")
+	var report syncReport
+	if err := json.Unmarshal([]byte(stdout), &report); err != nil {
+		t.Fatalf("Expected a report, Got: %d %q %q", code, stdout, stderr)
+	}
+	return code, report
+}
+
+func TestSyncStrategies(t *testing.T) {
+	tests := []struct {
+		// change is made offline to the item, which then changes on the
+		// server
+		change   []string
+		strategy string
+		code     int
+		result   string
+		pending  int
+		// title is that of the server's item after the sync, "" if deleted
+		title string
+	}{
+		{[]string{"update", "--title=local"}, strategyManual, exitConflict, syncConflict, 1, "server"},
+		{[]string{"update", "--title=local"}, strategyServer, exitOK, syncDiscarded, 0, "server"},
+		{[]string{"update", "--title=local"}, strategyClient, exitOK, syncOverwritten, 0, "local"},
+		{[]string{"delete"}, strategyManual, exitConflict, syncConflict, 1, "server"},
+		{[]string{"delete"}, strategyServer, exitOK, syncDiscarded, 0, "server"},
+		{[]string{"delete"}, strategyClient, exitOK, syncOverwritten, 0, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.change[0]+"-"+test.strategy, func(t *testing.T) {
+			testHome(t)
+			datastore := datastores.NewInMemDataStore()
+			item := datastore.AddItem(models.ToDo{UserId: "alice", Title: "original", Priority: models.PriorityLow})
+			srv := testAPI(t, datastore)
+
+			// fetched into the local copy at revision 1
+			if code, _, stderr := run("get", item.ShortId(), "--user-id=alice", "--url="+srv.URL); code != exitOK {
+				t.Fatalf("Expected: %d, Got: %d %q", exitOK, code, stderr)
+			}
+			args := []string{test.change[0], item.ShortId(), "--user-id=alice", "--offline"}
+			if code, _, stderr := run(append(args, test.change[1:]...)...); code != exitOK {
+				t.Fatalf("Expected: %d, Got: %d %q", exitOK, code, stderr)
+			}
+			item.Title = "server"
+			if _, err := datastore.UpdateItem(item); err != nil {
+				t.Fatal(err)
+			}
+
+			code, report := syncAs(t, srv.URL, test.strategy)
+			if code != test.code || len(report.Changes) != 1 || report.Changes[0].Result != test.result || report.Pending != test.pending {
+				t.Errorf("Expected: %d %s %d pending, Got: %d %+v", test.code, test.result, test.pending, code, report)
+			}
+			current, err := datastore.GetItem("alice", item.Id)
+			if test.title == "" {
+				if err == nil {
+					t.Errorf("Expected the item deleted, Got: %+v", current)
+				}
+			} else if err != nil || current.Title != test.title {
+				t.Errorf("Expected: %q, Got: %+v %v", test.title, current, err)
+			}
+
+			// a conflict kept by manual is resolved by syncing with another
+			// strategy
+			if test.pending > 0 {
+				if code, report := syncAs(t, srv.URL, strategyServer); code != exitOK || report.Pending != 0 {
+					t.Errorf("Expected: %d, Got: %d %+v", exitOK, code, report)
+				}
+			}
+		})
+	}
+}
+
+func TestSyncPushesAddsOnce(t *testing.T) {
+	testHome(t)
+	datastore := datastores.NewInMemDataStore()
+	srv := testAPI(t, datastore)
+	for _, title := range []string{"pay rent", "call bank"} {
+		if code, _, stderr := run("add", title, "--user-id=alice", "--url="+srv.URL, "--offline"); code != exitOK {
+			t.Fatalf("Expected: %d, Got: %d %q", exitOK, code, stderr)
+		}
+	}
+	cache, err := openCache(defaultProfileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queued, err := os.ReadFile(cache.queuePath)
+	cache.close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, report := syncAs(t, srv.URL, strategyManual)
+	if code != exitOK || len(report.Changes) != 2 || report.Pulled != 2 || report.Pending != 0 {
+		t.Errorf("Expected 2 changes pushed & pulled, Got: %d %+v", code, report)
+	}
+	for _, change := range report.Changes {
+		if change.Result != syncPushed || !strings.HasPrefix(change.Detail, "added offline as") {
+			t.Errorf("Expected: %s, Got: %+v", syncPushed, change)
+		}
+	}
+
+	// a sync interrupted before the queue was saved pushes the adds again,
+	// which the server has already made
+	if err := os.WriteFile(cache.queuePath, queued, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if code, report := syncAs(t, srv.URL, strategyManual); code != exitOK || report.Pulled != 2 {
+		t.Errorf("Expected: %d, Got: %d %+v", exitOK, code, report)
+	}
+	if items, _ := datastore.ListItems("alice", datastores.OrderByTitle); len(items) != 2 {
+		t.Errorf("Expected: %d, Got: %+v", 2, items)
+	}
+	code, stdout, _ := run("list", "--user-id=alice", "--url="+srv.URL, "--offline")
+	if code != exitOK || !strings.Contains(stdout, "call bank") || !strings.Contains(stdout, "pay rent") {
+		t.Errorf("Expected the pulled items in the local copy, Got: %d %q", code, stdout)
+	}
+}
+
+func TestSyncStopsWhenUnreachable(t *testing.T) {
+	testHome(t)
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	if code, _, stderr := run("add", "pay rent", "--user-id=alice", "--url="+down.URL); code != exitOK {
+		t.Fatalf("Expected: %d, Got: %d %q", exitOK, code, stderr)
+	}
+	code, _, _ := run("sync", "--user-id=alice", "--url="+down.URL)
+	if code != exitUnavailable {
+		t.Errorf("Expected: %d, Got: %d", exitUnavailable, code)
+	}
+	cache, err := openCache(defaultProfileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.close()
+	if len(cache.queue) != 1 || cache.queue[0].Item.Title != "pay rent" {
+		t.Errorf("Expected the add still queued, Got: %+v", cache.queue)
+	}
+}
@@ -0,0 +1,418 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go-to-do-app/to-do-lib/datastores"
+	"go-to-do-app/to-do-lib/models"
+)
+
+// ANSI escape sequences drawing the interface
+const (
+	ansiAltScreen  = "\x1b[?1049h"
+	ansiMainScreen = "\x1b[?1049l"
+	ansiHideCursor = "\x1b[?25l"
+	ansiShowCursor = "\x1b[?25h"
+	ansiHome       = "\x1b[H"
+	ansiClearLine  = "\x1b[K"
+	ansiClearToEnd = "\x1b[J"
+	ansiBold       = "\x1b[1m"
+	ansiReverse    = "\x1b[7m"
+	ansiDim        = "\x1b[2m"
+	ansiRed        = "\x1b[31m"
+	ansiReset      = "\x1b[0m"
+)
+
+// tuiRequestTimeout limits each call to the server, so a slow server cannot
+// hang the interface.
+const tuiRequestTimeout = 10 * time.Second
+
+// keys read from the terminal, other than printable characters
+const (
+	keyUp        = "up"
+	keyDown      = "down"
+	keyEnter     = "enter"
+	keyEscape    = "esc"
+	keyBackspace = "backspace"
+	keyCtrlC     = "ctrl-c"
+)
+
+// tui is the state of the terminal interface.
+type tui struct {
+	env    *cmdEnv
+	items  []models.ToDo
+	cursor int
+	// offset is the index of the first item on screen
+	offset int
+	// filter is the priority shown, or "" for every item
+	filter models.Priority
+	// editing is set while the title of the selected item is edited
+	editing bool
+	input   []rune
+	status  string
+	failed  bool
+}
+
+// parseKeys splits the bytes read from the terminal into keys.
+func parseKeys(buf []byte) []string {
+	var keys []string
+	for len(buf) > 0 {
+		switch {
+		case buf[0] == 0x1b && len(buf) >= 3 && buf[1] == '[':
+			switch buf[2] {
+			case 'A':
+				keys = append(keys, keyUp)
+			case 'B':
+				keys = append(keys, keyDown)
+			}
+			buf = buf[3:]
+			continue
+		case buf[0] == 0x1b:
+			keys = append(keys, keyEscape)
+		case buf[0] == '\r' || buf[0] == '\n':
+			keys = append(keys, keyEnter)
+		case buf[0] == 0x7f || buf[0] == 0x08:
+			keys = append(keys, keyBackspace)
+		case buf[0] == 0x03:
+			keys = append(keys, keyCtrlC)
+		case buf[0] >= 0x20:
+			r, size := utf8.DecodeRune(buf)
+			keys = append(keys, string(r))
+			buf = buf[size:]
+			continue
+		}
+		buf = buf[1:]
+	}
+	return keys
+}
+
+// readKeys sends the keys pressed on in to keys until reading fails.
+func readKeys(in *os.File, keys chan<- string) {
+	buf := make([]byte, 64)
+	for {
+		n, err := in.Read(buf)
+		if err != nil {
+			close(keys)
+			return
+		}
+		for _, key := range parseKeys(buf[:n]) {
+			keys <- key
+		}
+	}
+}
+
+func tuiFlags(fs *flag.FlagSet, opts *options) {
+	fs.DurationVar(&opts.refresh, "refresh", 5*time.Second, "how often to fetch the items again, 0 to only refresh with r")
+}
+
+// cliTUI runs the full-screen interface until it is quit.
+func cliTUI(ctx context.Context, env *cmdEnv) error {
+	if err := requireV2(env, "tui"); err != nil {
+		return err
+	}
+	in, out := os.Stdin, os.Stdout
+	restore, err := makeRaw(int(in.Fd()))
+	if err != nil {
+		return usagef("tui needs an interactive terminal: %v", err)
+	}
+	defer restore()
+	fmt.Fprint(out, ansiAltScreen+ansiHideCursor)
+	defer fmt.Fprint(out, ansiShowCursor+ansiMainScreen)
+
+	// the notice of going offline would garble the screen, the header shows it
+	env.errOut = io.Discard
+	t := &tui{env: env}
+	t.refresh(ctx)
+	keys := make(chan string)
+	go readKeys(in, keys)
+	var tick <-chan time.Time
+	if env.opts.refresh > 0 {
+		ticker := time.NewTicker(env.opts.refresh)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		width, height, err := terminalSize(int(out.Fd()))
+		if err != nil || width <= 0 || height <= 0 {
+			width, height = 80, 24
+		}
+		fmt.Fprint(out, t.view(width, height))
+		select {
+		case key, ok := <-keys:
+			if !ok || t.update(ctx, key) {
+				return nil
+			}
+		case <-tick:
+			if !t.editing {
+				t.refresh(ctx)
+			}
+		}
+	}
+}
+
+// visible returns the items passing the priority filter.
+func (t *tui) visible() []models.ToDo {
+	if t.filter == "" {
+		return t.items
+	}
+	var items []models.ToDo
+	for _, item := range t.items {
+		if item.Priority == t.filter {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func (t *tui) selected() (models.ToDo, bool) {
+	items := t.visible()
+	if t.cursor < 0 || t.cursor >= len(items) {
+		return models.ToDo{}, false
+	}
+	return items[t.cursor], true
+}
+
+func (t *tui) setStatus(err error, format string, a ...any) {
+	t.failed = err != nil
+	if err != nil {
+		t.status = err.Error()
+		return
+	}
+	t.status = fmt.Sprintf(format, a...)
+}
+
+// refresh fetches the items again, keeping the same item selected.
+func (t *tui) refresh(ctx context.Context) {
+	current, hasCurrent := t.selected()
+	ctx, cancel := context.WithTimeout(ctx, tuiRequestTimeout)
+	defer cancel()
+	// try the server again, in case it is back
+	t.env.offline = t.env.opts.offline
+	items, err := t.env.listItems(ctx, datastores.OrderByTitle)
+	if err != nil {
+		t.setStatus(err, "")
+		return
+	}
+	t.items = items
+	if t.failed {
+		t.setStatus(nil, "")
+	}
+	t.cursor = min(t.cursor, max(len(t.visible())-1, 0))
+	if hasCurrent {
+		t.selectItem(current)
+	}
+}
+
+func (t *tui) selectItem(item models.ToDo) {
+	for i, visible := range t.visible() {
+		if visible.Id == item.Id {
+			t.cursor = i
+			return
+		}
+	}
+}
+
+// save puts a changed item, replacing it in the list.
+func (t *tui) save(ctx context.Context, item models.ToDo, done string) {
+	ctx, cancel := context.WithTimeout(ctx, tuiRequestTimeout)
+	defer cancel()
+	updated, err := t.env.updateItem(ctx, item)
+	if err != nil {
+		t.setStatus(err, "")
+		return
+	}
+	for i := range t.items {
+		if t.items[i].Id == updated.Id {
+			t.items[i] = updated
+		}
+	}
+	t.selectItem(updated)
+	t.setStatus(nil, "%s %q", done, updated.Title)
+}
+
+// update handles a key, reporting whether to quit.
+func (t *tui) update(ctx context.Context, key string) bool {
+	if key == keyCtrlC {
+		return true
+	}
+	if t.editing {
+		t.updateInput(ctx, key)
+		return false
+	}
+	item, hasItem := t.selected()
+	switch key {
+	case "q", keyEscape:
+		return true
+	case keyUp, "k":
+		t.cursor = max(t.cursor-1, 0)
+	case keyDown, "j":
+		t.cursor = min(t.cursor+1, max(len(t.visible())-1, 0))
+	case " ", "x":
+		if hasItem {
+			item.Complete = !item.Complete
+			t.save(ctx, item, "Updated")
+		}
+	case "e", keyEnter:
+		if hasItem {
+			t.editing = true
+			t.input = []rune(item.Title)
+		}
+	case "p":
+		if hasItem {
+			item.Priority = nextPriority(item.Priority)
+			t.save(ctx, item, "Updated")
+		}
+	case "f":
+		t.filter = nextFilter(t.filter)
+		t.cursor = 0
+		if hasItem {
+			t.selectItem(item)
+		}
+	case "r":
+		t.refresh(ctx)
+		if !t.failed {
+			t.setStatus(nil, "Refreshed at %s", time.Now().Format(time.TimeOnly))
+		}
+	}
+	return false
+}
+
+// updateInput edits the title of the selected item.
+func (t *tui) updateInput(ctx context.Context, key string) {
+	switch key {
+	case keyEscape:
+		t.editing = false
+	case keyEnter:
+		t.editing = false
+		item, hasItem := t.selected()
+		title := strings.TrimSpace(string(t.input))
+		if hasItem && title != "" && title != item.Title {
+			item.Title = title
+			t.save(ctx, item, "Renamed")
+		}
+	case keyBackspace:
+		if len(t.input) > 0 {
+			t.input = t.input[:len(t.input)-1]
+		}
+	case keyUp, keyDown:
+	default:
+		t.input = append(t.input, []rune(key)...)
+	}
+}
+
+// priorityIndex is the place of p among the priority levels, which the
+// priority key & the filter cycle through.
+func priorityIndex(p models.Priority) int {
+	for i, level := range models.PriorityLevels() {
+		if strings.EqualFold(string(level.Name), string(p)) {
+			return i
+		}
+	}
+	return 0
+}
+
+func nextPriority(p models.Priority) models.Priority {
+	levels := models.PriorityLevels()
+	return levels[(priorityIndex(p)+1)%len(levels)].Name
+}
+
+// nextFilter cycles from every item through each priority.
+func nextFilter(filter models.Priority) models.Priority {
+	levels := models.PriorityLevels()
+	if filter == "" {
+		return levels[0].Name
+	}
+	i := priorityIndex(filter) + 1
+	if i == len(levels) {
+		return ""
+	}
+	return levels[i].Name
+}
+
+// fit cuts or pads s to width columns.
+func fit(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) > width {
+		if width <= 1 {
+			return string(runes[:max(width, 0)])
+		}
+		return string(runes[:width-1]) + "…"
+	}
+	return s + strings.Repeat(" ", width-len(runes))
+}
+
+// view draws the whole screen.
+func (t *tui) view(width, height int) string {
+	var b strings.Builder
+	line := func(style, text string) {
+		b.WriteString(style + fit(text, width) + ansiReset + ansiClearLine + "\r\n")
+	}
+	b.WriteString(ansiHome)
+
+	filter := "All"
+	if t.filter != "" {
+		filter = string(t.filter)
+	}
+	items := t.visible()
+	p := t.env.opts.profile
+	server := p.URL
+	if t.env.offline {
+		server += " (offline)"
+	}
+	line(ansiBold, fmt.Sprintf(" todo · %s @ %s · priority: %s · %d of %d items", p.UserId, server, filter, len(items), len(t.items)))
+	line(ansiDim, fmt.Sprintf(" %-4s  %-8s  %s", "DONE", "PRIORITY", "TITLE"))
+
+	// the header & footer take two lines each
+	rows := max(height-4, 1)
+	if t.cursor < t.offset {
+		t.offset = t.cursor
+	}
+	if t.cursor >= t.offset+rows {
+		t.offset = t.cursor - rows + 1
+	}
+	t.offset = min(t.offset, max(len(items)-rows, 0))
+	for i := t.offset; i < t.offset+rows; i++ {
+		if i >= len(items) {
+			if len(items) == 0 && i == 0 {
+				line(ansiDim, " No items")
+				continue
+			}
+			line("", "")
+			continue
+		}
+		item := items[i]
+		done := "[ ]"
+		if item.Complete {
+			done = "[x]"
+		}
+		title := item.Title
+		if t.editing && i == t.cursor {
+			title = string(t.input) + "▏"
+		}
+		style := ""
+		if i == t.cursor {
+			style = ansiReverse
+		}
+		line(style, fmt.Sprintf(" %-4s  %-8s  %s", done, item.Priority, title))
+	}
+
+	if t.failed {
+		line(ansiRed, " "+t.status)
+	} else {
+		line("", " "+t.status)
+	}
+	if t.editing {
+		b.WriteString(ansiDim + fit(" enter save · esc cancel", width) + ansiReset + ansiClearLine)
+	} else {
+		b.WriteString(ansiDim + fit(" ↑/↓ move · space done · e edit title · p priority · f filter · r refresh · q quit", width) + ansiReset + ansiClearLine)
+	}
+	b.WriteString(ansiClearToEnd)
+	return b.String()
+}
@@ -3,21 +3,45 @@ package apiclient
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	todoerrors "go-to-do-app/to-do-lib/errors"
 	"go-to-do-app/to-do-lib/models"
 
 	"github.com/google/uuid"
 )
 
 type APIClient struct {
-	BaseURL    string
+	BaseURL string
+	// Token, when set, is sent as a bearer token with every request.
+	Token string
+	// UserId, when set, is sent as the X-User-Id header, naming the user
+	// making every request.
+	UserId     string
 	httpClient *http.Client
 }
 
+// do sends req with the client's credentials.
+func (c *APIClient) do(req *http.Request) (*http.Response, error) {
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	if c.UserId != "" {
+		req.Header.Set("X-User-Id", c.UserId)
+	}
+	return c.httpClient.Do(req)
+}
+
 func (c *APIClient) Req(
 	ctx context.Context, m string, args map[string]string) (models.ToDo, error) {
 
@@ -31,48 +55,254 @@ func (c *APIClient) Req(
 	itemid := args["id"]
 	version := args["version"]
 	title := args["title"]
-	priority := args["priority"]
+	description := args["description"]
+	priority := models.Priority(args["priority"])
 	complete := args["complete"] == "true"
+	tags, due, err := itemExtras(args)
+	if err != nil {
+		return models.ToDo{}, err
+	}
 
-	if m == http.MethodGet {
-		apiURL = fmt.Sprintf("http://localhost:8081/%s/todo?user_id=%s&id=%s",
-			version, userid, itemid)
+	if m == http.MethodGet || m == http.MethodDelete {
+		query := url.Values{"user_id": {userid}, "id": {itemid}}
+		apiURL = fmt.Sprintf("%s%s/todo?%s", c.BaseURL, version, query.Encode())
 	}
 	if m == http.MethodPut {
-		apiURL = fmt.Sprintf("http://localhost:8081/%s/todo", args["version"])
-		itemIn, err = models.NewToDo(&userid, &itemid, &title, &priority, &complete)
-		if err != nil {
-			return models.ToDo{}, err
+		apiURL = fmt.Sprintf("%s%s/todo", c.BaseURL, args["version"])
+		itemIn = models.ToDo{UserId: userid, Title: title, Description: description, Priority: priority, Complete: complete, Tags: tags, Due: due}
+		// an update made from a copy of the item carries its revision, so
+		// the server rejects it if the item has changed since
+		if revision, err := strconv.ParseInt(args["revision"], 10, 64); err == nil {
+			itemIn.Revision = revision
 		}
-		buffer, err = json.Marshal(itemIn)
+		// the id may be a short id, which the server resolves
+		buffer, err = json.Marshal(models.ToDoRef{ToDo: itemIn, Id: itemid})
 		if err != nil {
 			return models.ToDo{}, err
 		}
 	}
 	if m == http.MethodPost {
-		apiURL = fmt.Sprintf("http://localhost:8081/%s/todo", args["version"])
-		itemIn = models.ToDo{Id: uuid.Max, UserId: userid, Title: title, Priority: priority, Complete: complete}
+		apiURL = fmt.Sprintf("%s%s/todo", c.BaseURL, args["version"])
+		itemIn = models.ToDo{Id: uuid.Max, UserId: userid, Title: title, Description: description, Priority: priority, Complete: complete, Tags: tags, Due: due}
 		buffer, err = json.Marshal(itemIn)
 		if err != nil {
 			return models.ToDo{}, err
 		}
 	}
-	req, err = http.NewRequest(m, apiURL, bytes.NewBuffer(buffer))
+	req, err = http.NewRequestWithContext(ctx, m, apiURL, bytes.NewBuffer(buffer))
+	if err != nil {
+		return models.ToDo{}, err
+	}
+	// a request sent again with the same key is only applied once
+	if key := args["idempotency-key"]; key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
 	var item models.ToDo
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return models.ToDo{}, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != 200 && resp.StatusCode != 201 {
+		return models.ToDo{}, responseError(resp)
+	}
 	if err := json.NewDecoder(resp.Body).Decode(&item); err != nil {
 		return models.ToDo{}, err
 	}
-	if resp.StatusCode != 200 && resp.StatusCode != 201 {
-		return models.ToDo{}, errors.New(fmt.Sprintf("Request failed with %d", resp.StatusCode))
+	return item, nil
+}
+
+// itemExtras reads the "tags" arg, a comma separated list, & the "due" arg, a
+// date as 2006-01-02 or an RFC 3339 time, of the v2 fields of an item.
+func itemExtras(args map[string]string) ([]string, *time.Time, error) {
+	var tags []string
+	for _, tag := range strings.Split(args["tags"], ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	if args["due"] == "" {
+		return tags, nil, nil
+	}
+	due, err := time.ParseInLocation(time.DateOnly, args["due"], time.Local)
+	if err != nil {
+		if due, err = time.Parse(time.RFC3339, args["due"]); err != nil {
+			return nil, nil, fmt.Errorf("invalid due date %q, use 2006-01-02", args["due"])
+		}
+	}
+	return tags, &due, nil
+}
+
+// QuickAdd adds the item described by a line of text, args["text"], for
+// args["user-id"], reading relative due dates in the IANA time zone
+// args["time-zone"]. Quick add is only available in v2.
+func (c *APIClient) QuickAdd(ctx context.Context, args map[string]string) (models.ToDo, error) {
+	body, err := json.Marshal(map[string]string{"user_id": args["user-id"], "text": args["text"], "time_zone": args["time-zone"]})
+	if err != nil {
+		return models.ToDo{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"v2/todo:quickadd", bytes.NewReader(body))
+	if err != nil {
+		return models.ToDo{}, err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return models.ToDo{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return models.ToDo{}, responseError(resp)
+	}
+	var item models.ToDo
+	if err := json.NewDecoder(resp.Body).Decode(&item); err != nil {
+		return models.ToDo{}, err
 	}
 	return item, nil
 }
 
+// History fetches the recorded changes to the item identified by the "user-id"
+// & "id" args, oldest first.
+func (c *APIClient) History(ctx context.Context, args map[string]string) ([]models.HistoryEntry, error) {
+	query := url.Values{"user_id": {args["user-id"]}, "id": {args["id"]}}
+	apiURL := fmt.Sprintf("%s%s/todo/history?%s", c.BaseURL, args["version"], query.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp)
+	}
+	var entries []models.HistoryEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Search finds the items of args["user-id"] matching args["query"], best match
+// first. Search is only available in v2.
+func (c *APIClient) Search(ctx context.Context, args map[string]string) ([]models.ToDo, error) {
+	query := url.Values{"user_id": {args["user-id"]}, "q": {args["query"]}}
+	apiURL := fmt.Sprintf("%sv2/todos/search?%s", c.BaseURL, query.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp)
+	}
+	var items []models.ToDo
+	if err := json.NewDecoder(resp.Body).Decode(&items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// List fetches the items of args["user-id"] that are not in the trash, in the
+// order args["sort"], by title when empty. Listing is only available in v2.
+func (c *APIClient) List(ctx context.Context, args map[string]string) ([]models.ToDo, error) {
+	query := url.Values{"user_id": {args["user-id"]}}
+	if args["sort"] != "" {
+		query.Set("sort", args["sort"])
+	}
+	apiURL := fmt.Sprintf("%sv2/todos?%s", c.BaseURL, query.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp)
+	}
+	var items []models.ToDo
+	if err := json.NewDecoder(resp.Body).Decode(&items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// responseError returns the problem+json body of a failed response as a
+// *todoerrors.Problem, falling back to one built from the status code.
+func responseError(resp *http.Response) error {
+	problem := todoerrors.NewProblem(resp.StatusCode, "")
+	if err := json.NewDecoder(resp.Body).Decode(problem); err != nil || problem.Status == 0 {
+		return todoerrors.NewProblem(resp.StatusCode, fmt.Sprintf("Request failed with %d", resp.StatusCode))
+	}
+	return problem
+}
+
 func NewAPIClient(baseURL string) APIClient {
 	return APIClient{BaseURL: baseURL, httpClient: &http.Client{}}
 }
+
+// NewHandlerAPIClient returns a client whose requests are served by handler
+// in-process, such as a server calling its own api.
+func NewHandlerAPIClient(handler http.Handler) APIClient {
+	return APIClient{BaseURL: "http://in-process/", httpClient: &http.Client{Transport: handlerTransport{handler}}}
+}
+
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	t.handler.ServeHTTP(rec, req)
+	return rec.Result(), nil
+}
+
+// TLSOptions configures how an APIClient connects to an https server.
+type TLSOptions struct {
+	// CAFile is a PEM file of certificates trusted in addition to the
+	// system roots, such as a private CA or a self-signed server.
+	CAFile string
+	// CertFile & KeyFile are the certificate presented to servers that use
+	// mutual TLS.
+	CertFile string
+	KeyFile  string
+	// ServerName overrides the host name the server certificate is
+	// verified against.
+	ServerName string
+}
+
+// NewTLSAPIClient returns a client for an https server.
+func NewTLSAPIClient(baseURL string, opts TLSOptions) (APIClient, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: opts.ServerName}
+	if opts.CAFile != "" {
+		pem, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return APIClient{}, err
+		}
+		if config.RootCAs, err = x509.SystemCertPool(); err != nil {
+			config.RootCAs = x509.NewCertPool()
+		}
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return APIClient{}, fmt.Errorf("no certificates found in %s", opts.CAFile)
+		}
+	}
+	if opts.CertFile != "" || opts.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return APIClient{}, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	transport.ForceAttemptHTTP2 = true
+	return APIClient{BaseURL: baseURL, httpClient: &http.Client{Transport: transport}}, nil
+}
@@ -0,0 +1,104 @@
+package blobs_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"go-to-do-app/to-do-lib/blobs"
+	todoerrors "go-to-do-app/to-do-lib/errors"
+)
+
+func testBlobStore(t *testing.T, store blobs.BlobStore) {
+	ctx := context.Background()
+	contents := "hello attachment"
+	if err := store.Put(ctx, "blob1", strings.NewReader(contents), int64(len(contents)), "text/plain"); err != nil {
+		t.Fatalf("Expected put to succeed, Got: %v", err)
+	}
+	r, err := store.Get(ctx, "blob1")
+	if err != nil {
+		t.Fatalf("Expected get to succeed, Got: %v", err)
+	}
+	actual, _ := io.ReadAll(r)
+	r.Close()
+	if string(actual) != contents {
+		t.Errorf("Expected: %+v, Got: %+v", contents, string(actual))
+	}
+
+	if err := store.Delete(ctx, "blob1"); err != nil {
+		t.Errorf("Expected delete to succeed, Got: %v", err)
+	}
+	var notFound *todoerrors.NotFoundError
+	if _, err := store.Get(ctx, "blob1"); !errors.As(err, &notFound) {
+		t.Errorf("Expected deleted blob to be not found, Got: %v", err)
+	}
+	if err := store.Put(ctx, "../escape", strings.NewReader(""), 0, "text/plain"); err == nil {
+		t.Errorf("Expected key with a path separator to be rejected")
+	}
+}
+
+func TestFSBlobStore(t *testing.T) {
+	store, err := blobs.NewFSBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testBlobStore(t, store)
+}
+
+// fakeS3 is a local stand-in for an S3 bucket that checks requests are signed.
+type fakeS3 struct {
+	mut     sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=test-key/") ||
+		!strings.Contains(auth, "/eu-west-1/s3/aws4_request") ||
+		r.Header.Get("X-Amz-Date") == "" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, "/attachments/") {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = body
+	case http.MethodGet:
+		body, exists := f.objects[r.URL.Path]
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(body)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestS3BlobStore(t *testing.T) {
+	srv := httptest.NewServer(&fakeS3{objects: make(map[string][]byte)})
+	defer srv.Close()
+	store, err := blobs.NewS3BlobStore(blobs.S3Options{
+		Endpoint:  srv.URL,
+		Bucket:    "attachments",
+		Region:    "eu-west-1",
+		AccessKey: "test-key",
+		SecretKey: "test-secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testBlobStore(t, store)
+}
@@ -0,0 +1,283 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix starts the name of every environment variable read by FromEnv.
+const EnvPrefix = "TODO_"
+
+// Redacted replaces the value of secret settings in Dump.
+const Redacted = "*****"
+
+// field is a setting of a config struct, described by its tags:
+//
+//	config:"database.user"  the dotted key in files, also naming the env var
+//	flag:"user"             the command line flag, if any
+//	usage:"..."             the flag's help text
+//	secret:"true"           the value is redacted by Dump
+type field struct {
+	key    string
+	flag   string
+	usage  string
+	secret bool
+	value  reflect.Value
+}
+
+func fields(dst any) ([]field, error) {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("config: expected a pointer to a struct, got %T", dst)
+	}
+	var out []field
+	collectFields(v.Elem(), "", &out)
+	return out, nil
+}
+
+// collectFields walks nested structs, using their config tag as a prefix.
+func collectFields(v reflect.Value, prefix string, out *[]field) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		key, ok := sf.Tag.Lookup("config")
+		if !ok || !sf.IsExported() {
+			continue
+		}
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if sf.Type.Kind() == reflect.Struct && sf.Type != reflect.TypeOf(time.Time{}) {
+			collectFields(v.Field(i), key, out)
+			continue
+		}
+		*out = append(*out, field{
+			key:    key,
+			flag:   sf.Tag.Get("flag"),
+			usage:  sf.Tag.Get("usage"),
+			secret: sf.Tag.Get("secret") == "true",
+			value:  v.Field(i),
+		})
+	}
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func setValue(v reflect.Value, raw string) error {
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	case v.Kind() == reflect.String:
+		v.SetString(raw)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Int || v.Kind() == reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case v.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func formatValue(v reflect.Value) string {
+	switch {
+	case v.Type() == durationType:
+		return time.Duration(v.Int()).String()
+	case v.Kind() == reflect.Slice:
+		return strings.Join(v.Interface().([]string), ",")
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+// Apply sets the fields of dst from each layer in turn, so later layers take
+// precedence. Keys that match no field are an error, catching typos in files.
+func Apply(dst any, layers ...Values) error {
+	all, err := fields(dst)
+	if err != nil {
+		return err
+	}
+	byKey := make(map[string]field, len(all))
+	for _, f := range all {
+		byKey[f.key] = f
+	}
+	for _, layer := range layers {
+		for _, key := range layer.keys() {
+			f, exists := byKey[key]
+			if !exists {
+				return fmt.Errorf("unknown setting %s", key)
+			}
+			if err := setValue(f.value, layer[key]); err != nil {
+				return fmt.Errorf("invalid value %q for %s: %w", layer[key], key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// EnvName is the environment variable for a key, e.g. TODO_DATABASE_USER.
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+}
+
+// FromEnv reads the environment variables for the settings of dst.
+func FromEnv(dst any) (Values, error) {
+	all, err := fields(dst)
+	if err != nil {
+		return nil, err
+	}
+	values := Values{}
+	for _, f := range all {
+		if value, set := os.LookupEnv(EnvName(f.key)); set {
+			values[f.key] = value
+		}
+	}
+	return values, nil
+}
+
+// Dump returns the current settings of dst, optionally with secrets redacted.
+func Dump(dst any, redact bool) (Values, error) {
+	all, err := fields(dst)
+	if err != nil {
+		return nil, err
+	}
+	values := Values{}
+	for _, f := range all {
+		value := formatValue(f.value)
+		if redact && f.secret && value != "" {
+			value = Redacted
+		}
+		values[f.key] = value
+	}
+	return values, nil
+}
+
+// WriteYAML writes values as nested YAML maps, which ParseYAML reads back.
+func WriteYAML(w io.Writer, values Values) error {
+	var previous []string
+	for _, key := range values.keys() {
+		parts := strings.Split(key, ".")
+		common := 0
+		for common < len(previous)-1 && common < len(parts)-1 && previous[common] == parts[common] {
+			common++
+		}
+		for i := common; i < len(parts)-1; i++ {
+			if _, err := fmt.Fprintf(w, "%s%s:\n", strings.Repeat("  ", i), parts[i]); err != nil {
+				return err
+			}
+		}
+		last := len(parts) - 1
+		if _, err := fmt.Fprintf(w, "%s%s: %s\n", strings.Repeat("  ", last), parts[last], yamlQuote(values[key])); err != nil {
+			return err
+		}
+		previous = parts
+	}
+	return nil
+}
+
+// Flags registers the command line flags of a config struct. Only flags that
+// were given on the command line are reported, so that unset flags do not
+// override files or the environment with their defaults.
+type Flags struct {
+	fs   *flag.FlagSet
+	keys map[string]string
+}
+
+type flagValue struct {
+	value   string
+	scratch reflect.Value
+	isBool  bool
+}
+
+func (f *flagValue) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.value
+}
+
+// Set checks the value parses as the field's type, reporting bad flags when
+// they are parsed rather than when they are applied.
+func (f *flagValue) Set(raw string) error {
+	if err := setValue(f.scratch, raw); err != nil {
+		return err
+	}
+	f.value = raw
+	return nil
+}
+
+func (f *flagValue) IsBoolFlag() bool {
+	return f.isBool
+}
+
+// NewFlags defines a flag on fs for each setting of dst with a flag tag,
+// using the current value of the field as the default.
+func NewFlags(fs *flag.FlagSet, dst any) (*Flags, error) {
+	all, err := fields(dst)
+	if err != nil {
+		return nil, err
+	}
+	flags := &Flags{fs: fs, keys: map[string]string{}}
+	for _, f := range all {
+		if f.flag == "" {
+			continue
+		}
+		value := &flagValue{
+			value:   formatValue(f.value),
+			scratch: reflect.New(f.value.Type()).Elem(),
+			isBool:  f.value.Kind() == reflect.Bool,
+		}
+		if f.secret {
+			// keep secrets out of the usage message
+			value.value = ""
+		}
+		usage := fmt.Sprintf("%s (env %s)", f.usage, EnvName(f.key))
+		fs.Var(value, f.flag, usage)
+		flags.keys[f.flag] = f.key
+	}
+	return flags, nil
+}
+
+// Values returns the settings given on the command line.
+func (f *Flags) Values() Values {
+	values := Values{}
+	f.fs.Visit(func(fl *flag.Flag) {
+		if key, exists := f.keys[fl.Name]; exists {
+			values[key] = fl.Value.String()
+		}
+	})
+	return values
+}
@@ -0,0 +1,166 @@
+// Package config loads settings from YAML or TOML files, environment variables
+// and command line flags into a struct. Every layer is flattened to Values
+// keyed by dotted names, such as "database.user", so later layers can override
+// single settings of earlier ones.
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Values are settings keyed by dotted names. Lists are comma separated.
+type Values map[string]string
+
+// ReadFile parses a .yaml, .yml or .toml file.
+func ReadFile(path string) (Values, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var values Values
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		values, err = ParseYAML(data)
+	case ".toml":
+		values, err = ParseTOML(data)
+	default:
+		return nil, fmt.Errorf("%s: config files must be .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return values, nil
+}
+
+func (v Values) keys() []string {
+	keys := make([]string, 0, len(v))
+	for key := range v {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// unquote reads a scalar, removing the quotes around a string. Both file
+// formats share double quoted strings with backslash escapes and single
+// quoted strings taken literally.
+func unquote(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if len(raw) < 2 {
+		return raw, nil
+	}
+	switch raw[0] {
+	case '"':
+		if raw[len(raw)-1] != '"' {
+			return "", fmt.Errorf("unterminated string %s", raw)
+		}
+		var out strings.Builder
+		for i := 1; i < len(raw)-1; i++ {
+			if raw[i] != '\\' || i+1 == len(raw)-1 {
+				out.WriteByte(raw[i])
+				continue
+			}
+			i++
+			switch raw[i] {
+			case 'n':
+				out.WriteByte('\n')
+			case 't':
+				out.WriteByte('\t')
+			default:
+				out.WriteByte(raw[i])
+			}
+		}
+		return out.String(), nil
+	case '\'':
+		if raw[len(raw)-1] != '\'' {
+			return "", fmt.Errorf("unterminated string %s", raw)
+		}
+		return strings.ReplaceAll(raw[1:len(raw)-1], "''", "'"), nil
+	}
+	return raw, nil
+}
+
+// stripComment removes a # comment that is not inside a quoted string.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0 && c == '\\' && quote == '"':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == 0 && c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// listItem checks an item of a list. Lists are kept as comma separated
+// values, as they are given in flags and the environment, so an item cannot
+// hold a comma.
+func listItem(item string) (string, error) {
+	if strings.Contains(item, ",") {
+		return "", fmt.Errorf("list item %q cannot contain a comma", item)
+	}
+	return item, nil
+}
+
+// splitList splits the items of an inline list at the commas outside quoted
+// strings.
+func splitList(inner string) []string {
+	var items []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(inner); i++ {
+		switch c := inner[i]; {
+		case quote != 0 && c == '\\' && quote == '"':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == 0 && c == ',':
+			items = append(items, inner[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, inner[start:])
+}
+
+// inlineList reads a [a, b, "c"] list as a comma separated value.
+func inlineList(raw string) (string, error) {
+	inner := strings.TrimSpace(raw[1 : len(raw)-1])
+	if inner == "" {
+		return "", nil
+	}
+	items := splitList(inner)
+	for i, item := range items {
+		value, err := unquote(item)
+		if err == nil {
+			value, err = listItem(value)
+		}
+		if err != nil {
+			return "", err
+		}
+		items[i] = value
+	}
+	return strings.Join(items, ","), nil
+}
+
+func scalar(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if strings.HasPrefix(raw, "[") {
+		if !strings.HasSuffix(raw, "]") {
+			return "", fmt.Errorf("unterminated list %s", raw)
+		}
+		return inlineList(raw)
+	}
+	return unquote(raw)
+}
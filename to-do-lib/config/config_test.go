@@ -0,0 +1,187 @@
+package config_test
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go-to-do-app/to-do-lib/config"
+)
+
+type testConfig struct {
+	Mode     string        `config:"mode" flag:"mode" usage:"store mode"`
+	Verbose  bool          `config:"verbose" flag:"verbose" usage:"verbose output"`
+	Window   time.Duration `config:"window" flag:"window" usage:"window"`
+	Rate     float64       `config:"limits.rate" flag:"rate" usage:"rate"`
+	Tags     []string      `config:"tags"`
+	Database struct {
+		User     string `config:"user" flag:"user" usage:"db user"`
+		Password string `config:"password" flag:"password" usage:"db password" secret:"true"`
+		Port     int    `config:"port"`
+	} `config:"database"`
+}
+
+func TestParseYAML(t *testing.T) {
+	data := []byte(`
+# a comment
+mode: json-store   # trailing comment
+window: 10m
+tags: [a, "b c"]
+database:
+  user: 'o''brien'
+  password: "p#ss"
+  port: 5432
+limits:
+  rate: 2.5
+extra:
+  - one
+  - "two"
+`)
+	values, err := config.ParseYAML(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := config.Values{
+		"mode":              "json-store",
+		"window":            "10m",
+		"tags":              "a,b c",
+		"database.user":     "o'brien",
+		"database.password": "p#ss",
+		"database.port":     "5432",
+		"limits.rate":       "2.5",
+		"extra":             "one,two",
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected: %+v, Got: %+v", expected, values)
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	for _, data := range []string{"- orphan", "key", "key:value", `key: "open`, `tags: ["a,b", c]`, "tags:\n  - \"a,b\""} {
+		if _, err := config.ParseYAML([]byte(data)); err == nil {
+			t.Errorf("Expected an error parsing %q", data)
+		}
+	}
+}
+
+func TestParseTOML(t *testing.T) {
+	data := []byte(`
+mode = "json-store" # comment
+tags = ["a", 'b']
+limits.rate = 2.5
+
+[database]
+user = 'C:\todo'
+port = 5432
+`)
+	values, err := config.ParseTOML(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := config.Values{
+		"mode":          "json-store",
+		"tags":          "a,b",
+		"limits.rate":   "2.5",
+		"database.user": `C:\todo`,
+		"database.port": "5432",
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected: %+v, Got: %+v", expected, values)
+	}
+	if _, err := config.ParseTOML([]byte("a = 1\na = 2")); err == nil {
+		t.Errorf("Expected duplicate keys to be an error")
+	}
+	_, err = config.ParseTOML([]byte(`tags = ["a, b", "c"]`))
+	if err == nil || !strings.Contains(err.Error(), "cannot contain a comma") {
+		t.Errorf("Expected a comma inside a list item to be an error, Got: %v", err)
+	}
+}
+
+func TestApplyPrecedence(t *testing.T) {
+	cfg := testConfig{Mode: "in-mem"}
+	file := config.Values{"mode": "json-store", "database.user": "file", "window": "1h"}
+	env := config.Values{"database.user": "env"}
+	flags := config.Values{"mode": "postgres"}
+	if err := config.Apply(&cfg, file, env, flags); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Mode != "postgres" || cfg.Database.User != "env" || cfg.Window != time.Hour {
+		t.Errorf("Expected: postgres, env, 1h, Got: %s, %s, %v", cfg.Mode, cfg.Database.User, cfg.Window)
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	cfg := testConfig{}
+	if err := config.Apply(&cfg, config.Values{"modee": "x"}); err == nil {
+		t.Errorf("Expected unknown keys to be an error")
+	}
+	if err := config.Apply(&cfg, config.Values{"database.port": "abc"}); err == nil {
+		t.Errorf("Expected invalid numbers to be an error")
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("TODO_DATABASE_USER", "env-user")
+	t.Setenv("TODO_LIMITS_RATE", "3")
+	values, err := config.FromEnv(&testConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := config.Values{"database.user": "env-user", "limits.rate": "3"}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected: %+v, Got: %+v", expected, values)
+	}
+}
+
+func TestFlagsOnlyReportsSetFlags(t *testing.T) {
+	cfg := testConfig{Mode: "in-mem"}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags, err := config.NewFlags(fs, &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Parse([]string{"--verbose", "--user=flag-user"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := config.Values{"verbose": "true", "database.user": "flag-user"}
+	if values := flags.Values(); !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected: %+v, Got: %+v", expected, values)
+	}
+	if err := fs.Parse([]string{"--window=soon"}); err == nil {
+		t.Errorf("Expected invalid flag values to fail parsing")
+	}
+}
+
+func TestDumpRoundTrip(t *testing.T) {
+	cfg := testConfig{Mode: "in-mem", Window: 90 * time.Second, Tags: []string{"a", "b"}}
+	cfg.Database.User = "postgres"
+	cfg.Database.Password = "secret"
+	values, err := config.Dump(&cfg, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values["database.password"] != config.Redacted {
+		t.Errorf("Expected: %s, Got: %s", config.Redacted, values["database.password"])
+	}
+
+	var out bytes.Buffer
+	if err := config.WriteYAML(&out, values); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, out.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	read, err := config.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, values) {
+		t.Errorf("Expected: %+v, Got: %+v\n%s", values, read, out.String())
+	}
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// ParseTOML reads the subset of TOML used for configuration: [tables],
+// dotted keys, quoted and literal strings, numbers, booleans and single line
+// arrays.
+func ParseTOML(data []byte) (Values, error) {
+	values := Values{}
+	table := ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if strings.HasPrefix(line, "[[") || !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: unsupported table header %s", lineNo, line)
+			}
+			name, err := tomlKey(line[1 : len(line)-1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			table = name
+			continue
+		}
+
+		rawKey, raw, found := strings.Cut(line, "=")
+		if !found {
+			return nil, fmt.Errorf("line %d: expected 'key = value'", lineNo)
+		}
+		key, err := tomlKey(rawKey)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		if table != "" {
+			key = table + "." + key
+		}
+		if _, exists := values[key]; exists {
+			return nil, fmt.Errorf("line %d: %s is defined twice", lineNo, key)
+		}
+		value, err := scalar(raw)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// tomlKey normalises a dotted key, which may quote any of its parts.
+func tomlKey(raw string) (string, error) {
+	parts := strings.Split(raw, ".")
+	for i, part := range parts {
+		name, err := unquote(part)
+		if err != nil {
+			return "", err
+		}
+		if name == "" {
+			return "", fmt.Errorf("invalid key %q", strings.TrimSpace(raw))
+		}
+		parts[i] = name
+	}
+	return strings.Join(parts, "."), nil
+}
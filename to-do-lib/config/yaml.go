@@ -0,0 +1,110 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// ParseYAML reads the subset of YAML used for configuration: nested maps of
+// scalars, quoted strings, comments and lists, written either inline or as
+// "- item" lines.
+func ParseYAML(data []byte) (Values, error) {
+	type level struct {
+		indent int
+		prefix string
+	}
+	values := Values{}
+	stack := []level{{indent: -1}}
+	// list is the key of the block list being read, if any
+	var list string
+	var items []string
+	flush := func() {
+		if len(items) > 0 {
+			values[list] = strings.Join(items, ",")
+		}
+		list, items = "", nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimRight(stripComment(scanner.Text()), " \t\r")
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", lineNo)
+		}
+		indent := len(line) - len(trimmed)
+
+		if trimmed == "-" || strings.HasPrefix(trimmed, "- ") {
+			if list == "" {
+				return nil, fmt.Errorf("line %d: list item outside of a list", lineNo)
+			}
+			item, err := unquote(strings.TrimPrefix(trimmed, "-"))
+			if err == nil {
+				item, err = listItem(item)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			items = append(items, item)
+			continue
+		}
+		flush()
+
+		key, raw, found := strings.Cut(trimmed, ":")
+		if !found || (raw != "" && raw[0] != ' ') {
+			return nil, fmt.Errorf("line %d: expected 'key: value'", lineNo)
+		}
+		key, err := unquote(key)
+		if err != nil || key == "" {
+			return nil, fmt.Errorf("line %d: invalid key", lineNo)
+		}
+		for indent <= stack[len(stack)-1].indent {
+			stack = stack[:len(stack)-1]
+		}
+		if parent := stack[len(stack)-1].prefix; parent != "" {
+			key = parent + "." + key
+		}
+
+		if strings.TrimSpace(raw) == "" {
+			// either a nested map or a block list follows
+			stack = append(stack, level{indent: indent, prefix: key})
+			list = key
+			continue
+		}
+		value, err := scalar(raw)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	return values, nil
+}
+
+// yamlNeedsQuotes reports whether a value would be read back differently if
+// it was written without quotes.
+func yamlNeedsQuotes(value string) bool {
+	if value == "" || strings.TrimSpace(value) != value {
+		return true
+	}
+	if strings.ContainsAny(value, "#:\"'\n\t[]{}") {
+		return true
+	}
+	return strings.ContainsAny(value[:1], "-*&!%@`|>?,")
+}
+
+func yamlQuote(value string) string {
+	if !yamlNeedsQuotes(value) {
+		return value
+	}
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`).Replace(value)
+	return `"` + value + `"`
+}
@@ -0,0 +1,140 @@
+package datastores
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"go-to-do-app/to-do-lib/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// AssignmentStore finds items by who they are assigned to and when they are
+// due, whoever owns them. The assignee of an item may edit it as if it was
+// shared with them, see ShareStore.
+type AssignmentStore interface {
+	// ListAssigned returns the items assigned to a user that are not in the
+	// trash, in order
+	ListAssigned(assignee string, order ItemOrder) ([]models.ToDo, error)
+	// ListDue returns the items that are neither complete nor in the trash
+	// and are due by before, soonest first.
+	ListDue(before time.Time) ([]models.ToDo, error)
+	// ClaimReminder records that the item is being reminded of for its due
+	// time, reporting false when it already has been, by this or any other
+	// server on the store.
+	ClaimReminder(itemId uuid.UUID, due time.Time) (bool, error)
+}
+
+func (ds *inMemDatastore) ListAssigned(assignee string, order ItemOrder) ([]models.ToDo, error) {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	items := make([]models.ToDo, 0)
+	for _, user := range ds.Items {
+		for _, item := range user {
+			if item.Assignee == assignee && !item.Deleted() {
+				items = append(items, item)
+			}
+		}
+	}
+	sortItems(items, order)
+	return items, nil
+}
+
+func (ds *inMemDatastore) ListDue(before time.Time) ([]models.ToDo, error) {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	items := make([]models.ToDo, 0)
+	for _, user := range ds.Items {
+		for _, item := range user {
+			if item.Due != nil && !item.Due.After(before) && !item.Complete && !item.Deleted() {
+				items = append(items, item)
+			}
+		}
+	}
+	slices.SortFunc(items, func(a, b models.ToDo) int {
+		return cmp.Or(a.Due.Compare(*b.Due), cmp.Compare(a.Title, b.Title), cmp.Compare(a.Id.String(), b.Id.String()))
+	})
+	return items, nil
+}
+
+func (ds *inMemDatastore) ClaimReminder(itemId uuid.UUID, due time.Time) (bool, error) {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	if reminded, exists := ds.reminders[itemId]; exists && reminded.Equal(due) {
+		return false, nil
+	}
+	ds.reminders[itemId] = due
+	return true, nil
+}
+
+func (ds *JsonDatastore) ClaimReminder(itemId uuid.UUID, due time.Time) (bool, error) {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	claimed, err := ds.inMemDatastore.ClaimReminder(itemId, due)
+	if claimed {
+		ds.save()
+	}
+	return claimed, err
+}
+
+// assignedAccess expects the caller to hold ds.mut
+func (ds *inMemDatastore) assignedAccess(userId string, itemId uuid.UUID) (models.Access, bool) {
+	for owner, items := range ds.Items {
+		if item, exists := items[itemId]; exists && item.Assignee == userId && !item.Deleted() {
+			return models.Access{OwnerId: owner, Role: models.RoleEditor}, true
+		}
+	}
+	return models.Access{}, false
+}
+
+func (p *PGDB) queryItems(query string, args ...any) ([]models.ToDo, error) {
+	rows, err := p.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := make([]models.ToDo, 0)
+	for rows.Next() {
+		item, err := scanItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (p *PGDB) ListAssigned(assignee string, order ItemOrder) ([]models.ToDo, error) {
+	var levels []string
+	if order == OrderByPriority {
+		levels = models.PriorityNames()
+	}
+	return p.queryItems(
+		"SELECT "+pgItemColumns+" FROM items WHERE assignee = $1 AND deleted_at IS NULL "+
+			"ORDER BY array_position($2::text[], priority) DESC NULLS LAST, title, item_id",
+		assignee, pq.Array(levels),
+	)
+}
+
+func (p *PGDB) ListDue(before time.Time) ([]models.ToDo, error) {
+	return p.queryItems(
+		"SELECT "+pgItemColumns+" FROM items WHERE due <= $1 AND NOT complete AND deleted_at IS NULL ORDER BY due, title, item_id",
+		before,
+	)
+}
+
+// ClaimReminder sets the reminded_due of the item only while it differs from
+// the due time, so one server claims each reminder.
+func (p *PGDB) ClaimReminder(itemId uuid.UUID, due time.Time) (bool, error) {
+	res, err := p.db.Exec(
+		"UPDATE items SET reminded_due = due WHERE item_id = $1 AND due = $2 AND reminded_due IS DISTINCT FROM due",
+		itemId, due,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,81 @@
+package datastores_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"go-to-do-app/to-do-lib/datastores"
+	"go-to-do-app/to-do-lib/models"
+)
+
+func testAssignments(t *testing.T, store datastores.DataStore) {
+	now := time.Now().UTC().Truncate(time.Second)
+	soon, later := now.Add(time.Hour), now.Add(48*time.Hour)
+	first := store.AddItem(models.ToDo{Title: "first", Priority: "Low", UserId: "Owner", Assignee: "Assignee", Due: &later})
+	second := store.AddItem(models.ToDo{Title: "second", Priority: "High", UserId: "Other", Assignee: "Assignee", Due: &soon})
+	store.AddItem(models.ToDo{Title: "unassigned", Priority: "Low", UserId: "Owner"})
+
+	items, err := store.ListAssigned("Assignee", datastores.OrderByTitle)
+	if expected := []models.ToDo{first, second}; err != nil || !reflect.DeepEqual(items, expected) {
+		t.Errorf("Expected: %+v, Got: %+v (%v)", expected, items, err)
+	}
+	items, _ = store.ListAssigned("Assignee", datastores.OrderByPriority)
+	if expected := []models.ToDo{second, first}; !reflect.DeepEqual(items, expected) {
+		t.Errorf("Expected: %+v, Got: %+v", expected, items)
+	}
+
+	items, err = store.ListDue(now.Add(72 * time.Hour))
+	if expected := []models.ToDo{second, first}; err != nil || !reflect.DeepEqual(items, expected) {
+		t.Errorf("Expected: %+v, Got: %+v (%v)", expected, items, err)
+	}
+	items, _ = store.ListDue(soon)
+	if expected := []models.ToDo{second}; !reflect.DeepEqual(items, expected) {
+		t.Errorf("Expected: %+v, Got: %+v", expected, items)
+	}
+
+	expected := models.Access{OwnerId: "Other", Role: models.RoleEditor}
+	if actual, err := store.Access("Assignee", second.Id); err != nil || actual != expected {
+		t.Errorf("Expected: %+v, Got: %+v (%v)", expected, actual, err)
+	}
+	if actual, err := store.GetItem("Assignee", second.Id); err != nil || !reflect.DeepEqual(actual, second) {
+		t.Errorf("Expected: %+v, Got: %+v (%v)", second, actual, err)
+	}
+
+	second.Complete = true
+	if _, err := store.UpdateItem(second); err != nil {
+		t.Fatalf("Expected item to be updated, Got: %v", err)
+	}
+	if items, _ := store.ListDue(later); len(items) != 1 || items[0].Id != first.Id {
+		t.Errorf("Expected complete items not to be due, Got: %+v", items)
+	}
+	store.DeleteItem("Owner", first.Id)
+	if items, _ := store.ListAssigned("Assignee", datastores.OrderByTitle); len(items) != 1 || items[0].Id != second.Id {
+		t.Errorf("Expected items in the trash not to be listed, Got: %+v", items)
+	}
+	if _, err := store.Access("Assignee", first.Id); !isNotFound(err) {
+		t.Errorf("Expected no access to items in the trash, Got: %v", err)
+	}
+}
+
+func TestAssignments(t *testing.T) {
+	forEachStore(t, testAssignments)
+}
+
+func TestJSONRemindersPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	store := datastores.NewJsonDatastore(path)
+	due := time.Now().UTC().Truncate(time.Second)
+	item := store.AddItem(models.ToDo{Title: "due", Priority: "Low", UserId: "Owner", Due: &due})
+	if claimed, err := store.ClaimReminder(item.Id, due); !claimed || err != nil {
+		t.Fatalf("Expected the first claim to succeed, Got: %t, %v", claimed, err)
+	}
+	reloaded := datastores.NewJsonDatastore(path)
+	if claimed, _ := reloaded.ClaimReminder(item.Id, due); claimed {
+		t.Error("Expected a reminder to be claimed once across restarts")
+	}
+	if claimed, _ := reloaded.ClaimReminder(item.Id, due.Add(time.Hour)); !claimed {
+		t.Error("Expected a new due time to be reminded of again")
+	}
+}
@@ -0,0 +1,157 @@
+package datastores
+
+import (
+	"slices"
+
+	todoerrors "go-to-do-app/to-do-lib/errors"
+	"go-to-do-app/to-do-lib/models"
+
+	"github.com/google/uuid"
+)
+
+// AttachmentStore keeps the metadata of the files attached to items. Only
+// items that are not in the trash can gain attachments.
+type AttachmentStore interface {
+	AddAttachment(attachment models.Attachment) (models.Attachment, error)
+	// ListAttachments returns an item's attachments, oldest first.
+	ListAttachments(userId string, itemId uuid.UUID) ([]models.Attachment, error)
+	GetAttachment(userId string, itemId uuid.UUID, id uuid.UUID) (models.Attachment, error)
+	DeleteAttachment(userId string, itemId uuid.UUID, id uuid.UUID) (models.Attachment, error)
+}
+
+func (ds *inMemDatastore) AddAttachment(attachment models.Attachment) (models.Attachment, error) {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	if item, exists := ds.Items[attachment.UserId][attachment.ItemId]; !exists || item.Deleted() {
+		return models.Attachment{}, &todoerrors.NotFoundError{Message: "ToDo Not Found"}
+	}
+	ds.attachments[attachment.ItemId] = append(ds.attachments[attachment.ItemId], attachment)
+	return attachment, nil
+}
+
+func (ds *inMemDatastore) ListAttachments(userId string, itemId uuid.UUID) ([]models.Attachment, error) {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	attachments := make([]models.Attachment, 0)
+	for _, attachment := range ds.attachments[itemId] {
+		if attachment.UserId == userId {
+			attachments = append(attachments, attachment)
+		}
+	}
+	return attachments, nil
+}
+
+func (ds *inMemDatastore) GetAttachment(userId string, itemId uuid.UUID, id uuid.UUID) (models.Attachment, error) {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	for _, attachment := range ds.attachments[itemId] {
+		if attachment.UserId == userId && attachment.Id == id {
+			return attachment, nil
+		}
+	}
+	return models.Attachment{}, &todoerrors.NotFoundError{Message: "Attachment Not Found"}
+}
+
+func (ds *inMemDatastore) DeleteAttachment(userId string, itemId uuid.UUID, id uuid.UUID) (models.Attachment, error) {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	for i, attachment := range ds.attachments[itemId] {
+		if attachment.UserId == userId && attachment.Id == id {
+			ds.attachments[itemId] = slices.Delete(ds.attachments[itemId], i, i+1)
+			return attachment, nil
+		}
+	}
+	return models.Attachment{}, &todoerrors.NotFoundError{Message: "Attachment Not Found"}
+}
+
+func (ds *JsonDatastore) AddAttachment(attachment models.Attachment) (models.Attachment, error) {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	attachment, err := ds.inMemDatastore.AddAttachment(attachment)
+	if err != nil {
+		return models.Attachment{}, err
+	}
+	ds.save()
+	return attachment, nil
+}
+
+func (ds *JsonDatastore) DeleteAttachment(userId string, itemId uuid.UUID, id uuid.UUID) (models.Attachment, error) {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	attachment, err := ds.inMemDatastore.DeleteAttachment(userId, itemId, id)
+	if err != nil {
+		return models.Attachment{}, err
+	}
+	ds.save()
+	return attachment, nil
+}
+
+// pgAttachmentColumns is the column list read by scanAttachment
+const pgAttachmentColumns = "attachment_id, user_id, item_id, filename, content_type, size, created_at"
+
+func scanAttachment(row pgScanner) (models.Attachment, error) {
+	var attachment models.Attachment
+	err := row.Scan(
+		&attachment.Id, &attachment.UserId, &attachment.ItemId, &attachment.Filename,
+		&attachment.ContentType, &attachment.Size, &attachment.CreatedAt,
+	)
+	return attachment, err
+}
+
+func (p *PGDB) AddAttachment(attachment models.Attachment) (models.Attachment, error) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	if _, err := pgGetItem(p.db, attachment.UserId, attachment.ItemId); err != nil {
+		return models.Attachment{}, err
+	}
+	if _, err := p.db.Exec(
+		"INSERT INTO attachments ("+pgAttachmentColumns+") VALUES($1, $2, $3, $4, $5, $6, $7)",
+		attachment.Id, attachment.UserId, attachment.ItemId, attachment.Filename,
+		attachment.ContentType, attachment.Size, attachment.CreatedAt,
+	); err != nil {
+		return models.Attachment{}, err
+	}
+	return attachment, nil
+}
+
+func (p *PGDB) ListAttachments(userId string, itemId uuid.UUID) ([]models.Attachment, error) {
+	rows, err := p.db.Query(
+		"SELECT "+pgAttachmentColumns+" FROM attachments WHERE user_id = $1 AND item_id = $2 ORDER BY created_at",
+		userId, itemId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	attachments := make([]models.Attachment, 0)
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments, rows.Err()
+}
+
+func (p *PGDB) GetAttachment(userId string, itemId uuid.UUID, id uuid.UUID) (models.Attachment, error) {
+	attachment, err := scanAttachment(p.db.QueryRow(
+		"SELECT "+pgAttachmentColumns+" FROM attachments WHERE user_id = $1 AND item_id = $2 AND attachment_id = $3",
+		userId, itemId, id,
+	))
+	if err != nil {
+		return models.Attachment{}, &todoerrors.NotFoundError{Message: "Attachment Not Found"}
+	}
+	return attachment, nil
+}
+
+func (p *PGDB) DeleteAttachment(userId string, itemId uuid.UUID, id uuid.UUID) (models.Attachment, error) {
+	attachment, err := scanAttachment(p.db.QueryRow(
+		"DELETE FROM attachments WHERE user_id = $1 AND item_id = $2 AND attachment_id = $3 RETURNING "+pgAttachmentColumns,
+		userId, itemId, id,
+	))
+	if err != nil {
+		return models.Attachment{}, &todoerrors.NotFoundError{Message: "Attachment Not Found"}
+	}
+	return attachment, nil
+}
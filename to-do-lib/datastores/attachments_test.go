@@ -0,0 +1,62 @@
+package datastores_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-to-do-app/to-do-lib/datastores"
+	"go-to-do-app/to-do-lib/models"
+
+	"github.com/google/uuid"
+)
+
+func testAttachments(t *testing.T, store datastores.DataStore) {
+	item := store.AddItem(models.ToDo{Title: "test", Priority: "Low", UserId: "TestToDoUser"})
+	attachment := models.Attachment{
+		Id: uuid.New(), UserId: item.UserId, ItemId: item.Id, Filename: "notes.txt",
+		ContentType: "text/plain", Size: 5, CreatedAt: time.Now().UTC().Round(time.Microsecond),
+	}
+	if _, err := store.AddAttachment(attachment); err != nil {
+		t.Fatalf("Expected attachment to be added, Got: %v", err)
+	}
+	if _, err := store.AddAttachment(models.Attachment{Id: uuid.New(), UserId: item.UserId, ItemId: uuid.New()}); !isNotFound(err) {
+		t.Errorf("Expected attachment to a missing item to fail, Got: %v", err)
+	}
+
+	list, _ := store.ListAttachments(item.UserId, item.Id)
+	if len(list) != 1 || list[0] != attachment {
+		t.Errorf("Expected: %+v, Got: %+v", attachment, list)
+	}
+	if _, err := store.GetAttachment("SomeoneElse", item.Id, attachment.Id); !isNotFound(err) {
+		t.Errorf("Expected other users not to see the attachment, Got: %v", err)
+	}
+	if got, err := store.GetAttachment(item.UserId, item.Id, attachment.Id); err != nil || got != attachment {
+		t.Errorf("Expected: %+v, Got: %+v (%v)", attachment, got, err)
+	}
+
+	if _, err := store.DeleteAttachment(item.UserId, item.Id, attachment.Id); err != nil {
+		t.Errorf("Expected attachment to be deleted, Got: %v", err)
+	}
+	if list, _ := store.ListAttachments(item.UserId, item.Id); len(list) != 0 {
+		t.Errorf("Expected no attachments after delete, Got: %+v", list)
+	}
+}
+
+func TestAttachments(t *testing.T) {
+	forEachStore(t, testAttachments)
+}
+
+func TestJSONAttachmentsPersist(t *testing.T) {
+	fpath := filepath.Join(t.TempDir(), "store.json")
+	store := datastores.NewJsonDatastore(fpath)
+	item := store.AddItem(models.ToDo{Title: "test", Priority: "Low", UserId: "TestToDoUser"})
+	attachment, _ := store.AddAttachment(models.Attachment{
+		Id: uuid.New(), UserId: item.UserId, ItemId: item.Id, Filename: "notes.txt",
+		ContentType: "text/plain", Size: 5, CreatedAt: time.Now().UTC().Round(time.Microsecond),
+	})
+	list, _ := datastores.NewJsonDatastore(fpath).ListAttachments(item.UserId, item.Id)
+	if len(list) != 1 || list[0] != attachment {
+		t.Errorf("Expected: %+v, Got: %+v", attachment, list)
+	}
+}
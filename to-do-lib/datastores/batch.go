@@ -0,0 +1,119 @@
+package datastores
+
+import (
+	"errors"
+	"fmt"
+
+	todoerrors "go-to-do-app/to-do-lib/errors"
+	"go-to-do-app/to-do-lib/models"
+)
+
+const (
+	OpCreate = "create"
+	OpUpdate = "update"
+	OpDelete = "delete"
+)
+
+// BatchOp is a single create, update or delete within a batch. Delete only
+// reads the UserId & Id of Item.
+type BatchOp struct {
+	Op   string      `json:"op"`
+	Item models.ToDo `json:"item"`
+}
+
+// BatchResult holds the outcome of the BatchOp at the same index. Err is set
+// when that operation failed, which leaves the store as it was before it and
+// does not stop the rest of the batch.
+type BatchResult struct {
+	Item models.ToDo
+	Err  error
+}
+
+func invalidOpError(op string) error {
+	return &todoerrors.ValidationError{
+		Field: "op",
+		Err:   fmt.Errorf("invalid op: %s. Valid options are: %s, %s, %s", op, OpCreate, OpUpdate, OpDelete),
+	}
+}
+
+// applyBatch expects the caller to hold ds.mut
+func (ds *inMemDatastore) applyBatch(ops []BatchOp) []BatchResult {
+	results := make([]BatchResult, len(ops))
+	for i, op := range ops {
+		switch op.Op {
+		case OpCreate:
+			results[i].Item = ds.addItem(op.Item)
+		case OpUpdate:
+			results[i].Item, results[i].Err = ds.updateItem(op.Item, models.Workflow.Move)
+		case OpDelete:
+			results[i].Item, results[i].Err = ds.deleteItem(op.Item.UserId, op.Item.Id)
+		default:
+			results[i].Err = invalidOpError(op.Op)
+		}
+	}
+	return results
+}
+
+func (ds *inMemDatastore) Batch(ops []BatchOp) ([]BatchResult, error) {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	return ds.applyBatch(ops), nil
+}
+
+func (ds *JsonDatastore) Batch(ops []BatchOp) ([]BatchResult, error) {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	results, _ := ds.inMemDatastore.Batch(ops)
+	ds.save()
+	return results, nil
+}
+
+// Batch runs every operation inside one transaction, each behind its own
+// savepoint, so an operation that fails on a missing item, an invalid change
+// or a conflict leaves nothing behind while the rest of the batch applies, as
+// with the other stores. Any database error rolls the whole batch back.
+func (p *PGDB) Batch(ops []BatchOp) ([]BatchResult, error) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	tx, err := p.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	results := make([]BatchResult, len(ops))
+	for i, op := range ops {
+		if _, err := tx.Exec("SAVEPOINT batch_op"); err != nil {
+			return nil, err
+		}
+		switch op.Op {
+		case OpCreate:
+			results[i].Item, err = pgAddItem(tx, op.Item)
+		case OpUpdate:
+			results[i].Item, err = pgUpdateItem(tx, op.Item, models.Workflow.Move)
+		case OpDelete:
+			results[i].Item, err = pgDeleteItem(tx, op.Item.UserId, op.Item.Id)
+		default:
+			err = invalidOpError(op.Op)
+		}
+		if err == nil {
+			if _, err := tx.Exec("RELEASE SAVEPOINT batch_op"); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		var notFound *todoerrors.NotFoundError
+		var invalid *todoerrors.ValidationError
+		var conflict *todoerrors.ConflictError
+		if !errors.As(err, &notFound) && !errors.As(err, &invalid) && !errors.As(err, &conflict) {
+			return nil, err
+		}
+		if _, err := tx.Exec("ROLLBACK TO SAVEPOINT batch_op"); err != nil {
+			return nil, err
+		}
+		results[i] = BatchResult{Err: err}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
@@ -0,0 +1,122 @@
+package datastores_test
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"go-to-do-app/to-do-lib/datastores"
+	todoerrors "go-to-do-app/to-do-lib/errors"
+	"go-to-do-app/to-do-lib/models"
+
+	"github.com/google/uuid"
+	"github.com/joho/godotenv"
+)
+
+func isNotFound(err error) bool {
+	_, ok := err.(*todoerrors.NotFoundError)
+	return ok
+}
+
+func testBatch(t *testing.T, store datastores.DataStore) {
+	existing := store.AddItem(models.ToDo{Title: "existing", Priority: "Low", UserId: "TestToDoUser"})
+	toDelete := store.AddItem(models.ToDo{Title: "delete me", Priority: "Low", UserId: "TestToDoUser"})
+	updated := existing
+	updated.Complete = true
+	ops := []datastores.BatchOp{
+		{Op: datastores.OpCreate, Item: models.ToDo{Title: "created", Priority: "High", UserId: "TestToDoUser"}},
+		{Op: datastores.OpUpdate, Item: updated},
+		{Op: datastores.OpDelete, Item: toDelete},
+		{Op: datastores.OpUpdate, Item: models.ToDo{Id: uuid.Max, Title: "missing", Priority: "Low", UserId: "TestToDoUser"}},
+		{Op: "upsert", Item: existing},
+		{Op: datastores.OpCreate, Item: models.ToDo{Title: "after failures", Priority: "Low", UserId: "TestToDoUser"}},
+	}
+	results, err := store.Batch(ops)
+	if err != nil {
+		t.Fatalf("batch failed with %s", err)
+	}
+	if len(results) != len(ops) {
+		t.Fatalf("Expected %d results, Got %d", len(ops), len(results))
+	}
+	if results[0].Err != nil || results[0].Item.Title != "created" || results[0].Item.Id == uuid.Nil {
+		t.Errorf("Expected created item, Got: %+v", results[0])
+	}
+	updated.Status = models.StatusDone
+	updated.Revision++
+	if actual, _ := store.GetItem(existing.UserId, existing.Id); !reflect.DeepEqual(actual, updated) {
+		t.Errorf("Expected: %+v, Got: %+v", updated, actual)
+	}
+	if _, err := store.GetItem(toDelete.UserId, toDelete.Id); err == nil {
+		t.Errorf("Expected deleted item %s to be gone", toDelete.Id)
+	}
+	if _, ok := results[3].Err.(*todoerrors.NotFoundError); !ok {
+		t.Errorf("Expected: %T, Got: %T", &todoerrors.NotFoundError{}, results[3].Err)
+	}
+	if _, ok := results[4].Err.(*todoerrors.ValidationError); !ok {
+		t.Errorf("Expected: %T, Got: %T", &todoerrors.ValidationError{}, results[4].Err)
+	}
+	// failed operations do not stop the rest of the batch
+	if actual, err := store.GetItem("TestToDoUser", results[5].Item.Id); err != nil || actual.Title != "after failures" {
+		t.Errorf("Expected: %+v, Got: %+v", results[5].Item, actual)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	forEachStore(t, testBatch)
+}
+
+func TestJSONBatchPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	testBatch(t, datastores.NewJsonDatastore(path))
+
+	reloaded := datastores.NewJsonDatastore(path)
+	results, _ := reloaded.Batch([]datastores.BatchOp{})
+	if len(results) != 0 {
+		t.Errorf("Expected no results for an empty batch, Got %d", len(results))
+	}
+	// the created & updated items should have been written, with the deleted
+	// item kept in the trash
+	items := datastores.LoadJsonStore(path)["TestToDoUser"]
+	deleted := 0
+	for _, item := range items {
+		if item.Deleted() {
+			deleted++
+		}
+	}
+	if len(items) != 4 || deleted != 1 {
+		t.Errorf("Expected 4 items persisted with 1 deleted, Got %d with %d deleted", len(items), deleted)
+	}
+}
+
+func TestPostgresBatch(t *testing.T) {
+	err := godotenv.Load(".env")
+	if err != nil {
+		log.Fatal(err)
+	}
+	password := os.Getenv("DB_PASSWORD")
+	store, err := datastores.NewPGDatastore("postgres", password, "todo")
+	if err != nil {
+		log.Fatal("unable to connect to PG databased with credentials")
+	}
+	testBatch(t, store)
+}
+
+func TestInMemDeleteToDo(t *testing.T) {
+	store := datastores.NewInMemDataStore()
+	item := store.AddItem(models.ToDo{Title: "test", Priority: "Low", UserId: "TestToDoUser"})
+	deleted, err := store.DeleteItem(item.UserId, item.Id)
+	if err != nil {
+		t.Errorf("delete failed with %s", err)
+	}
+	if deleted.Id != item.Id || !deleted.Deleted() {
+		t.Errorf("Expected %s to be returned as deleted, Got: %+v", item.Id, deleted)
+	}
+	if _, actual := store.GetItem(item.UserId, item.Id); !isNotFound(actual) {
+		t.Errorf("Expected: %T, Got: %T", &todoerrors.NotFoundError{}, actual)
+	}
+	if _, err := store.DeleteItem(item.UserId, item.Id); !isNotFound(err) {
+		t.Errorf("Expected deleting a missing item to return %T", &todoerrors.NotFoundError{})
+	}
+}
@@ -1,150 +1,354 @@
 package datastores
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"maps"
 	"os"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
 	todoerrors "go-to-do-app/to-do-lib/errors"
 	"go-to-do-app/to-do-lib/logging"
 	"go-to-do-app/to-do-lib/models"
+	"go-to-do-app/to-do-lib/search"
 
 	"github.com/google/uuid"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 type DataStore interface {
 	AddItem(item models.ToDo) models.ToDo
+	// GetItem returns a user's item, or one shared with them, see ShareStore
 	GetItem(userId string, itemId uuid.UUID) (models.ToDo, error)
+	// ListItems returns a user's items that are not in the trash, in order
+	ListItems(userId string, order ItemOrder) ([]models.ToDo, error)
+	// UpdateItem replaces an item, advancing its revision. An item sent with
+	// a non-zero revision that is no longer current is rejected with a
+	// ConflictError, so that changes made from a stale copy are not lost.
 	UpdateItem(item models.ToDo) (models.ToDo, error)
+	// DeleteItem moves an item to the trash, see TrashStore
+	DeleteItem(userId string, itemId uuid.UUID) (models.ToDo, error)
+	Batch(ops []BatchOp) ([]BatchResult, error)
+	TrashStore
+	IdempotencyStore
+	WebhookStore
+	HistoryStore
+	SearchStore
+	AttachmentStore
+	ShortIdStore
+	ShareStore
+	AssignmentStore
 	Close()
 }
 
+// ItemOrder is the order ListItems returns items in. Items ordered alike are
+// ordered by title, then id.
+type ItemOrder string
+
+const (
+	OrderByTitle ItemOrder = "title"
+	// OrderByPriority puts the items of the highest ranked priority first,
+	// and items whose priority is no longer one of the levels last
+	OrderByPriority ItemOrder = "priority"
+)
+
+// ParseItemOrder reads an order, "" being OrderByTitle.
+func ParseItemOrder(s string) (ItemOrder, error) {
+	switch order := ItemOrder(strings.ToLower(s)); order {
+	case "":
+		return OrderByTitle, nil
+	case OrderByTitle, OrderByPriority:
+		return order, nil
+	}
+	return "", &todoerrors.ValidationError{Field: "sort", Err: fmt.Errorf("unknown order %q, use %s or %s", s, OrderByTitle, OrderByPriority)}
+}
+
 type inMemDatastore struct {
-	Items map[string]map[uuid.UUID]models.ToDo
-	mut   sync.Mutex
+	Items           map[string]map[uuid.UUID]models.ToDo
+	idempotencyKeys map[string]IdempotencyRecord
+	webhooks        map[string]map[uuid.UUID]WebhookSubscription
+	deliveries      map[uuid.UUID]WebhookDelivery
+	reminders       map[uuid.UUID]time.Time
+	history         map[uuid.UUID][]models.HistoryEntry
+	attachments     map[uuid.UUID][]models.Attachment
+	shares          []models.Share
+	index           *search.Index
+	mut             sync.Mutex
 }
 
 // this function is only retuning (models.ToDo, error) to avoid code duplicatio on endpoints, which seems kinda bad
 func (ds *inMemDatastore) AddItem(item models.ToDo) models.ToDo {
-	item.Id = uuid.New()
 	ds.mut.Lock()
 	defer ds.mut.Unlock()
-
-	if user, exists := ds.Items[item.UserId]; exists {
-		user[item.Id] = item
-	} else {
-		ds.Items[item.UserId] = map[uuid.UUID]models.ToDo{item.Id: item}
-	}
-	return ds.Items[item.UserId][item.Id]
+	return ds.addItem(item)
 }
 
 func (ds *inMemDatastore) GetItem(userId string, itemId uuid.UUID) (models.ToDo, error) {
 	ds.mut.Lock()
 	defer ds.mut.Unlock()
-	if item, exists := ds.Items[userId][itemId]; exists {
+	if item, exists := ds.Items[userId][itemId]; exists && !item.Deleted() {
 		return item, nil
 	}
-	return models.ToDo{}, &todoerrors.NotFoundError{Message: "ToDo Not Found"}
+	access, err := ds.access(userId, itemId)
+	if err != nil {
+		return models.ToDo{}, err
+	}
+	return ds.Items[access.OwnerId][itemId], nil
+}
+
+func (ds *inMemDatastore) ListItems(userId string, order ItemOrder) ([]models.ToDo, error) {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	items := make([]models.ToDo, 0, len(ds.Items[userId]))
+	for _, item := range ds.Items[userId] {
+		if !item.Deleted() {
+			items = append(items, item)
+		}
+	}
+	sortItems(items, order)
+	return items, nil
+}
+
+func sortItems(items []models.ToDo, order ItemOrder) {
+	slices.SortFunc(items, func(a, b models.ToDo) int {
+		if order == OrderByPriority {
+			if c := models.ComparePriority(b.Priority, a.Priority); c != 0 {
+				return c
+			}
+		}
+		if c := strings.Compare(a.Title, b.Title); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Id.String(), b.Id.String())
+	})
 }
 
 func (ds *inMemDatastore) UpdateItem(item models.ToDo) (models.ToDo, error) {
 	ds.mut.Lock()
 	defer ds.mut.Unlock()
+	return ds.updateItem(item, models.Workflow.Move)
+}
 
+func (ds *inMemDatastore) DeleteItem(userId string, itemId uuid.UUID) (models.ToDo, error) {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	return ds.deleteItem(userId, itemId)
+}
+
+func (ds *inMemDatastore) Close() {
+	//no action for in mem
+}
+
+// addItem, updateItem & deleteItem expect the caller to hold ds.mut
+func (ds *inMemDatastore) addItem(item models.ToDo) models.ToDo {
+	item.Id = uuid.New()
+	item.Tags = slices.Clone(item.Tags)
+	item.DeletedAt = nil
+	item.Revision = 1
+	models.CurrentWorkflow().Start(&item)
 	if user, exists := ds.Items[item.UserId]; exists {
-		if _, iexist := user[item.Id]; iexist {
+		user[item.Id] = item
+	} else {
+		ds.Items[item.UserId] = map[uuid.UUID]models.ToDo{item.Id: item}
+	}
+	ds.reindex(item)
+	return ds.Items[item.UserId][item.Id]
+}
+
+// statusChange applies the status of an update to the item, such as
+// Workflow.Move.
+type statusChange func(w models.Workflow, before models.ToDo, item *models.ToDo) error
+
+func (ds *inMemDatastore) updateItem(item models.ToDo, change statusChange) (models.ToDo, error) {
+	if user, exists := ds.Items[item.UserId]; exists {
+		if stored, iexist := user[item.Id]; iexist && !stored.Deleted() {
+			if item.Revision != 0 && item.Revision != stored.Revision {
+				return models.ToDo{}, revisionConflict(item.Revision, stored.Revision)
+			}
+			if err := change(models.CurrentWorkflow(), stored, &item); err != nil {
+				return models.ToDo{}, err
+			}
+			item.DeletedAt = nil
+			item.Tags = slices.Clone(item.Tags)
+			item.Revision = stored.Revision + 1
 			user[item.Id] = item
+			ds.reindex(item)
 			return ds.Items[item.UserId][item.Id], nil
 		}
 	}
 	return models.ToDo{}, &todoerrors.NotFoundError{Message: "ToDo Not Found"}
 }
 
-func (ds *inMemDatastore) Close() {
-	//no action for in mem
+func (ds *inMemDatastore) deleteItem(userId string, itemId uuid.UUID) (models.ToDo, error) {
+	item, exists := ds.Items[userId][itemId]
+	if !exists || item.Deleted() {
+		return models.ToDo{}, &todoerrors.NotFoundError{Message: "ToDo Not Found"}
+	}
+	now := time.Now()
+	item.DeletedAt = &now
+	item.Revision++
+	ds.Items[userId][itemId] = item
+	ds.reindex(item)
+	return item, nil
+}
+
+// revisionConflict reports an update made to an old revision of an item.
+func revisionConflict(sent, current int64) error {
+	return &todoerrors.ConflictError{Message: fmt.Sprintf("ToDo has changed since revision %d, the current revision is %d", sent, current)}
+}
+
+func newInMemDatastore(items map[string]map[uuid.UUID]models.ToDo) *inMemDatastore {
+	ds := &inMemDatastore{
+		Items:           items,
+		idempotencyKeys: make(map[string]IdempotencyRecord),
+		webhooks:        make(map[string]map[uuid.UUID]WebhookSubscription),
+		deliveries:      make(map[uuid.UUID]WebhookDelivery),
+		reminders:       make(map[uuid.UUID]time.Time),
+		history:         make(map[uuid.UUID][]models.HistoryEntry),
+		attachments:     make(map[uuid.UUID][]models.Attachment),
+		index:           search.NewIndex(),
+		mut:             sync.Mutex{},
+	}
+	workflow := models.CurrentWorkflow()
+	for _, user := range items {
+		for id, item := range user {
+			// items saved before they had a status are given the one their
+			// completion implies
+			item.Status = workflow.StatusOf(item)
+			user[id] = item
+			ds.reindex(item)
+		}
+	}
+	return ds
 }
 
 func NewInMemDataStore() DataStore {
-	return &inMemDatastore{Items: make(map[string]map[uuid.UUID]models.ToDo), mut: sync.Mutex{}}
+	return newInMemDatastore(make(map[string]map[uuid.UUID]models.ToDo))
 }
 
-func LoadJsonStore(fpath string) map[string]map[uuid.UUID]models.ToDo {
-	file, err := os.Open(fpath)
-	ctx := context.Background()
-	logging.AddTraceID(ctx)
+// jsonStoreFile is the layout of a json store on disk. Stores written before
+// webhooks were added hold only the array of items, which is still accepted.
+type jsonStoreFile struct {
+	Items       []models.ToDo           `json:"items"`
+	Webhooks    []WebhookSubscription   `json:"webhooks,omitempty"`
+	Deliveries  []WebhookDelivery       `json:"deliveries,omitempty"`
+	Reminders   map[uuid.UUID]time.Time `json:"reminders,omitempty"`
+	History     []models.HistoryEntry   `json:"history,omitempty"`
+	Attachments []models.Attachment     `json:"attachments,omitempty"`
+	Shares      []models.Share          `json:"shares,omitempty"`
+}
+
+func readJsonStoreFile(fpath string) jsonStoreFile {
+	ctx := logging.AddTraceID(context.Background())
+	var contents jsonStoreFile
+	data, err := os.ReadFile(fpath)
 	if err != nil {
 		logging.LogWithTrace(ctx, map[string]interface{}{}, err.Error())
+		return contents
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(data, &contents.Items)
+	} else {
+		err = json.Unmarshal(data, &contents)
 	}
-	defer file.Close()
-	var todos []models.ToDo
-	decoder := json.NewDecoder(file)
-	err = decoder.Decode(&todos)
 	if err != nil {
 		logging.LogWithTrace(ctx, map[string]interface{}{}, err.Error())
 	}
+	return contents
+}
+
+func (f jsonStoreFile) items() map[string]map[uuid.UUID]models.ToDo {
 	items := make(map[string]map[uuid.UUID]models.ToDo)
-	for _, item := range todos {
-		items[item.UserId] = map[uuid.UUID]models.ToDo{item.Id: item}
-		if err != nil {
-			logging.LogWithTrace(ctx, map[string]interface{}{}, fmt.Sprintf("error with todo: %+v", item))
+	for _, item := range f.Items {
+		if _, exists := items[item.UserId]; !exists {
+			items[item.UserId] = make(map[uuid.UUID]models.ToDo)
 		}
+		items[item.UserId][item.Id] = item
 	}
 	return items
 }
 
+func LoadJsonStore(fpath string) map[string]map[uuid.UUID]models.ToDo {
+	return readJsonStoreFile(fpath).items()
+}
+
+// JsonDatastore keeps its items in memory and writes the whole store back to
+// fpath after every successful mutation.
 type JsonDatastore struct {
+	*inMemDatastore
 	fpath string
 	mut   sync.Mutex
-	items map[string]map[uuid.UUID]models.ToDo
 }
 
 func (ds *JsonDatastore) AddItem(item models.ToDo) models.ToDo {
-	item.Id = uuid.New()
 	ds.mut.Lock()
 	defer ds.mut.Unlock()
-	if user, exists := ds.items[item.UserId]; exists {
-		user[item.Id] = item
-	} else {
-		ds.items[item.UserId] = map[uuid.UUID]models.ToDo{item.Id: item}
-	}
-	ds.Close()
-	return ds.items[item.UserId][item.Id]
+	item = ds.inMemDatastore.AddItem(item)
+	ds.save()
+	return item
 }
 
-func (ds *JsonDatastore) GetItem(userId string, itemId uuid.UUID) (models.ToDo, error) {
+func (ds *JsonDatastore) UpdateItem(item models.ToDo) (models.ToDo, error) {
 	ds.mut.Lock()
 	defer ds.mut.Unlock()
-	if item, exists := ds.items[userId][itemId]; exists {
-		return item, nil
+	item, err := ds.inMemDatastore.UpdateItem(item)
+	if err != nil {
+		return models.ToDo{}, err
 	}
-	return models.ToDo{}, &todoerrors.NotFoundError{Message: "ToDo Not Found"}
+	ds.save()
+	return item, nil
 }
 
-func (ds *JsonDatastore) UpdateItem(item models.ToDo) (models.ToDo, error) {
+func (ds *JsonDatastore) DeleteItem(userId string, itemId uuid.UUID) (models.ToDo, error) {
 	ds.mut.Lock()
 	defer ds.mut.Unlock()
-	if user, exists := ds.items[item.UserId]; exists {
-		if _, iexist := user[item.Id]; iexist {
-			ds.items[item.UserId][item.Id] = item
-			ds.Close()
-			return ds.items[item.UserId][item.Id], nil
-		}
+	item, err := ds.inMemDatastore.DeleteItem(userId, itemId)
+	if err != nil {
+		return models.ToDo{}, err
 	}
-	return models.ToDo{}, &todoerrors.NotFoundError{Message: "ToDo Not Found"}
+	ds.save()
+	return item, nil
 }
 
 func (ds *JsonDatastore) Close() {
-	items := make([]models.ToDo, 0)
-	for _, user := range ds.items {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	ds.save()
+}
+
+// save expects the caller to hold ds.mut
+func (ds *JsonDatastore) save() {
+	ds.inMemDatastore.mut.Lock()
+	contents := jsonStoreFile{Items: make([]models.ToDo, 0)}
+	for _, user := range ds.Items {
 		for _, item := range user {
-			items = append(items, item)
+			contents.Items = append(contents.Items, item)
+		}
+	}
+	for _, user := range ds.webhooks {
+		for _, sub := range user {
+			contents.Webhooks = append(contents.Webhooks, sub)
 		}
 	}
-	bytes, err := json.MarshalIndent(items, "", "  ")
+	for _, delivery := range ds.deliveries {
+		contents.Deliveries = append(contents.Deliveries, delivery)
+	}
+	contents.Reminders = maps.Clone(ds.reminders)
+	for _, entries := range ds.history {
+		contents.History = append(contents.History, entries...)
+	}
+	for _, attachments := range ds.attachments {
+		contents.Attachments = append(contents.Attachments, attachments...)
+	}
+	contents.Shares = ds.shares
+	ds.inMemDatastore.mut.Unlock()
+	bytes, err := json.MarshalIndent(contents, "", "  ")
 	if err != nil {
 		fmt.Println("Error marshalling JSON:", err)
 		return
@@ -158,7 +362,26 @@ func (ds *JsonDatastore) Close() {
 }
 
 func NewJsonDatastore(path string) DataStore {
-	return &JsonDatastore{fpath: path, items: LoadJsonStore(path), mut: sync.Mutex{}}
+	contents := readJsonStoreFile(path)
+	store := newInMemDatastore(contents.items())
+	for _, sub := range contents.Webhooks {
+		if _, exists := store.webhooks[sub.UserId]; !exists {
+			store.webhooks[sub.UserId] = make(map[uuid.UUID]WebhookSubscription)
+		}
+		store.webhooks[sub.UserId][sub.Id] = sub
+	}
+	for _, delivery := range contents.Deliveries {
+		store.deliveries[delivery.Id] = delivery
+	}
+	maps.Copy(store.reminders, contents.Reminders)
+	for _, entry := range contents.History {
+		store.history[entry.ItemId] = append(store.history[entry.ItemId], entry)
+	}
+	for _, attachment := range contents.Attachments {
+		store.attachments[attachment.ItemId] = append(store.attachments[attachment.ItemId], attachment)
+	}
+	store.shares = contents.Shares
+	return &JsonDatastore{inMemDatastore: store, fpath: path, mut: sync.Mutex{}}
 }
 
 type PGDB struct {
@@ -167,49 +390,168 @@ type PGDB struct {
 	mut     sync.Mutex
 }
 
+// pgExecutor is satisfied by both *sql.DB and *sql.Tx so item queries can be
+// shared between single requests and batches.
+type pgExecutor interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
 func (p *PGDB) AddItem(item models.ToDo) models.ToDo {
 	p.mut.Lock()
 	defer p.mut.Unlock()
-	id := uuid.New()
-	p.db.Exec(
-		"INSERT INTO items (user_id, item_id, title, priority, complete) VALUES($1, $2, $3, $4, $5)",
-		item.UserId, id, item.Title, item.Priority, item.Complete,
-	)
-	rec, _ := p.GetItem(item.UserId, id)
+	rec, _ := pgAddItem(p.db, item)
 	return rec
 }
 func (p *PGDB) GetItem(userId string, itemId uuid.UUID) (models.ToDo, error) {
-	var item models.ToDo
+	if item, err := pgGetItem(p.db, userId, itemId); err == nil {
+		return item, nil
+	}
+	access, err := pgAccess(p.db, userId, itemId)
+	if err != nil {
+		return models.ToDo{}, err
+	}
+	return pgGetItem(p.db, access.OwnerId, itemId)
+}
+func (p *PGDB) ListItems(userId string, order ItemOrder) ([]models.ToDo, error) {
+	// priorities are ranked by their place among the levels of the deployment,
+	// which may change, rather than a rank saved with the item
+	var levels []string
+	if order == OrderByPriority {
+		levels = models.PriorityNames()
+	}
+	return p.queryItems(
+		"SELECT "+pgItemColumns+" FROM items WHERE user_id = $1 AND deleted_at IS NULL "+
+			"ORDER BY array_position($2::text[], priority) DESC NULLS LAST, title, item_id",
+		userId, pq.Array(levels),
+	)
+}
+
+func (p *PGDB) UpdateItem(item models.ToDo) (models.ToDo, error) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	return pgUpdateItem(p.db, item, models.Workflow.Move)
+}
+func (p *PGDB) DeleteItem(userId string, itemId uuid.UUID) (models.ToDo, error) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	return pgDeleteItem(p.db, userId, itemId)
+}
+
+func pgAddItem(ex pgExecutor, item models.ToDo) (models.ToDo, error) {
+	id := uuid.New()
+	models.CurrentWorkflow().Start(&item)
+	if _, err := ex.Exec(
+		"INSERT INTO items (user_id, item_id, title, priority, complete, description, tags, due, status, assignee) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)",
+		item.UserId, id, item.Title, item.Priority, item.Complete, item.Description, pq.Array(item.Tags), item.Due, item.Status, item.Assignee,
+	); err != nil {
+		return models.ToDo{}, err
+	}
+	return pgGetItem(ex, item.UserId, id)
+}
+
+// pgItemColumns is the column list read by scanItem
+const pgItemColumns = "user_id, item_id, title, priority, complete, deleted_at, description, revision, tags, due, status, assignee"
+
+type pgScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanItem(row pgScanner) (models.ToDo, error) {
 	var (
-		user_id  string
-		item_id  string
-		title    string
-		priority string
-		complete bool
+		user_id     string
+		item_id     string
+		title       string
+		priority    string
+		complete    bool
+		deleted_at  sql.NullTime
+		description string
+		revision    int64
+		tags        []string
+		due         sql.NullTime
+		status      string
+		assignee    string
 	)
-	if err := p.db.QueryRow(
-		"SELECT * FROM items WHERE user_id = $1 AND item_id = $2",
-		userId, itemId,
-	).Scan(&user_id, &item_id, &title, &priority, &complete); err != nil {
-		return models.ToDo{}, &todoerrors.NotFoundError{}
+	if err := row.Scan(&user_id, &item_id, &title, &priority, &complete, &deleted_at, &description, &revision, pq.Array(&tags), &due, &status, &assignee); err != nil {
+		return models.ToDo{}, err
 	}
 	id, _ := uuid.Parse(item_id)
-	item = models.ToDo{UserId: user_id, Id: id, Title: title, Priority: priority, Complete: complete}
+	item := models.ToDo{UserId: user_id, Id: id, Title: title, Description: description, Priority: models.Priority(priority), Complete: complete, Status: models.Status(status), Assignee: assignee, Revision: revision}
+	// items saved before they had a status are given the one their
+	// completion implies
+	item.Status = models.CurrentWorkflow().StatusOf(item)
+	if deleted_at.Valid {
+		item.DeletedAt = &deleted_at.Time
+	}
+	if len(tags) > 0 {
+		item.Tags = tags
+	}
+	if due.Valid {
+		item.Due = &due.Time
+	}
 	return item, nil
 }
-func (p *PGDB) UpdateItem(item models.ToDo) (models.ToDo, error) {
-	p.mut.Lock()
-	defer p.mut.Unlock()
-	p.db.Exec(
-		"UPDATE items SET user_id = $1, title = $3, priority = $4, complete = $5 WHERE user_id = $1 AND item_id = $2",
-		item.UserId, item.Id, item.Title, item.Priority, item.Complete,
+
+func pgGetItem(ex pgExecutor, userId string, itemId uuid.UUID) (models.ToDo, error) {
+	item, err := scanItem(ex.QueryRow(
+		"SELECT "+pgItemColumns+" FROM items WHERE user_id = $1 AND item_id = $2 AND deleted_at IS NULL",
+		userId, itemId,
+	))
+	if err != nil {
+		return models.ToDo{}, &todoerrors.NotFoundError{Message: "ToDo Not Found"}
+	}
+	return item, nil
+}
+
+func pgUpdateItem(ex pgExecutor, item models.ToDo, change statusChange) (models.ToDo, error) {
+	stored, err := pgGetItem(ex, item.UserId, item.Id)
+	if err != nil {
+		return models.ToDo{}, err
+	}
+	if item.Revision != 0 && item.Revision != stored.Revision {
+		return models.ToDo{}, revisionConflict(item.Revision, stored.Revision)
+	}
+	if err := change(models.CurrentWorkflow(), stored, &item); err != nil {
+		return models.ToDo{}, err
+	}
+	// the move was checked from the stored status, so the update only
+	// applies while the item is still at the stored revision
+	res, err := ex.Exec(
+		"UPDATE items SET user_id = $1, title = $3, priority = $4, complete = $5, description = $6, tags = $8, due = $9, status = $10, assignee = $11, revision = revision + 1 "+
+			"WHERE user_id = $1 AND item_id = $2 AND deleted_at IS NULL AND revision = $7",
+		item.UserId, item.Id, item.Title, item.Priority, item.Complete, item.Description, stored.Revision, pq.Array(item.Tags), item.Due, item.Status, item.Assignee,
 	)
-	rec, err := p.GetItem(item.UserId, item.Id)
 	if err != nil {
 		return models.ToDo{}, err
 	}
-	return rec, nil
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		// either the item has since been deleted, or changed by another server
+		current, err := pgGetItem(ex, item.UserId, item.Id)
+		if err != nil {
+			return models.ToDo{}, err
+		}
+		return models.ToDo{}, revisionConflict(stored.Revision, current.Revision)
+	}
+	return pgGetItem(ex, item.UserId, item.Id)
+}
+
+func pgDeleteItem(ex pgExecutor, userId string, itemId uuid.UUID) (models.ToDo, error) {
+	item, err := pgGetItem(ex, userId, itemId)
+	if err != nil {
+		return models.ToDo{}, err
+	}
+	now := time.Now()
+	if _, err := ex.Exec(
+		"UPDATE items SET deleted_at = $3, revision = revision + 1 WHERE user_id = $1 AND item_id = $2",
+		userId, itemId, now,
+	); err != nil {
+		return models.ToDo{}, err
+	}
+	item.DeletedAt = &now
+	item.Revision++
+	return item, nil
 }
+
 func (p *PGDB) Close() {
 	p.db.Close()
 }
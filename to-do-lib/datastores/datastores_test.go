@@ -4,6 +4,8 @@ import (
 	"log"
 	"math/rand/v2"
 	"os"
+	"path/filepath"
+	"reflect"
 	"sync"
 	"testing"
 
@@ -15,6 +17,28 @@ import (
 	"github.com/joho/godotenv"
 )
 
+// stores are the kinds of datastore the tests of every store run against.
+var stores = []struct {
+	name string
+	new  func(t *testing.T) datastores.DataStore
+}{
+	{"InMem", func(t *testing.T) datastores.DataStore {
+		return datastores.NewInMemDataStore()
+	}},
+	{"JSON", func(t *testing.T) datastores.DataStore {
+		return datastores.NewJsonDatastore(filepath.Join(t.TempDir(), "store.json"))
+	}},
+}
+
+// forEachStore runs test against a new store of each kind.
+func forEachStore(t *testing.T, test func(t *testing.T, store datastores.DataStore)) {
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			test(t, s.new(t))
+		})
+	}
+}
+
 func TestNewInMemDataStore(t *testing.T) {
 	store := datastores.NewInMemDataStore()
 	if store == nil {
@@ -39,11 +63,36 @@ func TestInMemUpdateToDo(t *testing.T) {
 	expected.Priority = "High"
 	expected.Complete = true
 	actual, _ := store.UpdateItem(expected)
-	if actual != expected {
+	expected.Status = models.StatusDone
+	expected.Revision++
+	if !reflect.DeepEqual(actual, expected) {
 		t.Errorf("Expected: %+v, Got: %+v", expected, actual)
 	}
 }
 
+func TestInMemUpdateStaleRevision(t *testing.T) {
+	store := datastores.NewInMemDataStore()
+	item := store.AddItem(models.ToDo{Title: "test", Priority: "Low", UserId: "TestToDoUser"})
+	if item.Revision != 1 {
+		t.Errorf("Expected: %d, Got: %d", 1, item.Revision)
+	}
+	first := item
+	first.Title = "first"
+	if _, err := store.UpdateItem(first); err != nil {
+		t.Fatalf("update failed with %s", err)
+	}
+	second := item
+	second.Title = "second"
+	_, err := store.UpdateItem(second)
+	if _, ok := err.(*todoerrors.ConflictError); !ok {
+		t.Errorf("Expected: %T, Got: %T", &todoerrors.ConflictError{}, err)
+	}
+	second.Revision = 0
+	if actual, err := store.UpdateItem(second); err != nil || actual.Revision != 3 {
+		t.Errorf("Expected update without a revision at revision 3, Got: %+v (%v)", actual, err)
+	}
+}
+
 func TestInMemUpdateNonExistientToDo(t *testing.T) {
 	store := datastores.NewInMemDataStore()
 	td := models.ToDo{Id: uuid.Max, Title: "test", Priority: "Low", Complete: false, UserId: "TestToDoUser"}
@@ -63,11 +112,91 @@ func TestInMemGetToDo(t *testing.T) {
 	if err != nil {
 		t.Errorf("datastore unable to find item that was created with uuid: %s", expected.Id)
 	}
-	if actual != expected {
+	if !reflect.DeepEqual(actual, expected) {
 		t.Errorf("Expected: %+v, Got: %+v", expected, actual)
 	}
 }
 
+func TestInMemListToDos(t *testing.T) {
+	store := datastores.NewInMemDataStore()
+	b := store.AddItem(models.ToDo{Title: "b", Priority: "Low", UserId: "TestToDoUser"})
+	a := store.AddItem(models.ToDo{Title: "a", Priority: "Low", UserId: "TestToDoUser"})
+	deleted := store.AddItem(models.ToDo{Title: "c", Priority: "Low", UserId: "TestToDoUser"})
+	store.AddItem(models.ToDo{Title: "other", Priority: "Low", UserId: "OtherUser"})
+	store.DeleteItem(deleted.UserId, deleted.Id)
+	items, err := store.ListItems("TestToDoUser", datastores.OrderByTitle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || !reflect.DeepEqual(items[0], a) || !reflect.DeepEqual(items[1], b) {
+		t.Errorf("Expected: %+v, Got: %+v", []models.ToDo{a, b}, items)
+	}
+}
+
+func testListToDosByPriority(t *testing.T, store datastores.DataStore) {
+	low := store.AddItem(models.ToDo{Title: "a", Priority: "Low", UserId: "TestToDoUser"})
+	high := store.AddItem(models.ToDo{Title: "b", Priority: "High", UserId: "TestToDoUser"})
+	medium := store.AddItem(models.ToDo{Title: "c", Priority: "Medium", UserId: "TestToDoUser"})
+	alsoHigh := store.AddItem(models.ToDo{Title: "a", Priority: "High", UserId: "TestToDoUser"})
+	items, err := store.ListItems("TestToDoUser", datastores.OrderByPriority)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []models.ToDo{alsoHigh, high, medium, low}
+	if !reflect.DeepEqual(items, expected) {
+		t.Errorf("Expected: %+v, Got: %+v", expected, items)
+	}
+}
+
+func TestListToDosByPriority(t *testing.T) {
+	forEachStore(t, testListToDosByPriority)
+}
+
+func TestListToDosByConfiguredPriority(t *testing.T) {
+	defer models.SetPriorityLevels(models.DefaultPriorityLevels)
+	if err := models.SetPriorityLevels([]models.PriorityLevel{{Name: "Someday", Rank: 1}, {Name: "Urgent", Rank: 100}, {Name: "Soon", Rank: 50}}); err != nil {
+		t.Fatal(err)
+	}
+	store := datastores.NewInMemDataStore()
+	soon := store.AddItem(models.ToDo{Title: "a", Priority: "Soon", UserId: "TestToDoUser"})
+	retired := store.AddItem(models.ToDo{Title: "b", Priority: "High", UserId: "TestToDoUser"})
+	urgent := store.AddItem(models.ToDo{Title: "c", Priority: "Urgent", UserId: "TestToDoUser"})
+	someday := store.AddItem(models.ToDo{Title: "d", Priority: "Someday", UserId: "TestToDoUser"})
+	items, err := store.ListItems("TestToDoUser", datastores.OrderByPriority)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []models.ToDo{urgent, soon, someday, retired}
+	if !reflect.DeepEqual(items, expected) {
+		t.Errorf("Expected: %+v, Got: %+v", expected, items)
+	}
+}
+
+func testUpdateToDoStatus(t *testing.T, store datastores.DataStore) {
+	item := store.AddItem(models.ToDo{Title: "test", Priority: "Low", UserId: "TestToDoUser"})
+	if item.Status != models.StatusBacklog {
+		t.Errorf("Expected: %v, Got: %v", models.StatusBacklog, item.Status)
+	}
+	item.Status = models.StatusBlocked
+	if _, err := store.UpdateItem(item); err == nil {
+		t.Errorf("Expected move from %v to %v to be rejected", models.StatusBacklog, models.StatusBlocked)
+	}
+	item.Status = models.StatusInProgress
+	item, err := store.UpdateItem(item)
+	if err != nil || item.Status != models.StatusInProgress || item.Complete {
+		t.Errorf("Expected: %v, Got: %+v %v", models.StatusInProgress, item, err)
+	}
+	item.Status = models.StatusDone
+	item, err = store.UpdateItem(item)
+	if err != nil || !item.Complete {
+		t.Errorf("Expected item at %v to be complete, Got: %+v %v", models.StatusDone, item, err)
+	}
+}
+
+func TestUpdateToDoStatus(t *testing.T) {
+	forEachStore(t, testUpdateToDoStatus)
+}
+
 func TestJSONMemDataStore(t *testing.T) {
 	store := datastores.NewInMemDataStore()
 	if store == nil {
@@ -92,7 +221,9 @@ func TestJSONUpdateToDo(t *testing.T) {
 	expected.Priority = "High"
 	expected.Complete = true
 	actual, _ := store.UpdateItem(expected)
-	if actual != expected {
+	expected.Status = models.StatusDone
+	expected.Revision++
+	if !reflect.DeepEqual(actual, expected) {
 		t.Errorf("Expected: %+v, Got: %+v", expected, actual)
 	}
 }
@@ -116,7 +247,7 @@ func TestJSONGetToDo(t *testing.T) {
 	if err != nil {
 		t.Errorf("datastore unable to find item that was created with uuid: %s", expected.Id)
 	}
-	if actual != expected {
+	if !reflect.DeepEqual(actual, expected) {
 		t.Errorf("Expected: %+v, Got: %+v", expected, actual)
 	}
 }
@@ -155,7 +286,7 @@ func TestPostgresGetToDo(t *testing.T) {
 	if err != nil {
 		t.Errorf("datastore unable to find item that was created with uuid: %s", expected.Id)
 	}
-	if actual != expected {
+	if !reflect.DeepEqual(actual, expected) {
 		t.Errorf("Expected: %+v, Got: %+v", expected, actual)
 	}
 }
@@ -175,7 +306,9 @@ func TestPostgresUpdateToDo(t *testing.T) {
 	expected.Priority = "High"
 	expected.Complete = true
 	actual, _ := store.UpdateItem(expected)
-	if actual != expected {
+	expected.Status = models.StatusDone
+	expected.Revision++
+	if !reflect.DeepEqual(actual, expected) {
 		t.Errorf("Expected: %+v, Got: %+v", expected, actual)
 	}
 }
@@ -215,7 +348,7 @@ func TestConcurrentPutRequests(t *testing.T) {
 	}
 	stores = append(stores, pg)
 	statuses := []bool{true, false}
-	priorities := []string{models.PriorityLow, models.PriorityMedium, models.PriorityHigh}
+	priorities := []models.Priority{models.PriorityLow, models.PriorityMedium, models.PriorityHigh}
 	itmev1 := models.ToDo{Id: uuid.Max, Title: "test", Priority: "High", Complete: false, UserId: ""}
 	itemv2 := models.ToDo{Id: uuid.Max, Title: "test", Priority: "High", Complete: false, UserId: "TestToDoUser"}
 	versions := make(map[string]models.ToDo)
@@ -235,8 +368,12 @@ func TestConcurrentPutRequests(t *testing.T) {
 					expected := expectedItem
 					expected.Priority = priorities[rand.IntN(len(statuses))]
 					expected.Complete = statuses[rand.IntN(len(statuses))]
+					// concurrent updates overwrite each other rather than
+					// checking the revision, which the others advance
+					expected.Revision = 0
 					actual, _ := datastore.UpdateItem(expected)
-					if actual != expected {
+					expected.Revision = actual.Revision
+					if !reflect.DeepEqual(actual, expected) {
 						t.Errorf("Expected %+v, Got %+v", expected, actual)
 					}
 				}(i)
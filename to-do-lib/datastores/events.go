@@ -0,0 +1,172 @@
+package datastores
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"go-to-do-app/to-do-lib/events"
+	"go-to-do-app/to-do-lib/logging"
+	"go-to-do-app/to-do-lib/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const pgEventsChannel = "todo_events"
+
+// publishingDatastore publishes an event for every successful mutation of the
+// DataStore it wraps.
+type publishingDatastore struct {
+	DataStore
+	pub events.Publisher
+}
+
+func WithEvents(ds DataStore, pub events.Publisher) DataStore {
+	return &publishingDatastore{DataStore: ds, pub: pub}
+}
+
+func (ds *publishingDatastore) publish(eventType string, item models.ToDo, previous *models.ToDo) {
+	ds.pub.Publish(events.Event{Type: eventType, Item: item, Previous: previous, Time: time.Now()})
+}
+
+// previous looks up the stored version of item before it is changed
+func (ds *publishingDatastore) previous(item models.ToDo) *models.ToDo {
+	prev, err := ds.DataStore.GetItem(item.UserId, item.Id)
+	if err != nil {
+		return nil
+	}
+	return &prev
+}
+
+func (ds *publishingDatastore) AddItem(item models.ToDo) models.ToDo {
+	item = ds.DataStore.AddItem(item)
+	ds.publish(events.Created, item, nil)
+	return item
+}
+
+func (ds *publishingDatastore) UpdateItem(item models.ToDo) (models.ToDo, error) {
+	prev := ds.previous(item)
+	item, err := ds.DataStore.UpdateItem(item)
+	if err != nil {
+		return models.ToDo{}, err
+	}
+	ds.publish(events.Updated, item, prev)
+	return item, nil
+}
+
+func (ds *publishingDatastore) DeleteItem(userId string, itemId uuid.UUID) (models.ToDo, error) {
+	item, err := ds.DataStore.DeleteItem(userId, itemId)
+	if err != nil {
+		return models.ToDo{}, err
+	}
+	ds.publish(events.Deleted, item, nil)
+	return item, nil
+}
+
+func (ds *publishingDatastore) Batch(ops []BatchOp) ([]BatchResult, error) {
+	prevs := make([]*models.ToDo, len(ops))
+	for i, op := range ops {
+		if op.Op == OpUpdate {
+			prevs[i] = ds.previous(op.Item)
+		}
+	}
+	results, err := ds.DataStore.Batch(ops)
+	if err != nil {
+		return nil, err
+	}
+	for i, res := range results {
+		if res.Err != nil {
+			continue
+		}
+		switch ops[i].Op {
+		case OpCreate:
+			ds.publish(events.Created, res.Item, nil)
+		case OpUpdate:
+			ds.publish(events.Updated, res.Item, prevs[i])
+		case OpDelete:
+			ds.publish(events.Deleted, res.Item, nil)
+		}
+	}
+	return results, nil
+}
+
+// Publish sends e to every server listening on the database, including this
+// one. Events are kept in the events table, as NOTIFY payloads are limited in
+// size, and NOTIFY only tells listeners there are new ones.
+func (p *PGDB) Publish(e events.Event) {
+	payload, err := json.Marshal(e)
+	if err == nil {
+		_, err = p.db.Exec(
+			"WITH stored AS (INSERT INTO events (payload, created_at) VALUES ($1, $2) RETURNING event_id) "+
+				"SELECT pg_notify($3, event_id::text) FROM stored",
+			string(payload), time.Now(), pgEventsChannel,
+		)
+	}
+	if err != nil {
+		ctx := logging.AddTraceID(context.Background())
+		logging.LogWithTrace(ctx, map[string]interface{}{"error": err.Error()}, "failed to publish event")
+	}
+}
+
+// pgEventRetention is how long published events are kept for listeners that
+// fall behind.
+const pgEventRetention = time.Hour
+
+// ListenEvents forwards events published by any server on the database to
+// broker until ctx is cancelled.
+func (p *PGDB) ListenEvents(ctx context.Context, broker *events.Broker) error {
+	listener := pq.NewListener(p.connStr, time.Second, time.Minute, nil)
+	defer listener.Close()
+	if err := listener.Listen(pgEventsChannel); err != nil {
+		return err
+	}
+	var last int64
+	if err := p.db.QueryRow("SELECT COALESCE(MAX(event_id), 0) FROM events").Scan(&last); err != nil {
+		return err
+	}
+	prune := time.NewTicker(time.Minute)
+	defer prune.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-prune.C:
+			if _, err := p.db.Exec("DELETE FROM events WHERE created_at < $1", time.Now().Add(-pgEventRetention)); err != nil {
+				logging.LogWithTrace(ctx, map[string]interface{}{"error": err.Error()}, "failed to prune events")
+			}
+		// a nil notification means the connection was re-established, and
+		// events may have been missed, so they are read all the same
+		case <-listener.Notify:
+			var err error
+			if last, err = p.forwardEvents(ctx, last, broker); err != nil {
+				logging.LogWithTrace(ctx, map[string]interface{}{"error": err.Error()}, "failed to read events")
+			}
+		}
+	}
+}
+
+// forwardEvents publishes the events stored after last to broker, returning
+// the id of the last one. Events keep their event_id, so a client resuming
+// from its last id gets the same events from any server.
+func (p *PGDB) forwardEvents(ctx context.Context, last int64, broker *events.Broker) (int64, error) {
+	rows, err := p.db.QueryContext(ctx, "SELECT event_id, payload FROM events WHERE event_id > $1 ORDER BY event_id", last)
+	if err != nil {
+		return last, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var payload []byte
+		if err := rows.Scan(&last, &payload); err != nil {
+			return last, err
+		}
+		var e events.Event
+		if err := json.Unmarshal(payload, &e); err != nil {
+			logging.LogWithTrace(ctx, map[string]interface{}{"error": err.Error()}, "discarding malformed event")
+			continue
+		}
+		e.Id = uint64(last)
+		broker.Publish(e)
+	}
+	return last, rows.Err()
+}
@@ -0,0 +1,39 @@
+package datastores_test
+
+import (
+	"testing"
+
+	"go-to-do-app/to-do-lib/datastores"
+	"go-to-do-app/to-do-lib/events"
+	"go-to-do-app/to-do-lib/models"
+)
+
+type recordingPublisher struct {
+	published []events.Event
+}
+
+func (p *recordingPublisher) Publish(e events.Event) {
+	p.published = append(p.published, e)
+}
+
+func TestWithEventsPublishesMutations(t *testing.T) {
+	pub := &recordingPublisher{}
+	store := datastores.WithEvents(datastores.NewInMemDataStore(), pub)
+	item := store.AddItem(models.ToDo{Title: "test", Priority: "Low", UserId: "TestToDoUser"})
+	item.Complete = true
+	store.UpdateItem(item)
+	store.DeleteItem(item.UserId, item.Id)
+	// a failed mutation should not publish anything
+	store.DeleteItem(item.UserId, item.Id)
+	store.Batch([]datastores.BatchOp{{Op: datastores.OpCreate, Item: item}})
+
+	expected := []string{events.Created, events.Updated, events.Deleted, events.Created}
+	if len(pub.published) != len(expected) {
+		t.Fatalf("Expected %d events, Got %d", len(expected), len(pub.published))
+	}
+	for i, e := range pub.published {
+		if e.Type != expected[i] || e.Item.UserId != item.UserId {
+			t.Errorf("Expected %s event for %s, Got: %+v", expected[i], item.UserId, e)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package datastores
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"go-to-do-app/to-do-lib/events"
+	"go-to-do-app/to-do-lib/logging"
+	"go-to-do-app/to-do-lib/models"
+
+	"github.com/google/uuid"
+)
+
+// HistoryStore keeps an append-only record of the changes to each item.
+type HistoryStore interface {
+	AppendHistory(entry models.HistoryEntry) error
+	// ListHistory returns the entries for an item, oldest first.
+	ListHistory(userId string, itemId uuid.UUID) ([]models.HistoryEntry, error)
+}
+
+func (ds *inMemDatastore) AppendHistory(entry models.HistoryEntry) error {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	ds.history[entry.ItemId] = append(ds.history[entry.ItemId], entry)
+	return nil
+}
+
+func (ds *inMemDatastore) ListHistory(userId string, itemId uuid.UUID) ([]models.HistoryEntry, error) {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	entries := make([]models.HistoryEntry, 0)
+	for _, entry := range ds.history[itemId] {
+		if entry.UserId == userId {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (ds *JsonDatastore) AppendHistory(entry models.HistoryEntry) error {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	if err := ds.inMemDatastore.AppendHistory(entry); err != nil {
+		return err
+	}
+	ds.save()
+	return nil
+}
+
+func (p *PGDB) AppendHistory(entry models.HistoryEntry) error {
+	before, err := json.Marshal(entry.Before)
+	if err != nil {
+		return err
+	}
+	after, err := json.Marshal(entry.After)
+	if err != nil {
+		return err
+	}
+	_, err = p.db.Exec(
+		`INSERT INTO item_history (entry_id, user_id, item_id, action, before, after, actor, trace_id, created_at)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		entry.Id, entry.UserId, entry.ItemId, entry.Action, string(before), string(after), entry.Actor, entry.TraceId, entry.Time,
+	)
+	return err
+}
+
+func (p *PGDB) ListHistory(userId string, itemId uuid.UUID) ([]models.HistoryEntry, error) {
+	rows, err := p.db.Query(
+		`SELECT entry_id, user_id, item_id, action, before, after, actor, trace_id, created_at
+		FROM item_history WHERE user_id = $1 AND item_id = $2 ORDER BY created_at, seq`,
+		userId, itemId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entries := make([]models.HistoryEntry, 0)
+	for rows.Next() {
+		var entry models.HistoryEntry
+		var before, after []byte
+		if err := rows.Scan(
+			&entry.Id, &entry.UserId, &entry.ItemId, &entry.Action, &before, &after, &entry.Actor, &entry.TraceId, &entry.Time,
+		); err != nil {
+			return nil, err
+		}
+		json.Unmarshal(before, &entry.Before)
+		json.Unmarshal(after, &entry.After)
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// historyDatastore appends a HistoryEntry for every successful mutation of the
+// DataStore it wraps, attributed to the actor & trace id of ctx.
+type historyDatastore struct {
+	DataStore
+	ctx context.Context
+}
+
+// WithHistory records the changes made through the returned DataStore. It is
+// bound to the context of a single request.
+func WithHistory(ctx context.Context, ds DataStore) DataStore {
+	return &historyDatastore{DataStore: ds, ctx: ctx}
+}
+
+func (ds *historyDatastore) record(action string, before *models.ToDo, after *models.ToDo) {
+	entry := models.HistoryEntry{
+		Id:      uuid.New(),
+		Action:  action,
+		Before:  before,
+		After:   after,
+		Actor:   logging.GetActor(ds.ctx),
+		TraceId: logging.GetTraceID(ds.ctx),
+		Time:    time.Now(),
+	}
+	for _, item := range []*models.ToDo{after, before} {
+		if item != nil {
+			entry.UserId, entry.ItemId = item.UserId, item.Id
+		}
+	}
+	if entry.Actor == "" {
+		entry.Actor = entry.UserId
+	}
+	if err := ds.DataStore.AppendHistory(entry); err != nil {
+		logging.LogWithTrace(ds.ctx, map[string]interface{}{"error": err.Error(), "itemId": entry.ItemId}, "failed to record history")
+	}
+}
+
+func (ds *historyDatastore) lookup(item models.ToDo) *models.ToDo {
+	prev, err := ds.DataStore.GetItem(item.UserId, item.Id)
+	if err != nil {
+		return nil
+	}
+	return &prev
+}
+
+func (ds *historyDatastore) AddItem(item models.ToDo) models.ToDo {
+	item = ds.DataStore.AddItem(item)
+	ds.record(events.Created, nil, &item)
+	return item
+}
+
+func (ds *historyDatastore) UpdateItem(item models.ToDo) (models.ToDo, error) {
+	before := ds.lookup(item)
+	item, err := ds.DataStore.UpdateItem(item)
+	if err != nil {
+		return models.ToDo{}, err
+	}
+	ds.record(events.Updated, before, &item)
+	return item, nil
+}
+
+func (ds *historyDatastore) DeleteItem(userId string, itemId uuid.UUID) (models.ToDo, error) {
+	item, err := ds.DataStore.DeleteItem(userId, itemId)
+	if err != nil {
+		return models.ToDo{}, err
+	}
+	ds.record(events.Deleted, &item, nil)
+	return item, nil
+}
+
+func (ds *historyDatastore) Batch(ops []BatchOp) ([]BatchResult, error) {
+	befores := make([]*models.ToDo, len(ops))
+	for i, op := range ops {
+		if op.Op == OpUpdate {
+			befores[i] = ds.lookup(op.Item)
+		}
+	}
+	results, err := ds.DataStore.Batch(ops)
+	if err != nil {
+		return nil, err
+	}
+	for i, res := range results {
+		if res.Err != nil {
+			continue
+		}
+		item := res.Item
+		switch ops[i].Op {
+		case OpCreate:
+			ds.record(events.Created, nil, &item)
+		case OpUpdate:
+			ds.record(events.Updated, befores[i], &item)
+		case OpDelete:
+			ds.record(events.Deleted, &item, nil)
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,55 @@
+package datastores_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"go-to-do-app/to-do-lib/datastores"
+	"go-to-do-app/to-do-lib/events"
+	"go-to-do-app/to-do-lib/logging"
+	"go-to-do-app/to-do-lib/models"
+)
+
+func TestWithHistoryRecordsChanges(t *testing.T) {
+	ctx := logging.AddActor(logging.AddTraceID(context.Background()), "TestActor")
+	store := datastores.WithHistory(ctx, datastores.NewInMemDataStore())
+	created := store.AddItem(models.ToDo{Title: "test", Priority: "Low", UserId: "TestToDoUser"})
+	updated := created
+	updated.Priority = "High"
+	store.UpdateItem(updated)
+	store.DeleteItem(created.UserId, created.Id)
+
+	entries, err := store.ListHistory(created.UserId, created.Id)
+	if err != nil {
+		t.Fatalf("list history failed with %s", err)
+	}
+	expected := []string{events.Created, events.Updated, events.Deleted}
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected %d entries, Got %d", len(expected), len(entries))
+	}
+	for i, entry := range entries {
+		if entry.Action != expected[i] || entry.Actor != "TestActor" || entry.TraceId != logging.GetTraceID(ctx) {
+			t.Errorf("Expected %s by TestActor, Got: %+v", expected[i], entry)
+		}
+	}
+	if entries[1].Before == nil || entries[1].Before.Priority != "Low" || entries[1].After.Priority != "High" {
+		t.Errorf("Expected update from Low to High, Got: %+v", entries[1])
+	}
+	if entries[2].After != nil {
+		t.Errorf("Expected no after snapshot for delete, Got: %+v", entries[2].After)
+	}
+	if other, _ := store.ListHistory("SomeoneElse", created.Id); len(other) != 0 {
+		t.Errorf("Expected no history for another user, Got: %+v", other)
+	}
+}
+
+func TestJSONHistoryPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	store := datastores.WithHistory(context.Background(), datastores.NewJsonDatastore(path))
+	item := store.AddItem(models.ToDo{Title: "test", Priority: "Low", UserId: "TestToDoUser"})
+	entries, _ := datastores.NewJsonDatastore(path).ListHistory(item.UserId, item.Id)
+	if len(entries) != 1 || entries[0].Actor != "TestToDoUser" {
+		t.Errorf("Expected 1 entry by the item's user, Got: %+v", entries)
+	}
+}
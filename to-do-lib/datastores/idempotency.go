@@ -0,0 +1,111 @@
+package datastores
+
+import (
+	"database/sql"
+	"time"
+)
+
+// IdempotencyRecord is the stored response for an Idempotency-Key. A
+// StatusCode of 0 means the original request is still being processed.
+type IdempotencyRecord struct {
+	Key         string
+	RequestHash string
+	StatusCode  int
+	Body        []byte
+	CreatedAt   time.Time
+}
+
+type IdempotencyStore interface {
+	// ReserveIdempotencyKey claims key for a new request, returning true when
+	// the caller owns it. Otherwise the existing record is returned. Records
+	// created before expiredBefore are treated as absent.
+	ReserveIdempotencyKey(key string, requestHash string, expiredBefore time.Time) (IdempotencyRecord, bool, error)
+	CompleteIdempotencyKey(key string, statusCode int, body []byte) error
+	ReleaseIdempotencyKey(key string) error
+	PurgeIdempotencyKeys(before time.Time) error
+}
+
+// The in-mem implementation is shared with the json store, which does not
+// persist keys so they only survive for the life of the process.
+func (ds *inMemDatastore) ReserveIdempotencyKey(key string, requestHash string, expiredBefore time.Time) (IdempotencyRecord, bool, error) {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	if rec, exists := ds.idempotencyKeys[key]; exists && !rec.CreatedAt.Before(expiredBefore) {
+		return rec, false, nil
+	}
+	rec := IdempotencyRecord{Key: key, RequestHash: requestHash, CreatedAt: time.Now()}
+	ds.idempotencyKeys[key] = rec
+	return rec, true, nil
+}
+
+func (ds *inMemDatastore) CompleteIdempotencyKey(key string, statusCode int, body []byte) error {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	if rec, exists := ds.idempotencyKeys[key]; exists {
+		rec.StatusCode = statusCode
+		rec.Body = body
+		ds.idempotencyKeys[key] = rec
+	}
+	return nil
+}
+
+func (ds *inMemDatastore) ReleaseIdempotencyKey(key string) error {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	delete(ds.idempotencyKeys, key)
+	return nil
+}
+
+func (ds *inMemDatastore) PurgeIdempotencyKeys(before time.Time) error {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	for key, rec := range ds.idempotencyKeys {
+		if rec.CreatedAt.Before(before) {
+			delete(ds.idempotencyKeys, key)
+		}
+	}
+	return nil
+}
+
+func (p *PGDB) ReserveIdempotencyKey(key string, requestHash string, expiredBefore time.Time) (IdempotencyRecord, bool, error) {
+	var reserved string
+	err := p.db.QueryRow(
+		`INSERT INTO idempotency_keys (idem_key, request_hash, status_code, body, created_at) VALUES($1, $2, 0, NULL, $3)
+		ON CONFLICT (idem_key) DO UPDATE SET request_hash = EXCLUDED.request_hash, status_code = 0, body = NULL, created_at = EXCLUDED.created_at
+		WHERE idempotency_keys.created_at < $4
+		RETURNING idem_key`,
+		key, requestHash, time.Now(), expiredBefore,
+	).Scan(&reserved)
+	if err == nil {
+		return IdempotencyRecord{Key: key, RequestHash: requestHash}, true, nil
+	}
+	if err != sql.ErrNoRows {
+		return IdempotencyRecord{}, false, err
+	}
+	rec := IdempotencyRecord{Key: key}
+	if err := p.db.QueryRow(
+		"SELECT request_hash, status_code, body, created_at FROM idempotency_keys WHERE idem_key = $1",
+		key,
+	).Scan(&rec.RequestHash, &rec.StatusCode, &rec.Body, &rec.CreatedAt); err != nil {
+		return IdempotencyRecord{}, false, err
+	}
+	return rec, false, nil
+}
+
+func (p *PGDB) CompleteIdempotencyKey(key string, statusCode int, body []byte) error {
+	_, err := p.db.Exec(
+		"UPDATE idempotency_keys SET status_code = $2, body = $3 WHERE idem_key = $1",
+		key, statusCode, body,
+	)
+	return err
+}
+
+func (p *PGDB) ReleaseIdempotencyKey(key string) error {
+	_, err := p.db.Exec("DELETE FROM idempotency_keys WHERE idem_key = $1", key)
+	return err
+}
+
+func (p *PGDB) PurgeIdempotencyKeys(before time.Time) error {
+	_, err := p.db.Exec("DELETE FROM idempotency_keys WHERE created_at < $1", before)
+	return err
+}
@@ -0,0 +1,48 @@
+package datastores_test
+
+import (
+	"testing"
+	"time"
+
+	"go-to-do-app/to-do-lib/datastores"
+)
+
+func TestInMemIdempotencyKeyReplay(t *testing.T) {
+	store := datastores.NewInMemDataStore()
+	window := time.Now().Add(-time.Hour)
+	if _, reserved, _ := store.ReserveIdempotencyKey("key", "hash", window); !reserved {
+		t.Fatal("Expected first use of key to be reserved")
+	}
+	rec, reserved, _ := store.ReserveIdempotencyKey("key", "hash", window)
+	if reserved || rec.StatusCode != 0 {
+		t.Errorf("Expected in-progress record, Got reserved=%t %+v", reserved, rec)
+	}
+	store.CompleteIdempotencyKey("key", 201, []byte(`{"title":"test"}`))
+	rec, reserved, _ = store.ReserveIdempotencyKey("key", "hash", window)
+	if reserved || rec.StatusCode != 201 || string(rec.Body) != `{"title":"test"}` {
+		t.Errorf("Expected stored response, Got reserved=%t %+v", reserved, rec)
+	}
+}
+
+func TestInMemIdempotencyKeyExpires(t *testing.T) {
+	store := datastores.NewInMemDataStore()
+	store.ReserveIdempotencyKey("key", "hash", time.Now().Add(-time.Hour))
+	store.CompleteIdempotencyKey("key", 201, nil)
+	if _, reserved, _ := store.ReserveIdempotencyKey("key", "other", time.Now().Add(time.Second)); !reserved {
+		t.Error("Expected key outside the window to be reserved again")
+	}
+}
+
+func TestInMemIdempotencyKeyRelease(t *testing.T) {
+	store := datastores.NewInMemDataStore()
+	window := time.Now().Add(-time.Hour)
+	store.ReserveIdempotencyKey("key", "hash", window)
+	store.ReleaseIdempotencyKey("key")
+	if _, reserved, _ := store.ReserveIdempotencyKey("key", "hash", window); !reserved {
+		t.Error("Expected released key to be reserved again")
+	}
+	store.PurgeIdempotencyKeys(time.Now().Add(time.Second))
+	if _, reserved, _ := store.ReserveIdempotencyKey("key", "hash", window); !reserved {
+		t.Error("Expected purged key to be reserved again")
+	}
+}
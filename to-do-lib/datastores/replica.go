@@ -0,0 +1,57 @@
+package datastores
+
+import (
+	"go-to-do-app/to-do-lib/models"
+
+	"github.com/google/uuid"
+)
+
+// ReplicaStore is implemented by stores that can hold a copy of the items of
+// another store, such as the CLI's offline copy of the server. Items are kept
+// as given, with the ids & revisions of the original, rather than being
+// assigned new ones.
+type ReplicaStore interface {
+	// PutItem stores item, replacing any item with the same id.
+	PutItem(item models.ToDo)
+	// ReplaceItems replaces all of a user's items, including those in the
+	// trash, with items.
+	ReplaceItems(userId string, items []models.ToDo)
+}
+
+func (ds *inMemDatastore) PutItem(item models.ToDo) {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	if _, exists := ds.Items[item.UserId]; !exists {
+		ds.Items[item.UserId] = make(map[uuid.UUID]models.ToDo)
+	}
+	ds.Items[item.UserId][item.Id] = item
+	ds.reindex(item)
+}
+
+func (ds *inMemDatastore) ReplaceItems(userId string, items []models.ToDo) {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	for id := range ds.Items[userId] {
+		ds.index.Remove(id)
+	}
+	ds.Items[userId] = make(map[uuid.UUID]models.ToDo, len(items))
+	for _, item := range items {
+		item.UserId = userId
+		ds.Items[userId][item.Id] = item
+		ds.reindex(item)
+	}
+}
+
+func (ds *JsonDatastore) PutItem(item models.ToDo) {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	ds.inMemDatastore.PutItem(item)
+	ds.save()
+}
+
+func (ds *JsonDatastore) ReplaceItems(userId string, items []models.ToDo) {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	ds.inMemDatastore.ReplaceItems(userId, items)
+	ds.save()
+}
@@ -0,0 +1,39 @@
+package datastores_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"go-to-do-app/to-do-lib/datastores"
+	"go-to-do-app/to-do-lib/models"
+
+	"github.com/google/uuid"
+)
+
+func TestJSONReplicaKeepsIdsAndRevisions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	store := datastores.NewJsonDatastore(path)
+	replica := store.(datastores.ReplicaStore)
+	stale := store.AddItem(models.ToDo{Title: "stale", Priority: "Low", UserId: "TestToDoUser"})
+	kept := models.ToDo{Id: uuid.New(), Title: "kept", Priority: "High", Status: models.StatusBacklog, UserId: "TestToDoUser", Revision: 7}
+	replica.ReplaceItems("TestToDoUser", []models.ToDo{kept})
+
+	reopened := datastores.NewJsonDatastore(path)
+	if actual, err := reopened.GetItem(kept.UserId, kept.Id); err != nil || !reflect.DeepEqual(actual, kept) {
+		t.Errorf("Expected: %+v, Got: %+v (%v)", kept, actual, err)
+	}
+	if _, err := reopened.GetItem(stale.UserId, stale.Id); !isNotFound(err) {
+		t.Errorf("Expected replaced item to be gone, Got: %v", err)
+	}
+	if found, _ := store.Search("TestToDoUser", "stale"); len(found) != 0 {
+		t.Errorf("Expected replaced item to be unindexed, Got: %+v", found)
+	}
+
+	kept.Title = "changed"
+	kept.Revision = 8
+	replica.PutItem(kept)
+	if actual, _ := store.GetItem(kept.UserId, kept.Id); !reflect.DeepEqual(actual, kept) {
+		t.Errorf("Expected: %+v, Got: %+v", kept, actual)
+	}
+}
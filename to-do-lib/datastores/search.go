@@ -0,0 +1,78 @@
+package datastores
+
+import (
+	"strings"
+
+	"go-to-do-app/to-do-lib/models"
+	"go-to-do-app/to-do-lib/search"
+)
+
+// SearchStore finds a user's items by their title & description. Every word
+// of the query must match a word of the item, either whole or as a prefix, and
+// the best matches come first. Deleted items are never returned.
+type SearchStore interface {
+	Search(userId string, query string) ([]models.ToDo, error)
+}
+
+// searchText is the text of an item that is indexed for search.
+func searchText(item models.ToDo) string {
+	return item.Title + "\n" + item.Description
+}
+
+// reindex expects the caller to hold ds.mut
+func (ds *inMemDatastore) reindex(item models.ToDo) {
+	if item.Deleted() {
+		ds.index.Remove(item.Id)
+		return
+	}
+	ds.index.Add(item.UserId, item.Id, searchText(item))
+}
+
+func (ds *inMemDatastore) Search(userId string, query string) ([]models.ToDo, error) {
+	results := ds.index.Search(userId, query)
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	items := make([]models.ToDo, 0, len(results))
+	for _, res := range results {
+		if item, exists := ds.Items[userId][res.Id]; exists && !item.Deleted() {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// pgTsQuery turns a search query into a to_tsquery expression matching every
+// token as a prefix. Tokens only hold letters & digits so need no quoting.
+func pgTsQuery(query string) string {
+	tokens := search.Tokenize(query)
+	for i, token := range tokens {
+		tokens[i] = token + ":*"
+	}
+	return strings.Join(tokens, " & ")
+}
+
+func (p *PGDB) Search(userId string, query string) ([]models.ToDo, error) {
+	items := make([]models.ToDo, 0)
+	tsquery := pgTsQuery(query)
+	if tsquery == "" {
+		return items, nil
+	}
+	rows, err := p.db.Query(
+		`SELECT `+pgItemColumns+` FROM items, to_tsquery('simple', $2) query
+		WHERE user_id = $1 AND deleted_at IS NULL AND search @@ query
+		ORDER BY ts_rank(search, query) DESC, item_id`,
+		userId, tsquery,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		item, err := scanItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
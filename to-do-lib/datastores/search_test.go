@@ -0,0 +1,61 @@
+package datastores_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"go-to-do-app/to-do-lib/datastores"
+	"go-to-do-app/to-do-lib/models"
+)
+
+func testSearch(t *testing.T, store datastores.DataStore) {
+	milk := store.AddItem(models.ToDo{Title: "Buy milk", Priority: "Low", UserId: "TestToDoUser"})
+	bread := store.AddItem(models.ToDo{Title: "Buy bread", Priority: "Low", UserId: "TestToDoUser"})
+	store.AddItem(models.ToDo{Title: "Buy milk", Priority: "Low", UserId: "SomeoneElse"})
+
+	found, _ := store.Search("TestToDoUser", "buy mil")
+	if len(found) != 1 || !reflect.DeepEqual(found[0], milk) {
+		t.Errorf("Expected: %+v, Got: %+v", milk, found)
+	}
+
+	bread.Title = "Bake sourdough"
+	store.UpdateItem(bread)
+	if found, _ := store.Search("TestToDoUser", "bread"); len(found) != 0 {
+		t.Errorf("Expected updated title to replace the old one, Got: %+v", found)
+	}
+	if found, _ := store.Search("TestToDoUser", "sour"); len(found) != 1 || found[0].Id != bread.Id {
+		t.Errorf("Expected: %+v, Got: %+v", bread, found)
+	}
+
+	store.DeleteItem(milk.UserId, milk.Id)
+	if found, _ := store.Search("TestToDoUser", "milk"); len(found) != 0 {
+		t.Errorf("Expected deleted item to be excluded, Got: %+v", found)
+	}
+	store.RestoreItem(milk.UserId, milk.Id)
+	if found, _ := store.Search("TestToDoUser", "milk"); len(found) != 1 {
+		t.Errorf("Expected restored item to be found, Got: %+v", found)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	forEachStore(t, testSearch)
+}
+
+func TestJSONSearchIndexesLoadedItems(t *testing.T) {
+	fpath := filepath.Join(t.TempDir(), "store.json")
+	item := datastores.NewJsonDatastore(fpath).AddItem(models.ToDo{Title: "Buy milk", Priority: "Low", UserId: "TestToDoUser"})
+	found, _ := datastores.NewJsonDatastore(fpath).Search("TestToDoUser", "milk")
+	if len(found) != 1 || !reflect.DeepEqual(found[0], item) {
+		t.Errorf("Expected: %+v, Got: %+v", item, found)
+	}
+}
+
+func TestSearchMatchesDescription(t *testing.T) {
+	store := datastores.NewInMemDataStore()
+	item := store.AddItem(models.ToDo{Title: "Shopping", Description: "- **milk**\n- eggs", Priority: "Low", UserId: "TestToDoUser"})
+	found, _ := store.Search("TestToDoUser", "eggs milk")
+	if len(found) != 1 || !reflect.DeepEqual(found[0], item) {
+		t.Errorf("Expected: %+v, Got: %+v", item, found)
+	}
+}
@@ -0,0 +1,317 @@
+package datastores
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	todoerrors "go-to-do-app/to-do-lib/errors"
+	"go-to-do-app/to-do-lib/models"
+
+	"github.com/google/uuid"
+)
+
+// ShareStore shares items, or a user's whole list, with collaborators and
+// resolves the access users have to items that are not theirs. GetItem finds
+// items shared with the user as well as their own.
+type ShareStore interface {
+	// ShareItem gives the collaborator a role on the owner's item, or on
+	// every item of theirs. Sharing again with the same collaborator replaces
+	// their role.
+	ShareItem(share models.Share) (models.Share, error)
+	// Unshare takes back what ShareItem gave, itemId being uuid.Nil for a
+	// shared list.
+	Unshare(ownerId string, itemId uuid.UUID, collaborator string) error
+	// ListShares returns the shares of an owner's item, or of their list when
+	// itemId is uuid.Nil, oldest first.
+	ListShares(ownerId string, itemId uuid.UUID) ([]models.Share, error)
+	// ListShared returns the items shared with a user that are not in the
+	// trash, ordered by title, each with the highest role the user has on it.
+	ListShared(userId string) ([]models.SharedItem, error)
+	// Access returns the role a user has on an item that is not in the trash:
+	// owner of their own, or else the highest role shared with them. Items
+	// that are neither are not found, so they are not revealed to others.
+	Access(userId string, itemId uuid.UUID) (models.Access, error)
+}
+
+var errShareNotFound = &todoerrors.NotFoundError{Message: "Share Not Found"}
+
+// keepHighestRole adds item to shared, or raises the role it already has.
+func keepHighestRole(shared map[uuid.UUID]models.SharedItem, item models.ToDo, role models.Role) {
+	if current, exists := shared[item.Id]; !exists || !current.Role.Allows(role) {
+		shared[item.Id] = models.SharedItem{ToDo: item, Role: role}
+	}
+}
+
+// sortShared orders shared items like ListItems does by title.
+func sortShared(shared map[uuid.UUID]models.SharedItem) []models.SharedItem {
+	items := make([]models.ToDo, 0, len(shared))
+	for _, s := range shared {
+		items = append(items, s.ToDo)
+	}
+	sortItems(items, OrderByTitle)
+	sorted := make([]models.SharedItem, 0, len(items))
+	for _, item := range items {
+		sorted = append(sorted, shared[item.Id])
+	}
+	return sorted
+}
+
+func (ds *inMemDatastore) ShareItem(share models.Share) (models.Share, error) {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	if share.ItemId != nil {
+		if item, exists := ds.Items[share.OwnerId][*share.ItemId]; !exists || item.Deleted() {
+			return models.Share{}, &todoerrors.NotFoundError{Message: "ToDo Not Found"}
+		}
+	}
+	for i, existing := range ds.shares {
+		if existing.OwnerId == share.OwnerId && existing.Item() == share.Item() && existing.Collaborator == share.Collaborator {
+			ds.shares[i].Role = share.Role
+			return ds.shares[i], nil
+		}
+	}
+	share.CreatedAt = time.Now().UTC()
+	ds.shares = append(ds.shares, share)
+	return share, nil
+}
+
+func (ds *inMemDatastore) Unshare(ownerId string, itemId uuid.UUID, collaborator string) error {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	for i, share := range ds.shares {
+		if share.OwnerId == ownerId && share.Item() == itemId && share.Collaborator == collaborator {
+			ds.shares = slices.Delete(ds.shares, i, i+1)
+			return nil
+		}
+	}
+	return errShareNotFound
+}
+
+func (ds *inMemDatastore) ListShares(ownerId string, itemId uuid.UUID) ([]models.Share, error) {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	shares := make([]models.Share, 0)
+	for _, share := range ds.shares {
+		if share.OwnerId == ownerId && share.Item() == itemId {
+			shares = append(shares, share)
+		}
+	}
+	return shares, nil
+}
+
+func (ds *inMemDatastore) ListShared(userId string) ([]models.SharedItem, error) {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	shared := make(map[uuid.UUID]models.SharedItem)
+	for _, share := range ds.shares {
+		if share.Collaborator != userId {
+			continue
+		}
+		for _, item := range ds.Items[share.OwnerId] {
+			if !item.Deleted() && share.Covers(item) {
+				keepHighestRole(shared, item, share.Role)
+			}
+		}
+	}
+	return sortShared(shared), nil
+}
+
+func (ds *inMemDatastore) Access(userId string, itemId uuid.UUID) (models.Access, error) {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	return ds.access(userId, itemId)
+}
+
+// access expects the caller to hold ds.mut
+func (ds *inMemDatastore) access(userId string, itemId uuid.UUID) (models.Access, error) {
+	if item, exists := ds.Items[userId][itemId]; exists && !item.Deleted() {
+		return models.Access{OwnerId: userId, Role: models.RoleOwner}, nil
+	}
+	access, _ := ds.assignedAccess(userId, itemId)
+	for _, share := range ds.shares {
+		if share.Collaborator != userId || access.Role.Allows(share.Role) {
+			continue
+		}
+		if item, exists := ds.Items[share.OwnerId][itemId]; exists && !item.Deleted() && share.Covers(item) {
+			access = models.Access{OwnerId: share.OwnerId, Role: share.Role}
+		}
+	}
+	if access.Role == "" {
+		return models.Access{}, &todoerrors.NotFoundError{Message: "ToDo Not Found"}
+	}
+	return access, nil
+}
+
+func (ds *JsonDatastore) ShareItem(share models.Share) (models.Share, error) {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	share, err := ds.inMemDatastore.ShareItem(share)
+	if err != nil {
+		return models.Share{}, err
+	}
+	ds.save()
+	return share, nil
+}
+
+func (ds *JsonDatastore) Unshare(ownerId string, itemId uuid.UUID, collaborator string) error {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	if err := ds.inMemDatastore.Unshare(ownerId, itemId, collaborator); err != nil {
+		return err
+	}
+	ds.save()
+	return nil
+}
+
+// pgShareColumns is the column list read by scanShare
+const pgShareColumns = "owner_id, item_id, collaborator, role, created_at"
+
+// pgShareItemId is the item_id a share is stored with, empty for a list.
+func pgShareItemId(itemId uuid.UUID) string {
+	if itemId == uuid.Nil {
+		return ""
+	}
+	return itemId.String()
+}
+
+func scanShare(row pgScanner) (models.Share, error) {
+	var (
+		share  models.Share
+		itemId string
+		role   string
+	)
+	if err := row.Scan(&share.OwnerId, &itemId, &share.Collaborator, &role, &share.CreatedAt); err != nil {
+		return models.Share{}, err
+	}
+	if id, err := uuid.Parse(itemId); err == nil {
+		share.ItemId = &id
+	}
+	share.Role = models.Role(role)
+	return share, nil
+}
+
+func (p *PGDB) ShareItem(share models.Share) (models.Share, error) {
+	if share.ItemId != nil {
+		if _, err := pgGetItem(p.db, share.OwnerId, *share.ItemId); err != nil {
+			return models.Share{}, err
+		}
+	}
+	return scanShare(p.db.QueryRow(
+		"INSERT INTO shares ("+pgShareColumns+") VALUES($1, $2, $3, $4, $5) "+
+			"ON CONFLICT (owner_id, item_id, collaborator) DO UPDATE SET role = EXCLUDED.role RETURNING "+pgShareColumns,
+		share.OwnerId, pgShareItemId(share.Item()), share.Collaborator, share.Role, time.Now().UTC(),
+	))
+}
+
+func (p *PGDB) Unshare(ownerId string, itemId uuid.UUID, collaborator string) error {
+	res, err := p.db.Exec(
+		"DELETE FROM shares WHERE owner_id = $1 AND item_id = $2 AND collaborator = $3",
+		ownerId, pgShareItemId(itemId), collaborator,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errShareNotFound
+	}
+	return nil
+}
+
+func (p *PGDB) ListShares(ownerId string, itemId uuid.UUID) ([]models.Share, error) {
+	rows, err := p.db.Query(
+		"SELECT "+pgShareColumns+" FROM shares WHERE owner_id = $1 AND item_id = $2 ORDER BY created_at",
+		ownerId, pgShareItemId(itemId),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	shares := make([]models.Share, 0)
+	for rows.Next() {
+		share, err := scanShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, share)
+	}
+	return shares, rows.Err()
+}
+
+// pgQualifiedItemColumns is pgItemColumns of the items table named alias.
+func pgQualifiedItemColumns(alias string) string {
+	columns := strings.Split(pgItemColumns, ", ")
+	for i, column := range columns {
+		columns[i] = alias + "." + column
+	}
+	return strings.Join(columns, ", ")
+}
+
+// extraScanner reads the columns selected after those of an item into extra.
+type extraScanner struct {
+	pgScanner
+	extra []any
+}
+
+func (s extraScanner) Scan(dest ...any) error {
+	return s.pgScanner.Scan(append(dest, s.extra...)...)
+}
+
+// pgSharedItems joins the shares made with a user to the items they cover,
+// an empty item_id covering every item of the owner.
+const pgSharedItems = "FROM shares s JOIN items i ON i.user_id = s.owner_id AND (s.item_id = '' OR s.item_id = i.item_id) " +
+	"WHERE s.collaborator = $1 AND i.deleted_at IS NULL"
+
+func (p *PGDB) ListShared(userId string) ([]models.SharedItem, error) {
+	rows, err := p.db.Query(
+		"SELECT "+pgQualifiedItemColumns("i")+", s.role "+pgSharedItems,
+		userId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	shared := make(map[uuid.UUID]models.SharedItem)
+	for rows.Next() {
+		var role string
+		item, err := scanItem(extraScanner{rows, []any{&role}})
+		if err != nil {
+			return nil, err
+		}
+		keepHighestRole(shared, item, models.Role(role))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return sortShared(shared), nil
+}
+
+func (p *PGDB) Access(userId string, itemId uuid.UUID) (models.Access, error) {
+	return pgAccess(p.db, userId, itemId)
+}
+
+func pgAccess(ex pgExecutor, userId string, itemId uuid.UUID) (models.Access, error) {
+	if _, err := pgGetItem(ex, userId, itemId); err == nil {
+		return models.Access{OwnerId: userId, Role: models.RoleOwner}, nil
+	}
+	// the assignee of an item may edit it like an editor it is shared with
+	var access models.Access
+	var role string
+	err := ex.QueryRow(
+		"SELECT owner_id, role FROM ("+
+			"SELECT s.owner_id, s.role "+pgSharedItems+" AND i.item_id = $2 "+
+			"UNION ALL SELECT user_id, $3::text FROM items WHERE item_id = $2 AND assignee = $1 AND deleted_at IS NULL"+
+			") access ORDER BY CASE role WHEN $3 THEN 0 ELSE 1 END LIMIT 1",
+		userId, itemId.String(), models.RoleEditor,
+	).Scan(&access.OwnerId, &role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Access{}, &todoerrors.NotFoundError{Message: "ToDo Not Found"}
+	}
+	if err != nil {
+		return models.Access{}, err
+	}
+	access.Role = models.Role(role)
+	return access, nil
+}
@@ -0,0 +1,82 @@
+package datastores_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"go-to-do-app/to-do-lib/datastores"
+	"go-to-do-app/to-do-lib/models"
+
+	"github.com/google/uuid"
+)
+
+func testShares(t *testing.T, store datastores.DataStore) {
+	shared := store.AddItem(models.ToDo{Title: "shared", Priority: "Low", UserId: "Owner"})
+	listed := store.AddItem(models.ToDo{Title: "listed", Priority: "Low", UserId: "Owner"})
+	if _, err := store.GetItem("Collaborator", shared.Id); !isNotFound(err) {
+		t.Errorf("Expected unshared item not to be found, Got: %v", err)
+	}
+	if _, err := store.ShareItem(models.Share{OwnerId: "Owner", ItemId: &uuid.Max, Collaborator: "Collaborator", Role: models.RoleViewer}); !isNotFound(err) {
+		t.Errorf("Expected sharing a missing item to fail, Got: %v", err)
+	}
+
+	if _, err := store.ShareItem(models.Share{OwnerId: "Owner", ItemId: &shared.Id, Collaborator: "Collaborator", Role: models.RoleViewer}); err != nil {
+		t.Fatalf("Expected item to be shared, Got: %v", err)
+	}
+	if actual, err := store.GetItem("Collaborator", shared.Id); err != nil || !reflect.DeepEqual(actual, shared) {
+		t.Errorf("Expected: %+v, Got: %+v (%v)", shared, actual, err)
+	}
+	if _, err := store.GetItem("Collaborator", listed.Id); !isNotFound(err) {
+		t.Errorf("Expected item that was not shared not to be found, Got: %v", err)
+	}
+	expected := models.Access{OwnerId: "Owner", Role: models.RoleViewer}
+	if actual, err := store.Access("Collaborator", shared.Id); err != nil || actual != expected {
+		t.Errorf("Expected: %+v, Got: %+v (%v)", expected, actual, err)
+	}
+	expected = models.Access{OwnerId: "Owner", Role: models.RoleOwner}
+	if actual, err := store.Access("Owner", shared.Id); err != nil || actual != expected {
+		t.Errorf("Expected: %+v, Got: %+v (%v)", expected, actual, err)
+	}
+
+	// the list share gives a higher role on the item shared alone too
+	if _, err := store.ShareItem(models.Share{OwnerId: "Owner", Collaborator: "Collaborator", Role: models.RoleEditor}); err != nil {
+		t.Fatalf("Expected list to be shared, Got: %v", err)
+	}
+	items, _ := store.ListShared("Collaborator")
+	expectedItems := []models.SharedItem{{ToDo: listed, Role: models.RoleEditor}, {ToDo: shared, Role: models.RoleEditor}}
+	if !reflect.DeepEqual(items, expectedItems) {
+		t.Errorf("Expected: %+v, Got: %+v", expectedItems, items)
+	}
+	if shares, _ := store.ListShares("Owner", shared.Id); len(shares) != 1 || shares[0].Role != models.RoleViewer {
+		t.Errorf("Expected the viewer share of the item, Got: %+v", shares)
+	}
+
+	if err := store.Unshare("Owner", uuid.Nil, "Collaborator"); err != nil {
+		t.Errorf("Expected list to be unshared, Got: %v", err)
+	}
+	if err := store.Unshare("Owner", uuid.Nil, "Collaborator"); !isNotFound(err) {
+		t.Errorf("Expected unsharing twice to fail, Got: %v", err)
+	}
+	if _, err := store.Access("Collaborator", listed.Id); !isNotFound(err) {
+		t.Errorf("Expected no access once unshared, Got: %v", err)
+	}
+	store.DeleteItem("Owner", shared.Id)
+	if items, _ := store.ListShared("Collaborator"); len(items) != 0 {
+		t.Errorf("Expected items in the trash not to be shared, Got: %+v", items)
+	}
+}
+
+func TestShares(t *testing.T) {
+	forEachStore(t, testShares)
+}
+
+func TestJSONSharesPersist(t *testing.T) {
+	fpath := filepath.Join(t.TempDir(), "store.json")
+	store := datastores.NewJsonDatastore(fpath)
+	item := store.AddItem(models.ToDo{Title: "test", Priority: "Low", UserId: "Owner"})
+	store.ShareItem(models.Share{OwnerId: "Owner", ItemId: &item.Id, Collaborator: "Collaborator", Role: models.RoleEditor})
+	if actual, err := datastores.NewJsonDatastore(fpath).GetItem("Collaborator", item.Id); err != nil || actual.Id != item.Id {
+		t.Errorf("Expected: %+v, Got: %+v (%v)", item, actual, err)
+	}
+}
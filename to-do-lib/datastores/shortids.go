@@ -0,0 +1,97 @@
+package datastores
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	todoerrors "go-to-do-app/to-do-lib/errors"
+	"go-to-do-app/to-do-lib/models"
+
+	"github.com/google/uuid"
+)
+
+// ShortIdStore resolves the short ids people type for items, see
+// models.ToDo.ShortId. UUIDs stay the canonical ids, a short id is only a
+// way of finding one.
+type ShortIdStore interface {
+	// ResolveId returns the id of the user's item that ref names, either the
+	// full id or a unique prefix of it. Items in the trash are included, so
+	// they can be restored by short id too. It looks in the user's list
+	// whoever asks, so callers check the list may be read first.
+	ResolveId(userId string, ref string) (uuid.UUID, error)
+}
+
+var shortIdPattern = regexp.MustCompile(`^[0-9a-f-]+$`)
+
+// parseIdRef returns the id of a ref that is a full id, or else the
+// lowercased prefix to look up.
+func parseIdRef(ref string) (uuid.UUID, string, error) {
+	if id, err := uuid.Parse(ref); err == nil {
+		return id, "", nil
+	}
+	prefix := strings.ToLower(ref)
+	if len(prefix) < models.MinShortIdLength || !shortIdPattern.MatchString(prefix) {
+		return uuid.Nil, "", &todoerrors.ValidationError{
+			Field: "id",
+			Err:   fmt.Errorf("%q is neither an id nor a short id of at least %d of its characters", ref, models.MinShortIdLength),
+		}
+	}
+	return uuid.Nil, prefix, nil
+}
+
+// matchedId returns the one id found for a prefix.
+func matchedId(prefix string, ids []uuid.UUID) (uuid.UUID, error) {
+	switch len(ids) {
+	case 0:
+		return uuid.Nil, &todoerrors.NotFoundError{Message: "ToDo Not Found"}
+	case 1:
+		return ids[0], nil
+	default:
+		return uuid.Nil, &todoerrors.ValidationError{
+			Field: "id",
+			Err:   fmt.Errorf("short id %s names more than one item, give more of the id", prefix),
+		}
+	}
+}
+
+func (ds *inMemDatastore) ResolveId(userId string, ref string) (uuid.UUID, error) {
+	id, prefix, err := parseIdRef(ref)
+	if err != nil || prefix == "" {
+		return id, err
+	}
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	var ids []uuid.UUID
+	for id := range ds.Items[userId] {
+		if strings.HasPrefix(id.String(), prefix) {
+			ids = append(ids, id)
+		}
+	}
+	return matchedId(prefix, ids)
+}
+
+func (p *PGDB) ResolveId(userId string, ref string) (uuid.UUID, error) {
+	id, prefix, err := parseIdRef(ref)
+	if err != nil || prefix == "" {
+		return id, err
+	}
+	// the prefix is only hex digits & dashes, so holds no LIKE wildcards
+	rows, err := p.db.Query("SELECT item_id FROM items WHERE user_id = $1 AND item_id LIKE $2 LIMIT 2", userId, prefix+"%")
+	if err != nil {
+		return uuid.Nil, err
+	}
+	defer rows.Close()
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return uuid.Nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return uuid.Nil, err
+	}
+	return matchedId(prefix, ids)
+}
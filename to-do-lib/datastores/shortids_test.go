@@ -0,0 +1,59 @@
+package datastores_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"go-to-do-app/to-do-lib/datastores"
+	todoerrors "go-to-do-app/to-do-lib/errors"
+	"go-to-do-app/to-do-lib/models"
+
+	"github.com/google/uuid"
+)
+
+func isValidationError(err error) bool {
+	var validation *todoerrors.ValidationError
+	return errors.As(err, &validation)
+}
+
+func testResolveId(t *testing.T, store datastores.DataStore) {
+	item := store.AddItem(models.ToDo{Title: "Buy milk", Priority: "Low", UserId: "TestToDoUser"})
+
+	for _, ref := range []string{item.Id.String(), item.ShortId(), strings.ToUpper(item.ShortId()), item.Id.String()[:models.MinShortIdLength]} {
+		if actual, err := store.ResolveId(item.UserId, ref); err != nil || actual != item.Id {
+			t.Errorf("Expected: %+v, Got: %+v (%v) for %q", item.Id, actual, err, ref)
+		}
+	}
+	if _, err := store.ResolveId("SomeoneElse", item.ShortId()); !isNotFound(err) {
+		t.Errorf("Expected another user's item not to be found, Got: %v", err)
+	}
+	for _, ref := range []string{"", "abc", "milk", item.ShortId() + "%"} {
+		if _, err := store.ResolveId(item.UserId, ref); !isValidationError(err) {
+			t.Errorf("Expected a validation error for %q, Got: %v", ref, err)
+		}
+	}
+
+	store.DeleteItem(item.UserId, item.Id)
+	if actual, err := store.ResolveId(item.UserId, item.ShortId()); err != nil || actual != item.Id {
+		t.Errorf("Expected an item in the trash to resolve, Got: %+v (%v)", actual, err)
+	}
+}
+
+func TestResolveId(t *testing.T) {
+	forEachStore(t, testResolveId)
+}
+
+func TestResolveAmbiguousShortId(t *testing.T) {
+	store := datastores.NewInMemDataStore()
+	first := models.ToDo{Id: uuid.MustParse("0123abcd-0000-4000-8000-000000000001"), Title: "first", Priority: "Low", UserId: "TestToDoUser"}
+	second := models.ToDo{Id: uuid.MustParse("0123abce-0000-4000-8000-000000000002"), Title: "second", Priority: "Low", UserId: "TestToDoUser"}
+	store.(datastores.ReplicaStore).ReplaceItems("TestToDoUser", []models.ToDo{first, second})
+
+	if _, err := store.ResolveId("TestToDoUser", "0123abc"); !isValidationError(err) {
+		t.Errorf("Expected a shared prefix to be rejected, Got: %v", err)
+	}
+	if actual, err := store.ResolveId("TestToDoUser", "0123abce"); err != nil || actual != second.Id {
+		t.Errorf("Expected: %+v, Got: %+v (%v)", second.Id, actual, err)
+	}
+}
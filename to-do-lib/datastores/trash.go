@@ -0,0 +1,270 @@
+package datastores
+
+import (
+	"slices"
+	"time"
+
+	todoerrors "go-to-do-app/to-do-lib/errors"
+	"go-to-do-app/to-do-lib/events"
+	"go-to-do-app/to-do-lib/models"
+
+	"github.com/google/uuid"
+)
+
+// TrashStore manages deleted items. A deleted item is hidden from GetItem &
+// UpdateItem until it is restored, or purged once it has been in the trash
+// for longer than the retention period.
+type TrashStore interface {
+	// ListTrash returns a user's deleted items, most recently deleted first.
+	ListTrash(userId string) ([]models.ToDo, error)
+	RestoreItem(userId string, itemId uuid.UUID) (models.ToDo, error)
+	// RevertItem updates an item back to an earlier state of it, as
+	// UndoLastChange does. Unlike UpdateItem, the item may return to any
+	// status of the workflow, whatever its transitions.
+	RevertItem(item models.ToDo) (models.ToDo, error)
+	// PurgeTrash permanently removes items deleted before the given time,
+	// along with their attachments, returning how many items were removed
+	// and the ids of the attachments, whose contents are left to delete.
+	PurgeTrash(before time.Time) (int, []uuid.UUID, error)
+}
+
+func sortTrash(items []models.ToDo) {
+	slices.SortFunc(items, func(a, b models.ToDo) int {
+		return b.DeletedAt.Compare(*a.DeletedAt)
+	})
+}
+
+func (ds *inMemDatastore) ListTrash(userId string) ([]models.ToDo, error) {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	items := make([]models.ToDo, 0)
+	for _, item := range ds.Items[userId] {
+		if item.Deleted() {
+			items = append(items, item)
+		}
+	}
+	sortTrash(items)
+	return items, nil
+}
+
+func (ds *inMemDatastore) RestoreItem(userId string, itemId uuid.UUID) (models.ToDo, error) {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	item, exists := ds.Items[userId][itemId]
+	if !exists || !item.Deleted() {
+		return models.ToDo{}, &todoerrors.NotFoundError{Message: "ToDo Not Found In Trash"}
+	}
+	item.DeletedAt = nil
+	item.Revision++
+	ds.Items[userId][itemId] = item
+	ds.reindex(item)
+	return item, nil
+}
+
+func (ds *inMemDatastore) RevertItem(item models.ToDo) (models.ToDo, error) {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	return ds.updateItem(item, models.Workflow.Revert)
+}
+
+func (ds *inMemDatastore) PurgeTrash(before time.Time) (int, []uuid.UUID, error) {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	purged := 0
+	var attachments []uuid.UUID
+	for _, user := range ds.Items {
+		for id, item := range user {
+			if item.Deleted() && item.DeletedAt.Before(before) {
+				for _, attachment := range ds.attachments[id] {
+					attachments = append(attachments, attachment.Id)
+				}
+				delete(user, id)
+				delete(ds.attachments, id)
+				delete(ds.reminders, id)
+				purged++
+			}
+		}
+	}
+	return purged, attachments, nil
+}
+
+func (ds *JsonDatastore) RestoreItem(userId string, itemId uuid.UUID) (models.ToDo, error) {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	item, err := ds.inMemDatastore.RestoreItem(userId, itemId)
+	if err != nil {
+		return models.ToDo{}, err
+	}
+	ds.save()
+	return item, nil
+}
+
+func (ds *JsonDatastore) RevertItem(item models.ToDo) (models.ToDo, error) {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	item, err := ds.inMemDatastore.RevertItem(item)
+	if err != nil {
+		return models.ToDo{}, err
+	}
+	ds.save()
+	return item, nil
+}
+
+func (ds *JsonDatastore) PurgeTrash(before time.Time) (int, []uuid.UUID, error) {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	purged, attachments, _ := ds.inMemDatastore.PurgeTrash(before)
+	if purged > 0 {
+		ds.save()
+	}
+	return purged, attachments, nil
+}
+
+func (p *PGDB) ListTrash(userId string) ([]models.ToDo, error) {
+	rows, err := p.db.Query(
+		"SELECT "+pgItemColumns+" FROM items WHERE user_id = $1 AND deleted_at IS NOT NULL ORDER BY deleted_at DESC",
+		userId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := make([]models.ToDo, 0)
+	for rows.Next() {
+		item, err := scanItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (p *PGDB) RevertItem(item models.ToDo) (models.ToDo, error) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	return pgUpdateItem(p.db, item, models.Workflow.Revert)
+}
+
+func (p *PGDB) RestoreItem(userId string, itemId uuid.UUID) (models.ToDo, error) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	res, err := p.db.Exec(
+		"UPDATE items SET deleted_at = NULL, revision = revision + 1 WHERE user_id = $1 AND item_id = $2 AND deleted_at IS NOT NULL",
+		userId, itemId,
+	)
+	if err != nil {
+		return models.ToDo{}, err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return models.ToDo{}, &todoerrors.NotFoundError{Message: "ToDo Not Found In Trash"}
+	}
+	return pgGetItem(p.db, userId, itemId)
+}
+
+func (p *PGDB) PurgeTrash(before time.Time) (int, []uuid.UUID, error) {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec("DELETE FROM items WHERE deleted_at < $1", before)
+	if err != nil {
+		return 0, nil, err
+	}
+	purged, err := res.RowsAffected()
+	if err != nil {
+		return 0, nil, err
+	}
+	rows, err := tx.Query(
+		"DELETE FROM attachments a WHERE NOT EXISTS (SELECT 1 FROM items i WHERE i.item_id = a.item_id) RETURNING attachment_id",
+	)
+	if err != nil {
+		return 0, nil, err
+	}
+	var attachments []uuid.UUID
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, nil, err
+		}
+		if parsed, err := uuid.Parse(id); err == nil {
+			attachments = append(attachments, parsed)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, nil, err
+	}
+	return int(purged), attachments, tx.Commit()
+}
+
+func (ds *publishingDatastore) RestoreItem(userId string, itemId uuid.UUID) (models.ToDo, error) {
+	item, err := ds.DataStore.RestoreItem(userId, itemId)
+	if err != nil {
+		return models.ToDo{}, err
+	}
+	ds.publish(events.Restored, item, nil)
+	return item, nil
+}
+
+func (ds *publishingDatastore) RevertItem(item models.ToDo) (models.ToDo, error) {
+	prev := ds.previous(item)
+	item, err := ds.DataStore.RevertItem(item)
+	if err != nil {
+		return models.ToDo{}, err
+	}
+	ds.publish(events.Updated, item, prev)
+	return item, nil
+}
+
+func (ds *historyDatastore) RestoreItem(userId string, itemId uuid.UUID) (models.ToDo, error) {
+	item, err := ds.DataStore.RestoreItem(userId, itemId)
+	if err != nil {
+		return models.ToDo{}, err
+	}
+	ds.record(events.Restored, nil, &item)
+	return item, nil
+}
+
+func (ds *historyDatastore) RevertItem(item models.ToDo) (models.ToDo, error) {
+	before := ds.lookup(item)
+	item, err := ds.DataStore.RevertItem(item)
+	if err != nil {
+		return models.ToDo{}, err
+	}
+	ds.record(events.Updated, before, &item)
+	return item, nil
+}
+
+// UndoLastChange reverses the most recent change recorded in the history of
+// an item: an update is reverted to the previous revision, a delete is
+// restored from the trash and a create or restore is deleted. The undo is
+// itself recorded, so undoing twice puts the change back.
+func UndoLastChange(ds DataStore, userId string, itemId uuid.UUID) (models.ToDo, error) {
+	entries, err := ds.ListHistory(userId, itemId)
+	if err != nil {
+		return models.ToDo{}, err
+	}
+	if len(entries) == 0 {
+		return models.ToDo{}, &todoerrors.NotFoundError{Message: "No changes to undo"}
+	}
+	last := entries[len(entries)-1]
+	switch last.Action {
+	case events.Created, events.Restored:
+		return ds.DeleteItem(userId, itemId)
+	case events.Deleted:
+		return ds.RestoreItem(userId, itemId)
+	case events.Updated:
+		if last.Before == nil {
+			return models.ToDo{}, &todoerrors.NotFoundError{Message: "No previous revision to undo to"}
+		}
+		// the undo applies whatever the current revision or status is
+		before := *last.Before
+		before.Revision = 0
+		return ds.RevertItem(before)
+	default:
+		return models.ToDo{}, &todoerrors.NotFoundError{Message: "No changes to undo"}
+	}
+}
@@ -0,0 +1,100 @@
+package datastores_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"go-to-do-app/to-do-lib/datastores"
+	"go-to-do-app/to-do-lib/models"
+
+	"github.com/google/uuid"
+)
+
+func testTrash(t *testing.T, store datastores.DataStore) {
+	item := store.AddItem(models.ToDo{Title: "test", Priority: "Low", UserId: "TestToDoUser"})
+	store.DeleteItem(item.UserId, item.Id)
+	if _, err := store.UpdateItem(item); !isNotFound(err) {
+		t.Errorf("Expected update of a deleted item to fail, Got: %v", err)
+	}
+	trash, _ := store.ListTrash(item.UserId)
+	if len(trash) != 1 || trash[0].Id != item.Id || !trash[0].Deleted() {
+		t.Fatalf("Expected deleted item in trash, Got: %+v", trash)
+	}
+	restored, err := store.RestoreItem(item.UserId, item.Id)
+	// deleting & restoring are both changes to the item
+	item.Revision += 2
+	if err != nil || !reflect.DeepEqual(restored, item) {
+		t.Errorf("Expected: %+v, Got: %+v (%v)", item, restored, err)
+	}
+	if trash, _ := store.ListTrash(item.UserId); len(trash) != 0 {
+		t.Errorf("Expected empty trash after restore, Got: %+v", trash)
+	}
+
+	attachment, _ := store.AddAttachment(models.Attachment{
+		Id: uuid.New(), UserId: item.UserId, ItemId: item.Id, Filename: "notes.txt",
+		ContentType: "text/plain", Size: 5, CreatedAt: time.Now().UTC().Round(time.Microsecond),
+	})
+	store.DeleteItem(item.UserId, item.Id)
+	if purged, _, _ := store.PurgeTrash(time.Now().Add(-time.Hour)); purged != 0 {
+		t.Errorf("Expected recently deleted item to be kept, purged %d", purged)
+	}
+	purged, attachments, _ := store.PurgeTrash(time.Now().Add(time.Second))
+	if purged != 1 {
+		t.Errorf("Expected 1 item purged, Got %d", purged)
+	}
+	if len(attachments) != 1 || attachments[0] != attachment.Id {
+		t.Errorf("Expected: %v, Got: %v", []uuid.UUID{attachment.Id}, attachments)
+	}
+	if _, err := store.RestoreItem(item.UserId, item.Id); !isNotFound(err) {
+		t.Errorf("Expected purged item to be gone, Got: %v", err)
+	}
+}
+
+func TestTrash(t *testing.T) {
+	forEachStore(t, testTrash)
+}
+
+func TestUndoStatusChangeIgnoresTransitions(t *testing.T) {
+	store := datastores.WithHistory(context.Background(), datastores.NewInMemDataStore())
+	item := store.AddItem(models.ToDo{Title: "test", Priority: "Low", UserId: "TestToDoUser"})
+	for _, status := range []models.Status{models.StatusInProgress, models.StatusBlocked, models.StatusBacklog} {
+		item.Status = status
+		var err error
+		if item, err = store.UpdateItem(item); err != nil {
+			t.Fatalf("Expected move to %s, Got: %v", status, err)
+		}
+	}
+
+	// blocked is not one of the moves from backlog, but the undo is not a move
+	undone, err := datastores.UndoLastChange(store, item.UserId, item.Id)
+	if err != nil || undone.Status != models.StatusBlocked || undone.Complete {
+		t.Errorf("Expected: %s, Got: %+v (%v)", models.StatusBlocked, undone, err)
+	}
+	if undone, err := datastores.UndoLastChange(store, item.UserId, item.Id); err != nil || undone.Status != models.StatusBacklog {
+		t.Errorf("Expected: %s, Got: %+v (%v)", models.StatusBacklog, undone, err)
+	}
+}
+
+func TestUndoLastChange(t *testing.T) {
+	store := datastores.WithHistory(context.Background(), datastores.NewInMemDataStore())
+	item := store.AddItem(models.ToDo{Title: "test", Priority: "Low", UserId: "TestToDoUser"})
+	changed := item
+	changed.Priority = "High"
+	store.UpdateItem(changed)
+
+	undone, err := datastores.UndoLastChange(store, item.UserId, item.Id)
+	// the undo is a further revision
+	item.Revision += 2
+	if err != nil || !reflect.DeepEqual(undone, item) {
+		t.Errorf("Expected update to be undone to %+v, Got: %+v (%v)", item, undone, err)
+	}
+	store.DeleteItem(item.UserId, item.Id)
+	if undone, err := datastores.UndoLastChange(store, item.UserId, item.Id); err != nil || undone.Deleted() {
+		t.Errorf("Expected delete to be undone, Got: %+v (%v)", undone, err)
+	}
+	if _, err := store.GetItem(item.UserId, item.Id); err != nil {
+		t.Errorf("Expected restored item to be found, Got: %v", err)
+	}
+}
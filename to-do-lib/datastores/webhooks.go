@@ -0,0 +1,308 @@
+package datastores
+
+import (
+	"database/sql"
+	"encoding/json"
+	"slices"
+	"time"
+
+	todoerrors "go-to-do-app/to-do-lib/errors"
+	"go-to-do-app/to-do-lib/events"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// WebhookSubscription asks for a user's events to be POSTed to URL. Empty
+// EventTypes or Priorities match every event type or priority.
+type WebhookSubscription struct {
+	Id         uuid.UUID `json:"id"`
+	UserId     string    `json:"user_id"`
+	URL        string    `json:"url"`
+	Secret     string    `json:"secret,omitempty"`
+	EventTypes []string  `json:"event_types,omitempty"`
+	Priorities []string  `json:"priorities,omitempty"`
+}
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+// WebhookDelivery tracks the attempts to send one event to one subscription.
+type WebhookDelivery struct {
+	Id             uuid.UUID    `json:"id"`
+	SubscriptionId uuid.UUID    `json:"subscription_id"`
+	UserId         string       `json:"user_id"`
+	URL            string       `json:"url"`
+	Event          events.Event `json:"event"`
+	Status         string       `json:"status"`
+	Attempts       int          `json:"attempts"`
+	ResponseCode   int          `json:"response_code,omitempty"`
+	LastError      string       `json:"last_error,omitempty"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+}
+
+type WebhookStore interface {
+	AddWebhook(sub WebhookSubscription) (WebhookSubscription, error)
+	ListWebhooks(userId string) ([]WebhookSubscription, error)
+	DeleteWebhook(userId string, id uuid.UUID) error
+	// SaveDelivery adds a delivery or replaces the one with the same Id.
+	SaveDelivery(delivery WebhookDelivery) error
+	GetDelivery(id uuid.UUID) (WebhookDelivery, error)
+	// ListDeliveries lists the deliveries for userId, or every user when it
+	// is empty, optionally limited to one status. Newest deliveries come first.
+	ListDeliveries(userId string, status string) ([]WebhookDelivery, error)
+	// PruneDeliveries drops all but the keep most recently finished
+	// deliveries. Pending deliveries are kept.
+	PruneDeliveries(keep int) error
+}
+
+func deliveryNotFound() error {
+	return &todoerrors.NotFoundError{Message: "Delivery Not Found"}
+}
+
+func (ds *inMemDatastore) AddWebhook(sub WebhookSubscription) (WebhookSubscription, error) {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	sub.Id = uuid.New()
+	if _, exists := ds.webhooks[sub.UserId]; !exists {
+		ds.webhooks[sub.UserId] = make(map[uuid.UUID]WebhookSubscription)
+	}
+	ds.webhooks[sub.UserId][sub.Id] = sub
+	return sub, nil
+}
+
+func (ds *inMemDatastore) ListWebhooks(userId string) ([]WebhookSubscription, error) {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	subs := make([]WebhookSubscription, 0, len(ds.webhooks[userId]))
+	for _, sub := range ds.webhooks[userId] {
+		subs = append(subs, sub)
+	}
+	return subs, nil
+}
+
+func (ds *inMemDatastore) DeleteWebhook(userId string, id uuid.UUID) error {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	if _, exists := ds.webhooks[userId][id]; !exists {
+		return &todoerrors.NotFoundError{Message: "Webhook Not Found"}
+	}
+	delete(ds.webhooks[userId], id)
+	return nil
+}
+
+func (ds *inMemDatastore) SaveDelivery(delivery WebhookDelivery) error {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	ds.deliveries[delivery.Id] = delivery
+	return nil
+}
+
+func (ds *inMemDatastore) GetDelivery(id uuid.UUID) (WebhookDelivery, error) {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	delivery, exists := ds.deliveries[id]
+	if !exists {
+		return WebhookDelivery{}, deliveryNotFound()
+	}
+	return delivery, nil
+}
+
+func (ds *inMemDatastore) ListDeliveries(userId string, status string) ([]WebhookDelivery, error) {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	list := make([]WebhookDelivery, 0)
+	for _, delivery := range ds.deliveries {
+		if (userId == "" || delivery.UserId == userId) && (status == "" || delivery.Status == status) {
+			list = append(list, delivery)
+		}
+	}
+	slices.SortFunc(list, func(a, b WebhookDelivery) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return list, nil
+}
+
+func (ds *inMemDatastore) PruneDeliveries(keep int) error {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	ds.pruneDeliveries(keep)
+	return nil
+}
+
+// pruneDeliveries expects the caller to hold ds.mut
+func (ds *inMemDatastore) pruneDeliveries(keep int) bool {
+	finished := make([]WebhookDelivery, 0, len(ds.deliveries))
+	for _, delivery := range ds.deliveries {
+		if delivery.Status != DeliveryPending {
+			finished = append(finished, delivery)
+		}
+	}
+	if len(finished) <= keep {
+		return false
+	}
+	slices.SortFunc(finished, func(a, b WebhookDelivery) int {
+		return b.UpdatedAt.Compare(a.UpdatedAt)
+	})
+	for _, delivery := range finished[keep:] {
+		delete(ds.deliveries, delivery.Id)
+	}
+	return true
+}
+
+func (ds *JsonDatastore) AddWebhook(sub WebhookSubscription) (WebhookSubscription, error) {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	sub, err := ds.inMemDatastore.AddWebhook(sub)
+	if err != nil {
+		return WebhookSubscription{}, err
+	}
+	ds.save()
+	return sub, nil
+}
+
+func (ds *JsonDatastore) DeleteWebhook(userId string, id uuid.UUID) error {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	if err := ds.inMemDatastore.DeleteWebhook(userId, id); err != nil {
+		return err
+	}
+	ds.save()
+	return nil
+}
+
+func (ds *JsonDatastore) SaveDelivery(delivery WebhookDelivery) error {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	if err := ds.inMemDatastore.SaveDelivery(delivery); err != nil {
+		return err
+	}
+	ds.save()
+	return nil
+}
+
+func (ds *JsonDatastore) PruneDeliveries(keep int) error {
+	ds.mut.Lock()
+	defer ds.mut.Unlock()
+	ds.inMemDatastore.mut.Lock()
+	pruned := ds.pruneDeliveries(keep)
+	ds.inMemDatastore.mut.Unlock()
+	if pruned {
+		ds.save()
+	}
+	return nil
+}
+
+func (p *PGDB) AddWebhook(sub WebhookSubscription) (WebhookSubscription, error) {
+	sub.Id = uuid.New()
+	if _, err := p.db.Exec(
+		"INSERT INTO webhooks (webhook_id, user_id, url, secret, event_types, priorities) VALUES($1, $2, $3, $4, $5, $6)",
+		sub.Id, sub.UserId, sub.URL, sub.Secret, pq.Array(sub.EventTypes), pq.Array(sub.Priorities),
+	); err != nil {
+		return WebhookSubscription{}, err
+	}
+	return sub, nil
+}
+
+func (p *PGDB) ListWebhooks(userId string) ([]WebhookSubscription, error) {
+	rows, err := p.db.Query(
+		"SELECT webhook_id, user_id, url, secret, event_types, priorities FROM webhooks WHERE user_id = $1",
+		userId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	subs := make([]WebhookSubscription, 0)
+	for rows.Next() {
+		var sub WebhookSubscription
+		if err := rows.Scan(
+			&sub.Id, &sub.UserId, &sub.URL, &sub.Secret, pq.Array(&sub.EventTypes), pq.Array(&sub.Priorities),
+		); err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+func (p *PGDB) DeleteWebhook(userId string, id uuid.UUID) error {
+	res, err := p.db.Exec("DELETE FROM webhooks WHERE user_id = $1 AND webhook_id = $2", userId, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return &todoerrors.NotFoundError{Message: "Webhook Not Found"}
+	}
+	return nil
+}
+
+func (p *PGDB) SaveDelivery(delivery WebhookDelivery) error {
+	event, err := json.Marshal(delivery.Event)
+	if err != nil {
+		return err
+	}
+	_, err = p.db.Exec(
+		"INSERT INTO webhook_deliveries (delivery_id, subscription_id, user_id, url, event, status, attempts, response_code, last_error, created_at, updated_at) "+
+			"VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) "+
+			"ON CONFLICT (delivery_id) DO UPDATE SET status = $6, attempts = $7, response_code = $8, last_error = $9, updated_at = $11",
+		delivery.Id, delivery.SubscriptionId, delivery.UserId, delivery.URL, event, delivery.Status,
+		delivery.Attempts, delivery.ResponseCode, delivery.LastError, delivery.CreatedAt, delivery.UpdatedAt,
+	)
+	return err
+}
+
+const pgDeliveryColumns = "delivery_id, subscription_id, user_id, url, event, status, attempts, response_code, last_error, created_at, updated_at"
+
+func scanDelivery(row pgScanner) (WebhookDelivery, error) {
+	var delivery WebhookDelivery
+	var event []byte
+	if err := row.Scan(
+		&delivery.Id, &delivery.SubscriptionId, &delivery.UserId, &delivery.URL, &event, &delivery.Status,
+		&delivery.Attempts, &delivery.ResponseCode, &delivery.LastError, &delivery.CreatedAt, &delivery.UpdatedAt,
+	); err != nil {
+		return WebhookDelivery{}, err
+	}
+	return delivery, json.Unmarshal(event, &delivery.Event)
+}
+
+func (p *PGDB) GetDelivery(id uuid.UUID) (WebhookDelivery, error) {
+	delivery, err := scanDelivery(p.db.QueryRow("SELECT "+pgDeliveryColumns+" FROM webhook_deliveries WHERE delivery_id = $1", id))
+	if err == sql.ErrNoRows {
+		return WebhookDelivery{}, deliveryNotFound()
+	}
+	return delivery, err
+}
+
+func (p *PGDB) ListDeliveries(userId string, status string) ([]WebhookDelivery, error) {
+	rows, err := p.db.Query(
+		"SELECT "+pgDeliveryColumns+" FROM webhook_deliveries WHERE ($1 = '' OR user_id = $1) AND ($2 = '' OR status = $2) ORDER BY created_at DESC",
+		userId, status,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := make([]WebhookDelivery, 0)
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, delivery)
+	}
+	return list, rows.Err()
+}
+
+func (p *PGDB) PruneDeliveries(keep int) error {
+	_, err := p.db.Exec(
+		"DELETE FROM webhook_deliveries WHERE status <> $1 AND delivery_id NOT IN "+
+			"(SELECT delivery_id FROM webhook_deliveries WHERE status <> $1 ORDER BY updated_at DESC LIMIT $2)",
+		DeliveryPending, keep,
+	)
+	return err
+}
@@ -0,0 +1,87 @@
+package datastores_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"go-to-do-app/to-do-lib/datastores"
+	todoerrors "go-to-do-app/to-do-lib/errors"
+
+	"github.com/google/uuid"
+)
+
+func TestJSONWebhooksPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	store := datastores.NewJsonDatastore(path)
+	sub, err := store.AddWebhook(datastores.WebhookSubscription{UserId: "TestToDoUser", URL: "http://localhost/hook"})
+	if err != nil {
+		t.Fatalf("add webhook failed with %s", err)
+	}
+	subs, _ := datastores.NewJsonDatastore(path).ListWebhooks("TestToDoUser")
+	if len(subs) != 1 || subs[0].Id != sub.Id {
+		t.Errorf("Expected: [%+v], Got: %+v", sub, subs)
+	}
+	if err := store.DeleteWebhook("TestToDoUser", sub.Id); err != nil {
+		t.Errorf("delete webhook failed with %s", err)
+	}
+	if subs, _ := datastores.NewJsonDatastore(path).ListWebhooks("TestToDoUser"); len(subs) != 0 {
+		t.Errorf("Expected no webhooks after delete, Got: %+v", subs)
+	}
+}
+
+func TestJSONDeliveriesPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	store := datastores.NewJsonDatastore(path)
+	now := time.Now().UTC().Truncate(time.Second)
+	deliveries := []datastores.WebhookDelivery{
+		{Id: uuid.New(), UserId: "TestToDoUser", Status: datastores.DeliveryDead, CreatedAt: now, UpdatedAt: now},
+		{Id: uuid.New(), UserId: "TestToDoUser", Status: datastores.DeliveryDelivered, CreatedAt: now.Add(time.Second), UpdatedAt: now.Add(time.Second)},
+		{Id: uuid.New(), UserId: "TestToDoUser", Status: datastores.DeliveryPending, CreatedAt: now.Add(2 * time.Second), UpdatedAt: now},
+		{Id: uuid.New(), UserId: "other", Status: datastores.DeliveryDead, CreatedAt: now, UpdatedAt: now},
+	}
+	for _, delivery := range deliveries {
+		if err := store.SaveDelivery(delivery); err != nil {
+			t.Fatalf("save delivery failed with %s", err)
+		}
+	}
+	reloaded := datastores.NewJsonDatastore(path)
+	if actual, _ := reloaded.GetDelivery(deliveries[0].Id); !reflect.DeepEqual(actual, deliveries[0]) {
+		t.Errorf("Expected: %+v, Got: %+v", deliveries[0], actual)
+	}
+	if dead, _ := reloaded.ListDeliveries("", datastores.DeliveryDead); len(dead) != 2 {
+		t.Errorf("Expected: %d, Got: %d", 2, len(dead))
+	}
+	list, _ := reloaded.ListDeliveries("TestToDoUser", "")
+	if len(list) != 3 || list[0].Id != deliveries[2].Id || list[2].Id != deliveries[0].Id {
+		t.Errorf("Expected the user's deliveries newest first, Got: %+v", list)
+	}
+
+	// pruning keeps the most recently finished and every pending delivery
+	if err := store.PruneDeliveries(1); err != nil {
+		t.Fatalf("prune deliveries failed with %s", err)
+	}
+	list, _ = datastores.NewJsonDatastore(path).ListDeliveries("", "")
+	if len(list) != 2 || list[0].Id != deliveries[2].Id || list[1].Id != deliveries[1].Id {
+		t.Errorf("Expected: %+v, Got: %+v", deliveries[1:3], list)
+	}
+	if _, err := store.GetDelivery(deliveries[0].Id); !isNotFound(err) {
+		t.Errorf("Expected: %T, Got: %T", &todoerrors.NotFoundError{}, err)
+	}
+}
+
+func TestJSONLoadsLegacyItemArray(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	legacy := `[
+  {"user_id": "TestToDoUser", "id": "00000000-0000-0000-0000-000000000001", "title": "one", "priority": "Low", "complete": false},
+  {"user_id": "TestToDoUser", "id": "00000000-0000-0000-0000-000000000002", "title": "two", "priority": "Low", "complete": false}
+]`
+	if err := os.WriteFile(path, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if items := datastores.LoadJsonStore(path)["TestToDoUser"]; len(items) != 2 {
+		t.Errorf("Expected 2 items from legacy store, Got %d", len(items))
+	}
+}
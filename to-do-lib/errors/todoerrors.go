@@ -1,6 +1,10 @@
 package todoerrors
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
 
 type NotFoundError struct {
 	Message string
@@ -18,3 +22,114 @@ type ValidationError struct {
 func (e *ValidationError) Error() string {
 	return fmt.Sprintf("Validation error on field %s: %v", e.Field, e.Err)
 }
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// ConflictError is returned when a change clashes with the current state of
+// the item, such as a stale revision.
+type ConflictError struct {
+	Message string
+}
+
+func (e *ConflictError) Error() string {
+	return e.Message
+}
+
+// UnauthorizedError is returned when the caller could not be identified.
+type UnauthorizedError struct {
+	Message string
+}
+
+func (e *UnauthorizedError) Error() string {
+	return e.Message
+}
+
+// ForbiddenError is returned when the caller is known but may not act on the
+// item.
+type ForbiddenError struct {
+	Message string
+}
+
+func (e *ForbiddenError) Error() string {
+	return e.Message
+}
+
+// ProblemContentType is the media type of a Problem, from RFC 7807.
+const ProblemContentType = "application/problem+json"
+
+// Problem types identify the kind of error independently of the message.
+// Problems without a more specific type use "about:blank".
+const (
+	TypeNotFound     = "/problems/not-found"
+	TypeValidation   = "/problems/validation"
+	TypeConflict     = "/problems/conflict"
+	TypeUnauthorized = "/problems/unauthorized"
+	TypeForbidden    = "/problems/forbidden"
+	TypeInternal     = "/problems/internal"
+)
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Problem is an RFC 7807 problem details object, the body of every error
+// response of the api.
+type Problem struct {
+	Type    string       `json:"type"`
+	Title   string       `json:"title"`
+	Status  int          `json:"status"`
+	Detail  string       `json:"detail,omitempty"`
+	Errors  []FieldError `json:"errors,omitempty"`
+	TraceId string       `json:"trace_id,omitempty"`
+}
+
+func (p *Problem) Error() string {
+	if p.Detail == "" {
+		return fmt.Sprintf("%d %s", p.Status, p.Title)
+	}
+	return fmt.Sprintf("%d %s: %s", p.Status, p.Title, p.Detail)
+}
+
+// NewProblem returns a problem of no specific type for a status code.
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{Type: "about:blank", Title: http.StatusText(status), Status: status, Detail: detail}
+}
+
+// ProblemFor maps an error to the problem reported to clients. Unrecognised
+// errors become a 500 without any detail, so internals are never leaked.
+func ProblemFor(err error) *Problem {
+	var (
+		problem      *Problem
+		notFound     *NotFoundError
+		validation   *ValidationError
+		conflict     *ConflictError
+		unauthorized *UnauthorizedError
+		forbidden    *ForbiddenError
+	)
+	switch {
+	case errors.As(err, &problem):
+		p := *problem
+		return &p
+	case errors.As(err, &notFound):
+		return &Problem{Type: TypeNotFound, Title: "Not Found", Status: http.StatusNotFound, Detail: notFound.Message}
+	case errors.As(err, &validation):
+		return &Problem{
+			Type:   TypeValidation,
+			Title:  "Validation Failed",
+			Status: http.StatusBadRequest,
+			Detail: validation.Error(),
+			Errors: []FieldError{{Field: validation.Field, Message: fmt.Sprint(validation.Err)}},
+		}
+	case errors.As(err, &conflict):
+		return &Problem{Type: TypeConflict, Title: "Conflict", Status: http.StatusConflict, Detail: conflict.Message}
+	case errors.As(err, &unauthorized):
+		return &Problem{Type: TypeUnauthorized, Title: "Unauthorized", Status: http.StatusUnauthorized, Detail: unauthorized.Message}
+	case errors.As(err, &forbidden):
+		return &Problem{Type: TypeForbidden, Title: "Forbidden", Status: http.StatusForbidden, Detail: forbidden.Message}
+	default:
+		return &Problem{Type: TypeInternal, Title: "Internal Server Error", Status: http.StatusInternalServerError, Detail: "Internal server error"}
+	}
+}
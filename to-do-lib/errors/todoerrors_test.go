@@ -0,0 +1,43 @@
+package todoerrors_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	todoerrors "go-to-do-app/to-do-lib/errors"
+)
+
+func TestProblemFor(t *testing.T) {
+	tests := []struct {
+		err     error
+		status  int
+		typ     string
+		detail  string
+		nErrors int
+	}{
+		{&todoerrors.NotFoundError{Message: "ToDo Not Found"}, http.StatusNotFound, todoerrors.TypeNotFound, "ToDo Not Found", 0},
+		{&todoerrors.ValidationError{Field: "title", Err: errors.New("invalid title")}, http.StatusBadRequest, todoerrors.TypeValidation, "Validation error on field title: invalid title", 1},
+		{&todoerrors.ConflictError{Message: "stale"}, http.StatusConflict, todoerrors.TypeConflict, "stale", 0},
+		{&todoerrors.UnauthorizedError{Message: "who?"}, http.StatusUnauthorized, todoerrors.TypeUnauthorized, "who?", 0},
+		{&todoerrors.ForbiddenError{Message: "no"}, http.StatusForbidden, todoerrors.TypeForbidden, "no", 0},
+		{fmt.Errorf("wrapped: %w", &todoerrors.ConflictError{Message: "stale"}), http.StatusConflict, todoerrors.TypeConflict, "stale", 0},
+		{todoerrors.NewProblem(http.StatusTooManyRequests, "slow down"), http.StatusTooManyRequests, "about:blank", "slow down", 0},
+		{errors.New("pq: connection refused"), http.StatusInternalServerError, todoerrors.TypeInternal, "Internal server error", 0},
+	}
+	for _, test := range tests {
+		p := todoerrors.ProblemFor(test.err)
+		if p.Status != test.status || p.Type != test.typ || p.Detail != test.detail || len(p.Errors) != test.nErrors {
+			t.Errorf("Expected: %d %s %q, Got: %+v", test.status, test.typ, test.detail, p)
+		}
+	}
+}
+
+func TestProblemForValidationFieldErrors(t *testing.T) {
+	p := todoerrors.ProblemFor(&todoerrors.ValidationError{Field: "priority", Err: errors.New("invalid priority")})
+	expected := todoerrors.FieldError{Field: "priority", Message: "invalid priority"}
+	if len(p.Errors) != 1 || p.Errors[0] != expected {
+		t.Errorf("Expected: %+v, Got: %+v", expected, p.Errors)
+	}
+}
@@ -0,0 +1,132 @@
+package events
+
+import (
+	"sync"
+	"time"
+
+	"go-to-do-app/to-do-lib/models"
+)
+
+const (
+	Created  = "created"
+	Updated  = "updated"
+	Deleted  = "deleted"
+	Restored = "restored"
+)
+
+const (
+	DefaultHistorySize   = 1000
+	subscriberBufferSize = 64
+)
+
+// Event describes a change to Item. Previous is set on updates when the item
+// before the change is known.
+type Event struct {
+	Id       uint64       `json:"id,omitempty"`
+	Type     string       `json:"type"`
+	Item     models.ToDo  `json:"item"`
+	Previous *models.ToDo `json:"previous,omitempty"`
+	Time     time.Time    `json:"time"`
+}
+
+// Completed reports whether e marks its item as complete.
+func (e Event) Completed() bool {
+	return e.Type == Updated && e.Item.Complete && e.Previous != nil && !e.Previous.Complete
+}
+
+type Publisher interface {
+	Publish(e Event)
+}
+
+// Publishers sends every event to each of its publishers in turn.
+type Publishers []Publisher
+
+func (p Publishers) Publish(e Event) {
+	for _, pub := range p {
+		pub.Publish(e)
+	}
+}
+
+type subscriber struct {
+	userId string
+	ch     chan Event
+}
+
+// Broker fans events out to in-process subscribers. It keeps the most recent
+// events so a subscriber can resume from the last id it saw.
+type Broker struct {
+	mut         sync.Mutex
+	lastId      uint64
+	history     []Event
+	historySize int
+	subs        map[*subscriber]struct{}
+}
+
+func NewBroker(historySize int) *Broker {
+	return &Broker{historySize: historySize, subs: make(map[*subscriber]struct{})}
+}
+
+// Publish delivers e to every subscriber of the item's user. An event with
+// an id keeps it, such as one read from a store shared by several servers so
+// they all number events alike, and is dropped if it is not after the last
+// id; others are given the next id, for a single server. A subscriber that
+// has fallen too far behind is dropped, closing its channel, and is expected
+// to resubscribe from its last id.
+func (b *Broker) Publish(e Event) {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	switch {
+	case e.Id == 0:
+		b.lastId++
+		e.Id = b.lastId
+	case e.Id > b.lastId:
+		b.lastId = e.Id
+	default:
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	b.history = append(b.history, e)
+	if len(b.history) > b.historySize {
+		b.history = b.history[len(b.history)-b.historySize:]
+	}
+	for sub := range b.subs {
+		if sub.userId != e.Item.UserId {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+			delete(b.subs, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+// Subscribe returns the events for userId published after lastId, followed by
+// new events as they are published. cancel must be called once the caller
+// stops reading.
+func (b *Broker) Subscribe(userId string, lastId uint64) (events <-chan Event, cancel func()) {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	var backlog []Event
+	for _, e := range b.history {
+		if e.Id > lastId && e.Item.UserId == userId {
+			backlog = append(backlog, e)
+		}
+	}
+	sub := &subscriber{userId: userId, ch: make(chan Event, len(backlog)+subscriberBufferSize)}
+	for _, e := range backlog {
+		sub.ch <- e
+	}
+	b.subs[sub] = struct{}{}
+	return sub.ch, func() {
+		b.mut.Lock()
+		defer b.mut.Unlock()
+		if _, exists := b.subs[sub]; exists {
+			delete(b.subs, sub)
+			close(sub.ch)
+		}
+	}
+}
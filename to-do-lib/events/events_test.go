@@ -0,0 +1,77 @@
+package events_test
+
+import (
+	"testing"
+
+	"go-to-do-app/to-do-lib/events"
+	"go-to-do-app/to-do-lib/models"
+)
+
+func TestBrokerDeliversOnlyUsersEvents(t *testing.T) {
+	broker := events.NewBroker(events.DefaultHistorySize)
+	stream, cancel := broker.Subscribe("TestToDoUser", 0)
+	defer cancel()
+	broker.Publish(events.Event{Type: events.Created, Item: models.ToDo{UserId: "SomeoneElse", Title: "other"}})
+	broker.Publish(events.Event{Type: events.Created, Item: models.ToDo{UserId: "TestToDoUser", Title: "mine"}})
+	actual := <-stream
+	if actual.Item.Title != "mine" || actual.Id != 2 {
+		t.Errorf("Expected event 2 for TestToDoUser, Got: %+v", actual)
+	}
+}
+
+func TestBrokerResumesAfterLastId(t *testing.T) {
+	broker := events.NewBroker(events.DefaultHistorySize)
+	for _, title := range []string{"one", "two", "three"} {
+		broker.Publish(events.Event{Type: events.Updated, Item: models.ToDo{UserId: "TestToDoUser", Title: title}})
+	}
+	stream, cancel := broker.Subscribe("TestToDoUser", 1)
+	defer cancel()
+	for _, expected := range []string{"two", "three"} {
+		if actual := <-stream; actual.Item.Title != expected {
+			t.Errorf("Expected: %s, Got: %s", expected, actual.Item.Title)
+		}
+	}
+}
+
+func TestBrokerHistoryIsBounded(t *testing.T) {
+	broker := events.NewBroker(2)
+	for _, title := range []string{"one", "two", "three"} {
+		broker.Publish(events.Event{Type: events.Updated, Item: models.ToDo{UserId: "TestToDoUser", Title: title}})
+	}
+	stream, cancel := broker.Subscribe("TestToDoUser", 0)
+	for _, expected := range []string{"two", "three"} {
+		if actual := <-stream; actual.Item.Title != expected {
+			t.Errorf("Expected: %s, Got: %s", expected, actual.Item.Title)
+		}
+	}
+	cancel()
+	if _, open := <-stream; open {
+		t.Error("Expected cancel to close the stream")
+	}
+}
+
+func TestBrokerKeepsStoreIds(t *testing.T) {
+	// servers sharing a store number events alike, so a client may resume
+	// from any of them
+	stored := []events.Event{
+		{Id: 10, Type: events.Created, Item: models.ToDo{UserId: "TestToDoUser", Title: "one"}},
+		{Id: 12, Type: events.Updated, Item: models.ToDo{UserId: "TestToDoUser", Title: "two"}},
+		{Id: 12, Type: events.Updated, Item: models.ToDo{UserId: "TestToDoUser", Title: "repeated"}},
+		{Id: 15, Type: events.Updated, Item: models.ToDo{UserId: "TestToDoUser", Title: "three"}},
+	}
+	for _, broker := range []*events.Broker{events.NewBroker(events.DefaultHistorySize), events.NewBroker(events.DefaultHistorySize)} {
+		for _, e := range stored {
+			broker.Publish(e)
+		}
+		stream, cancel := broker.Subscribe("TestToDoUser", 10)
+		for _, expected := range []events.Event{stored[1], stored[3]} {
+			if actual := <-stream; actual.Id != expected.Id || actual.Item.Title != expected.Item.Title {
+				t.Errorf("Expected: %+v, Got: %+v", expected, actual)
+			}
+		}
+		if len(stream) != 0 {
+			t.Errorf("Expected a repeated id to be dropped, Got: %+v", <-stream)
+		}
+		cancel()
+	}
+}
@@ -10,9 +10,16 @@ import (
 
 type contextKey string
 
-const traceIDKey = contextKey("traceID")
+const (
+	traceIDKey = contextKey("traceID")
+	actorKey   = contextKey("actor")
+)
 
+// AddTraceID gives ctx a new trace id, unless it already carries one.
 func AddTraceID(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(traceIDKey).(string); ok {
+		return ctx
+	}
 	traceID := uuid.New().String()
 	return context.WithValue(ctx, traceIDKey, traceID)
 }
@@ -25,11 +32,40 @@ func GetTraceID(ctx context.Context) string {
 	return traceID
 }
 
-var logger = slog.New(slog.NewTextHandler(os.Stdout, nil))
+// AddActor records who is making the request ctx belongs to.
+func AddActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+func GetActor(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey).(string)
+	return actor
+}
+
+var (
+	level  = new(slog.LevelVar)
+	logger = slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: level}))
+)
+
+// ParseLevel reads a level name: debug, info, warn or error.
+func ParseLevel(name string) (slog.Level, error) {
+	var l slog.Level
+	err := l.UnmarshalText([]byte(name))
+	return l, err
+}
+
+// SetLevel changes the lowest level logged, and is safe to call while the
+// server is handling requests.
+func SetLevel(l slog.Level) {
+	level.Set(l)
+}
 
 func LogWithTrace(ctx context.Context, logData map[string]interface{}, message string) {
 	traceID := GetTraceID(ctx)
 	logData["traceID"] = traceID
+	if actor := GetActor(ctx); actor != "" {
+		logData["actor"] = actor
+	}
 	var keyValues []interface{}
 	for key, value := range logData {
 		keyValues = append(keyValues, key, value)
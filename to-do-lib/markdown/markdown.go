@@ -0,0 +1,170 @@
+// Package markdown renders the subset of Markdown used in item descriptions
+// to HTML. All text from the source is escaped, so the only markup in the
+// output is the markup the renderer writes itself, and links are limited to
+// safe schemes.
+package markdown
+
+import (
+	"html"
+	"html/template"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	headingPattern = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	bulletPattern  = regexp.MustCompile(`^\s*[-*+]\s+(.*)$`)
+	orderedPattern = regexp.MustCompile(`^\s*\d+[.)]\s+(.*)$`)
+	rulePattern    = regexp.MustCompile(`^\s*(-\s*){3,}$|^\s*(\*\s*){3,}$|^\s*(_\s*){3,}$`)
+	quotePattern   = regexp.MustCompile(`^\s*>\s?(.*)$`)
+)
+
+var safeSchemes = map[string]bool{"": true, "http": true, "https": true, "mailto": true}
+
+// ToHTML renders src as sanitised HTML.
+func ToHTML(src string) template.HTML {
+	return template.HTML(render(strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")))
+}
+
+func render(lines []string) string {
+	var out strings.Builder
+	var para []string
+	flush := func() {
+		if len(para) > 0 {
+			out.WriteString("<p>" + inline(strings.Join(para, "\n")) + "</p>\n")
+			para = nil
+		}
+	}
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.TrimSpace(line) == "":
+			flush()
+		case strings.HasPrefix(strings.TrimSpace(line), "```"):
+			flush()
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```"); i++ {
+				code = append(code, lines[i])
+			}
+			out.WriteString("<pre><code>" + html.EscapeString(strings.Join(code, "\n")) + "</code></pre>\n")
+		case headingPattern.MatchString(line):
+			flush()
+			m := headingPattern.FindStringSubmatch(line)
+			tag := "h" + string(rune('0'+len(m[1])))
+			out.WriteString("<" + tag + ">" + inline(m[2]) + "</" + tag + ">\n")
+		case rulePattern.MatchString(line):
+			flush()
+			out.WriteString("<hr>\n")
+		case quotePattern.MatchString(line):
+			flush()
+			var quoted []string
+			for ; i < len(lines) && quotePattern.MatchString(lines[i]); i++ {
+				quoted = append(quoted, quotePattern.FindStringSubmatch(lines[i])[1])
+			}
+			i--
+			out.WriteString("<blockquote>\n" + render(quoted) + "</blockquote>\n")
+		case bulletPattern.MatchString(line), orderedPattern.MatchString(line):
+			flush()
+			pattern, tag := bulletPattern, "ul"
+			if !bulletPattern.MatchString(line) {
+				pattern, tag = orderedPattern, "ol"
+			}
+			out.WriteString("<" + tag + ">\n")
+			for ; i < len(lines) && pattern.MatchString(lines[i]); i++ {
+				out.WriteString("<li>" + inline(pattern.FindStringSubmatch(lines[i])[1]) + "</li>\n")
+			}
+			i--
+			out.WriteString("</" + tag + ">\n")
+		default:
+			para = append(para, strings.TrimSpace(line))
+		}
+	}
+	flush()
+	return out.String()
+}
+
+// inline renders code spans, links and emphasis within a block of text.
+func inline(text string) string {
+	var out strings.Builder
+	for i := 0; i < len(text); {
+		switch {
+		case text[i] == '`':
+			if end := strings.IndexByte(text[i+1:], '`'); end >= 0 {
+				out.WriteString("<code>" + html.EscapeString(text[i+1:i+1+end]) + "</code>")
+				i += end + 2
+				continue
+			}
+		case text[i] == '[':
+			if label, href, n, ok := link(text[i:]); ok {
+				if safeURL(href) {
+					out.WriteString(`<a href="` + html.EscapeString(href) + `" rel="nofollow noopener">` + inline(label) + "</a>")
+				} else {
+					out.WriteString(inline(label))
+				}
+				i += n
+				continue
+			}
+		case text[i] == '_' && i > 0 && isWordByte(text[i-1]):
+			// snake_case words are not emphasis
+		case strings.HasPrefix(text[i:], "**"), strings.HasPrefix(text[i:], "__"):
+			delim := text[i : i+2]
+			if end := strings.Index(text[i+2:], delim); end > 0 {
+				out.WriteString("<strong>" + inline(text[i+2:i+2+end]) + "</strong>")
+				i += end + 4
+				continue
+			}
+		case text[i] == '*', text[i] == '_':
+			if end := strings.IndexByte(text[i+1:], text[i]); end > 0 {
+				out.WriteString("<em>" + inline(text[i+1:i+1+end]) + "</em>")
+				i += end + 2
+				continue
+			}
+		case text[i] == '\n':
+			out.WriteString("<br>\n")
+			i++
+			continue
+		}
+		out.WriteString(html.EscapeString(text[i : i+1]))
+		i++
+	}
+	return out.String()
+}
+
+func isWordByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9'
+}
+
+// link matches "[label](href)" at the start of text, returning the number of
+// bytes consumed.
+func link(text string) (string, string, int, bool) {
+	closeLabel := strings.Index(text, "](")
+	if closeLabel < 1 {
+		return "", "", 0, false
+	}
+	closeHref, depth := -1, 0
+	for i, c := range text[closeLabel+2:] {
+		if c == '(' {
+			depth++
+		} else if c == ')' && depth > 0 {
+			depth--
+		} else if c == ')' {
+			closeHref = i
+			break
+		}
+	}
+	if closeHref < 0 {
+		return "", "", 0, false
+	}
+	label := text[1:closeLabel]
+	href := strings.TrimSpace(text[closeLabel+2 : closeLabel+2+closeHref])
+	return label, href, closeLabel + 3 + closeHref, true
+}
+
+func safeURL(href string) bool {
+	if href == "" || strings.ContainsAny(href, " \t\n\"'<>\\") {
+		return false
+	}
+	u, err := url.Parse(href)
+	return err == nil && safeSchemes[strings.ToLower(u.Scheme)]
+}
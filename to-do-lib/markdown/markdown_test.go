@@ -0,0 +1,57 @@
+package markdown_test
+
+import (
+	"strings"
+	"testing"
+
+	"go-to-do-app/to-do-lib/markdown"
+)
+
+func TestToHTML(t *testing.T) {
+	tests := []struct {
+		src      string
+		expected string
+	}{
+		{"plain text", "<p>plain text</p>\n"},
+		{"# Title", "<h1>Title</h1>\n"},
+		{"### Sub ###", "<h3>Sub</h3>\n"},
+		{"**bold** and *em* and _em_", "<p><strong>bold</strong> and <em>em</em> and <em>em</em></p>\n"},
+		{"a snake_case_name", "<p>a snake_case_name</p>\n"},
+		{"use `<b>` tags", "<p>use <code>&lt;b&gt;</code> tags</p>\n"},
+		{"- one\n- two", "<ul>\n<li>one</li>\n<li>two</li>\n</ul>\n"},
+		{"1. one\n2. two", "<ol>\n<li>one</li>\n<li>two</li>\n</ol>\n"},
+		{"> quoted", "<blockquote>\n<p>quoted</p>\n</blockquote>\n"},
+		{"```\nif a < b {}\n```", "<pre><code>if a &lt; b {}</code></pre>\n"},
+		{"line one\nline two\n\nnext", "<p>line one<br>\nline two</p>\n<p>next</p>\n"},
+		{"---", "<hr>\n"},
+		{"[bad](javascript:alert(1)) done", "<p>bad done</p>\n"},
+		{"[docs](https://example.com/a?b=1&c=2)", `<p><a href="https://example.com/a?b=1&amp;c=2" rel="nofollow noopener">docs</a></p>` + "\n"},
+	}
+	for _, test := range tests {
+		if actual := string(markdown.ToHTML(test.src)); actual != test.expected {
+			t.Errorf("%q Expected: %q, Got: %q", test.src, test.expected, actual)
+		}
+	}
+}
+
+func TestToHTMLSanitises(t *testing.T) {
+	tests := []string{
+		`<script>alert(1)</script>`,
+		`<img src=x onerror="alert(1)">`,
+		`[click](javascript:alert(1))`,
+		`[click](JaVaScRiPt:alert(1))`,
+		`[click](data:text/html;base64,PHNjcmlwdD4=)`,
+		`[click](&#106;avascript:alert(1))`,
+		`[click](https://example.com/"onmouseover="alert(1))`,
+		"# <iframe src=x>",
+		"- <svg onload=alert(1)>",
+	}
+	for _, src := range tests {
+		actual := string(markdown.ToHTML(src))
+		for _, forbidden := range []string{"<script", "<img", "<iframe", "<svg", "javascript:", "data:", `"onmouseover`} {
+			if strings.Contains(strings.ToLower(actual), forbidden) {
+				t.Errorf("%q rendered unsafe HTML: %s", src, actual)
+			}
+		}
+	}
+}
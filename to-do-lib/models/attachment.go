@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Attachment describes a file attached to an item. The contents are kept in a
+// blob store under the attachment's Id.
+type Attachment struct {
+	Id          uuid.UUID `json:"id"`
+	UserId      string    `json:"user_id"`
+	ItemId      uuid.UUID `json:"item_id"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+}
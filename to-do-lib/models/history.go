@@ -0,0 +1,79 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// HistoryEntry records one change to an item. Before is nil for a created
+// item & After is nil for a deleted one.
+type HistoryEntry struct {
+	Id      uuid.UUID `json:"id"`
+	UserId  string    `json:"user_id,omitempty"`
+	ItemId  uuid.UUID `json:"item_id"`
+	Action  string    `json:"action"`
+	Before  *ToDo     `json:"before,omitempty"`
+	After   *ToDo     `json:"after,omitempty"`
+	Actor   string    `json:"actor"`
+	TraceId string    `json:"trace_id"`
+	Time    time.Time `json:"time"`
+}
+
+// StatusChange is a move of an item between statuses. From is empty for the
+// status an item was created at.
+type StatusChange struct {
+	From  Status    `json:"from,omitempty"`
+	To    Status    `json:"to"`
+	Actor string    `json:"actor"`
+	Time  time.Time `json:"time"`
+}
+
+// StatusChanges picks the moves between statuses out of the history of an
+// item, oldest first. Changes made before items had a status are read from
+// whether they were complete.
+func StatusChanges(entries []HistoryEntry) []StatusChange {
+	w := CurrentWorkflow()
+	changes := []StatusChange{}
+	for _, entry := range entries {
+		if entry.After == nil {
+			continue
+		}
+		change := StatusChange{To: w.StatusOf(*entry.After), Actor: entry.Actor, Time: entry.Time}
+		if entry.Before != nil {
+			if change.From = w.StatusOf(*entry.Before); change.From == change.To {
+				continue
+			}
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// AssignmentChange is a reassignment of an item. From is empty when the item
+// was unassigned, and To when it is unassigned.
+type AssignmentChange struct {
+	From  string    `json:"from,omitempty"`
+	To    string    `json:"to,omitempty"`
+	Actor string    `json:"actor"`
+	Time  time.Time `json:"time"`
+}
+
+// AssignmentChanges picks the reassignments out of the history of an item,
+// oldest first, including it being created assigned to someone.
+func AssignmentChanges(entries []HistoryEntry) []AssignmentChange {
+	changes := []AssignmentChange{}
+	for _, entry := range entries {
+		if entry.After == nil {
+			continue
+		}
+		change := AssignmentChange{To: entry.After.Assignee, Actor: entry.Actor, Time: entry.Time}
+		if entry.Before != nil {
+			change.From = entry.Before.Assignee
+		}
+		if change.From != change.To {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
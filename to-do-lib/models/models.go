@@ -3,68 +3,136 @@ package models
 import (
 	"errors"
 	"fmt"
-	"strings"
+	"regexp"
+	"time"
+	"unicode/utf8"
 
 	todoerrors "go-to-do-app/to-do-lib/errors"
 
 	"github.com/google/uuid"
 )
 
-type priority = string
-
-const (
-	PriorityLow    priority = "Low"
-	PriorityMedium priority = "Medium"
-	PriorityHigh   priority = "High"
-)
-
 var (
 	V1 = "v1"
 	V2 = "v2"
+	// V3 sets the Status of items rather than Complete
+	V3 = "v3"
 )
 
-func ParsePriority(p string) (priority, error) {
-	if len(p) < 1 {
-		return "", fmt.Errorf("invalid priority: %s. Valid options are: %s, %s, %s", p, PriorityLow, PriorityMedium, PriorityHigh)
-	}
-	p = strings.ToUpper(string(p[0])) + strings.ToLower(p[1:])
-	switch priority(p) {
-	case PriorityLow, PriorityMedium, PriorityHigh:
-		return priority(p), nil
-	default:
-		return "", fmt.Errorf("invalid priority: %s. Valid options are: %s, %s, %s", p, PriorityLow, PriorityMedium, PriorityHigh)
-	}
-}
+// MaxDescriptionLength is the most characters a description may hold. Descriptions
+// are Markdown and only part of the v2 api.
+const MaxDescriptionLength = 10000
 
+// MaxTags is the most tags an item may have.
+const MaxTags = 20
+
+// MaxDueYear is the last year an item may be due in, the last that can be
+// written as JSON.
+const MaxDueYear = 9999
+
+// tagPattern matches a tag, a word of letters, digits, - & _ without the #
+// it is written with.
+var tagPattern = regexp.MustCompile(`^[\p{L}\p{N}_-]{1,50}$`)
+
+// ToDo is an item of a user's list. Tags, Due, the day the item is due, and
+// Assignee, the user the item is assigned to, are only part of the v2 api,
+// and Status, the step of the Workflow the item is at, of the v3 api.
+// Revision counts the changes made to it, starting at 1 when it is added; an
+// update sent with a revision is only made while the item is still at that
+// revision.
 type ToDo struct {
-	UserId   string    `json:"user_id,omitempty"`
-	Id       uuid.UUID `json:"id"`
-	Title    string    `json:"title"`
-	Priority priority  `json:"priority"`
-	Complete bool      `json:"complete"`
+	UserId      string     `json:"user_id,omitempty"`
+	Id          uuid.UUID  `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description,omitempty"`
+	Priority    Priority   `json:"priority"`
+	Complete    bool       `json:"complete"`
+	Status      Status     `json:"status,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`
+	Due         *time.Time `json:"due,omitempty"`
+	Assignee    string     `json:"assignee,omitempty"`
+	Revision    int64      `json:"revision,omitempty"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+}
+
+// ToDoRef is the body of an update, naming the item to change by its id or
+// a short id of it.
+type ToDoRef struct {
+	ToDo
+	Id string `json:"id"`
+}
+
+// ShortIdLength is the length of the short id shown for an item, the start of
+// its id. Any prefix of an id of at least MinShortIdLength characters is
+// accepted in its place while it names only one of the user's items.
+const (
+	ShortIdLength    = 8
+	MinShortIdLength = 4
+)
+
+// ShortId is the start of the item's id, short enough to type.
+func (t ToDo) ShortId() string {
+	return t.Id.String()[:ShortIdLength]
+}
+
+// Deleted reports whether the item is in the trash.
+func (t *ToDo) Deleted() bool {
+	return t.DeletedAt != nil
 }
 
 func (t *ToDo) Validate(ver string) error {
 	if t.Title == "" {
 		return &todoerrors.ValidationError{Field: "title", Err: errors.New("invalid title")}
 	}
-	p, err := ParsePriority(t.Priority)
+	p, err := ParsePriority(string(t.Priority))
 	if err != nil {
 		return &todoerrors.ValidationError{Field: "priority", Err: err}
 	}
 	t.Priority = p
+	if n := utf8.RuneCountInString(t.Description); n > MaxDescriptionLength {
+		return &todoerrors.ValidationError{Field: "description", Err: fmt.Errorf("description is %d characters, the limit is %d", n, MaxDescriptionLength)}
+	}
+	if len(t.Tags) > MaxTags {
+		return &todoerrors.ValidationError{Field: "tags", Err: fmt.Errorf("%d tags given, the limit is %d", len(t.Tags), MaxTags)}
+	}
+	for _, tag := range t.Tags {
+		if !tagPattern.MatchString(tag) {
+			return &todoerrors.ValidationError{Field: "tags", Err: fmt.Errorf("invalid tag %q, use up to 50 letters, digits, - and _", tag)}
+		}
+	}
+	if t.Due != nil && (t.Due.Year() < 1 || t.Due.Year() > MaxDueYear) {
+		return &todoerrors.ValidationError{Field: "due", Err: fmt.Errorf("due must be between the years 1 and %d", MaxDueYear)}
+	}
 	switch ver {
 	case V1:
 		if t.UserId != "" {
 			return &todoerrors.ValidationError{Field: fmt.Sprintf("user_id: %s", t.UserId), Err: errors.New("v1 todo api does not allow user_id")}
 		}
-	case V2:
+		if t.Description != "" {
+			return &todoerrors.ValidationError{Field: "description", Err: errors.New("v1 todo api does not allow description")}
+		}
+		if len(t.Tags) > 0 || t.Due != nil {
+			return &todoerrors.ValidationError{Field: "tags", Err: errors.New("v1 todo api does not allow tags or due")}
+		}
+		if t.Assignee != "" {
+			return &todoerrors.ValidationError{Field: "assignee", Err: errors.New("v1 todo api does not allow assignee")}
+		}
+	case V2, V3:
 		if t.UserId == "" {
 			return &todoerrors.ValidationError{Field: fmt.Sprintf("user_id: %s", t.UserId), Err: errors.New("invalid user_id")}
 		}
 	default:
 		return &todoerrors.NotFoundError{Message: fmt.Sprintf("%d not a valid version", t.Id.Version())}
 	}
+	if ver == V3 {
+		// Complete follows the status
+		if w := CurrentWorkflow(); t.Status != "" && !w.Has(t.Status) {
+			return unknownStatusError(w, t.Status)
+		}
+	} else {
+		// v1 & v2 clients set Complete, which moves the item to a status
+		t.Status = ""
+	}
 	return nil
 }
 
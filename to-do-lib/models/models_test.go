@@ -1,6 +1,9 @@
 package models_test
 
 import (
+	"encoding/json"
+	"reflect"
+	"strings"
 	"testing"
 
 	"go-to-do-app/to-do-lib/models"
@@ -33,3 +36,253 @@ func TestParsePriorityErrorsWithInvalidString(t *testing.T) {
 		t.Errorf("Expected parser to fail given an input of %s, but returned %s", input, ret)
 	}
 }
+
+func TestValidateDescriptionLength(t *testing.T) {
+	item := models.ToDo{UserId: "TestToDoUser", Title: "test", Priority: "Low", Description: strings.Repeat("é", models.MaxDescriptionLength)}
+	if err := item.Validate(models.V2); err != nil {
+		t.Errorf("Expected description at the limit to be valid, Got: %v", err)
+	}
+	item.Description += "!"
+	if err := item.Validate(models.V2); err == nil {
+		t.Errorf("Expected description over the limit to be rejected")
+	}
+}
+
+func TestValidateRejectsDescriptionInV1(t *testing.T) {
+	item := models.ToDo{Title: "test", Priority: "Low", Description: "notes"}
+	if err := item.Validate(models.V1); err == nil {
+		t.Errorf("Expected v1 item with a description to be rejected")
+	}
+}
+
+func TestParsePriorityByRank(t *testing.T) {
+	p, err := models.ParsePriority("30")
+	if err != nil || p != models.PriorityHigh {
+		t.Errorf("Expected: %s, Got: %s (%v)", models.PriorityHigh, p, err)
+	}
+	if p, err := models.ParsePriority("15"); err == nil {
+		t.Errorf("Expected an unknown rank to fail, Got: %s", p)
+	}
+}
+
+func TestConfiguredPriorityLevels(t *testing.T) {
+	defer models.SetPriorityLevels(models.DefaultPriorityLevels)
+	levels, err := models.ParsePriorityLevels([]string{"Low", "Medium", "High", "Urgent=100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := models.SetPriorityLevels(levels); err != nil {
+		t.Fatal(err)
+	}
+	p, err := models.ParsePriority("urgent")
+	if err != nil || p != "Urgent" || p.Rank() != 100 {
+		t.Errorf("Expected: Urgent ranked 100, Got: %s ranked %d (%v)", p, p.Rank(), err)
+	}
+	if models.ComparePriority("Urgent", models.PriorityHigh) <= 0 {
+		t.Errorf("Expected Urgent to rank above High")
+	}
+	if models.Priority("Critical").Rank() != 0 {
+		t.Errorf("Expected an unknown priority to rank 0")
+	}
+	item, err := models.ParseQuickAdd("ship it !!!!", quickAddNow)
+	if err != nil || item.Priority != "Urgent" {
+		t.Errorf("Expected: Urgent, Got: %s (%v)", item.Priority, err)
+	}
+}
+
+func TestSetPriorityLevelsRejectsInvalidLevels(t *testing.T) {
+	defer models.SetPriorityLevels(models.DefaultPriorityLevels)
+	invalid := [][]models.PriorityLevel{
+		nil,
+		{{Name: "Low", Rank: 1}, {Name: "low", Rank: 2}},
+		{{Name: "Low", Rank: 1}, {Name: "High", Rank: 1}},
+		{{Name: "Low", Rank: 0}},
+		{{Name: "10", Rank: 10}},
+		{{Name: "Very High", Rank: 10}},
+	}
+	for _, levels := range invalid {
+		if err := models.SetPriorityLevels(levels); err == nil {
+			t.Errorf("Expected %+v to be rejected", levels)
+		}
+	}
+	if _, err := models.ParsePriorityLevels([]string{"Low=x"}); err == nil {
+		t.Errorf("Expected an invalid rank to be rejected")
+	}
+}
+
+func TestUnmarshalPriority(t *testing.T) {
+	tests := []struct {
+		json     string
+		priority models.Priority
+	}{
+		{`{"priority": "high"}`, models.PriorityHigh},
+		{`{"priority": "Medium"}`, models.PriorityMedium},
+		{`{"priority": 10}`, models.PriorityLow},
+	}
+	for _, test := range tests {
+		var item models.ToDo
+		if err := json.Unmarshal([]byte(test.json), &item); err != nil {
+			t.Errorf("%s: unexpected error %v", test.json, err)
+			continue
+		}
+		item.UserId, item.Title = "TestToDoUser", "test"
+		if err := item.Validate(models.V2); err != nil || item.Priority != test.priority {
+			t.Errorf("%s: Expected: %s, Got: %s (%v)", test.json, test.priority, item.Priority, err)
+		}
+	}
+	var item models.ToDo
+	if err := json.Unmarshal([]byte(`{"priority": true}`), &item); err == nil {
+		t.Errorf("Expected a boolean priority to be rejected")
+	}
+	out, _ := json.Marshal(models.ToDo{Priority: models.PriorityHigh})
+	if !strings.Contains(string(out), `"priority":"High"`) {
+		t.Errorf("Expected priority marshalled by name, Got: %s", out)
+	}
+}
+
+func TestValidateStatusInV3(t *testing.T) {
+	item := models.ToDo{UserId: "TestToDoUser", Title: "test", Priority: "Low", Status: models.StatusBlocked}
+	if err := item.Validate(models.V3); err != nil || item.Status != models.StatusBlocked {
+		t.Errorf("Expected: %v, Got: %v %v", models.StatusBlocked, item.Status, err)
+	}
+	item.Status = "waiting"
+	if err := item.Validate(models.V3); err == nil {
+		t.Errorf("Expected unknown status to be rejected")
+	}
+	if err := item.Validate(models.V2); err != nil || item.Status != "" {
+		t.Errorf("Expected v2 item to have its status cleared, Got: %v %v", item.Status, err)
+	}
+}
+
+func TestWorkflowMove(t *testing.T) {
+	w := models.DefaultWorkflow
+	before := models.ToDo{Title: "test", Status: models.StatusBacklog}
+	tests := []struct {
+		item     models.ToDo
+		expected models.Status
+		complete bool
+		valid    bool
+	}{
+		{models.ToDo{Status: models.StatusInProgress}, models.StatusInProgress, false, true},
+		{models.ToDo{Status: models.StatusDone}, models.StatusDone, true, true},
+		{models.ToDo{Status: models.StatusBlocked}, "", false, false},
+		{models.ToDo{Status: "waiting"}, "", false, false},
+		{models.ToDo{Complete: true}, models.StatusDone, true, true},
+		{models.ToDo{Status: models.StatusBacklog, Complete: true}, models.StatusDone, true, true},
+		{models.ToDo{}, models.StatusBacklog, false, true},
+	}
+	for _, test := range tests {
+		item := test.item
+		err := w.Move(before, &item)
+		if (err == nil) != test.valid {
+			t.Errorf("Expected move to %q valid: %v, Got: %v", test.item.Status, test.valid, err)
+			continue
+		}
+		if test.valid && (item.Status != test.expected || item.Complete != test.complete) {
+			t.Errorf("Expected: %v %v, Got: %v %v", test.expected, test.complete, item.Status, item.Complete)
+		}
+	}
+	// a v1 or v2 client reopening a done item moves it back to the start
+	done := models.ToDo{Status: models.StatusDone, Complete: true}
+	item := models.ToDo{Complete: false}
+	if err := w.Move(done, &item); err != nil || item.Status != models.StatusBacklog {
+		t.Errorf("Expected: %v, Got: %v %v", models.StatusBacklog, item.Status, err)
+	}
+}
+
+func TestParseWorkflow(t *testing.T) {
+	w, err := models.ParseWorkflow("todo", []string{"shipped", "dropped"}, []string{"todo>doing", "doing > shipped", "todo>dropped"})
+	if err != nil {
+		t.Fatalf("Expected workflow to parse, Got: %v", err)
+	}
+	expected := []models.Status{"todo", "doing", "shipped", "dropped"}
+	if actual := w.Statuses(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected: %+v, Got: %+v", expected, actual)
+	}
+	if !w.Allows("doing", "shipped") || w.Allows("todo", "shipped") {
+		t.Errorf("Expected only the given transitions to be allowed, Got: %+v", w.Transitions)
+	}
+	invalid := []struct {
+		initial     string
+		done        []string
+		transitions []string
+	}{
+		{"", []string{"done"}, nil},
+		{"todo", nil, nil},
+		{"todo", []string{"todo"}, nil},
+		{"todo", []string{"done"}, []string{"todo-done"}},
+		{"todo", []string{"done"}, []string{"todo>todo"}},
+		{"To Do", []string{"done"}, nil},
+	}
+	for _, test := range invalid {
+		if _, err := models.ParseWorkflow(test.initial, test.done, test.transitions); err == nil {
+			t.Errorf("Expected %+v to be rejected", test)
+		}
+	}
+}
+
+func TestStatusChanges(t *testing.T) {
+	created := models.ToDo{Title: "test", Status: models.StatusBacklog}
+	renamed := models.ToDo{Title: "renamed", Status: models.StatusBacklog}
+	started := models.ToDo{Title: "renamed", Status: models.StatusInProgress}
+	legacy := models.ToDo{Title: "renamed", Complete: true}
+	entries := []models.HistoryEntry{
+		{Action: "create", After: &created, Actor: "a"},
+		{Action: "update", Before: &created, After: &renamed, Actor: "b"},
+		{Action: "update", Before: &renamed, After: &started, Actor: "c"},
+		{Action: "update", Before: &started, After: &legacy, Actor: "d"},
+		{Action: "delete", Before: &legacy, Actor: "e"},
+	}
+	expected := []models.StatusChange{
+		{To: models.StatusBacklog, Actor: "a"},
+		{From: models.StatusBacklog, To: models.StatusInProgress, Actor: "c"},
+		{From: models.StatusInProgress, To: models.StatusDone, Actor: "d"},
+	}
+	if actual := models.StatusChanges(entries); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected: %+v, Got: %+v", expected, actual)
+	}
+}
+
+func TestShareValidate(t *testing.T) {
+	valid := models.Share{OwnerId: "Owner", Collaborator: "Collaborator", Role: models.RoleEditor}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Expected %+v to be valid, Got: %v", valid, err)
+	}
+	invalid := []models.Share{
+		{Collaborator: "Collaborator", Role: models.RoleEditor},
+		{OwnerId: "Owner", Role: models.RoleEditor},
+		{OwnerId: "Owner", Collaborator: "Owner", Role: models.RoleEditor},
+		{OwnerId: "Owner", Collaborator: "Collaborator", Role: models.RoleOwner},
+		{OwnerId: "Owner", Collaborator: "Collaborator", Role: "admin"},
+	}
+	for _, share := range invalid {
+		if err := share.Validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected", share)
+		}
+	}
+	if !models.RoleEditor.Allows(models.RoleViewer) || models.RoleViewer.Allows(models.RoleEditor) || models.Role("").Allows(models.RoleViewer) {
+		t.Errorf("Expected roles to allow only what lower roles may do")
+	}
+}
+
+func TestAssignmentChanges(t *testing.T) {
+	created := models.ToDo{Title: "test", Assignee: "x"}
+	renamed := models.ToDo{Title: "renamed", Assignee: "x"}
+	reassigned := models.ToDo{Title: "renamed", Assignee: "y"}
+	unassigned := models.ToDo{Title: "renamed"}
+	entries := []models.HistoryEntry{
+		{Action: "create", After: &created, Actor: "a"},
+		{Action: "update", Before: &created, After: &renamed, Actor: "b"},
+		{Action: "update", Before: &renamed, After: &reassigned, Actor: "c"},
+		{Action: "update", Before: &reassigned, After: &unassigned, Actor: "d"},
+		{Action: "delete", Before: &unassigned, Actor: "e"},
+	}
+	expected := []models.AssignmentChange{
+		{To: "x", Actor: "a"},
+		{From: "x", To: "y", Actor: "c"},
+		{From: "y", Actor: "d"},
+	}
+	if actual := models.AssignmentChanges(entries); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected: %+v, Got: %+v", expected, actual)
+	}
+}
@@ -0,0 +1,176 @@
+package models
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Priority is how pressing an item is, the name of one of the priority levels
+// of the deployment. Levels are ordered by rank, a higher rank being more
+// pressing, so items can be sorted by priority.
+type Priority string
+
+const (
+	PriorityLow    Priority = "Low"
+	PriorityMedium Priority = "Medium"
+	PriorityHigh   Priority = "High"
+)
+
+// PriorityLevel is a priority a deployment offers, and its rank.
+type PriorityLevel struct {
+	Name Priority `json:"name"`
+	Rank int      `json:"rank"`
+}
+
+// DefaultPriorityLevels are the levels of a deployment that does not set its
+// own.
+var DefaultPriorityLevels = []PriorityLevel{{PriorityLow, 10}, {PriorityMedium, 20}, {PriorityHigh, 30}}
+
+// priorityNamePattern matches the name of a level, which starts with a letter
+// so it cannot be mistaken for a rank.
+var priorityNamePattern = regexp.MustCompile(`^\p{L}[\p{L}\p{N}_-]{0,29}$`)
+
+var (
+	levelsMut sync.RWMutex
+	levels    = DefaultPriorityLevels
+)
+
+// SetPriorityLevels replaces the priority levels of the deployment. Names must
+// be unique regardless of case, and ranks unique & positive. Items keep the
+// priority they were saved with, so a level that is removed or renamed stops
+// being valid for them.
+func SetPriorityLevels(l []PriorityLevel) error {
+	l, err := checkPriorityLevels(l)
+	if err != nil {
+		return err
+	}
+	levelsMut.Lock()
+	defer levelsMut.Unlock()
+	levels = l
+	return nil
+}
+
+// checkPriorityLevels returns a copy of l sorted by rank, if it is valid.
+func checkPriorityLevels(l []PriorityLevel) ([]PriorityLevel, error) {
+	if len(l) == 0 {
+		return nil, errors.New("at least one priority level is required")
+	}
+	l = slices.Clone(l)
+	slices.SortFunc(l, func(a, b PriorityLevel) int { return cmp.Compare(a.Rank, b.Rank) })
+	for i, level := range l {
+		if !priorityNamePattern.MatchString(string(level.Name)) {
+			return nil, fmt.Errorf("invalid priority name %q, use up to 30 letters, digits, - and _ starting with a letter", level.Name)
+		}
+		if level.Rank < 1 {
+			return nil, fmt.Errorf("rank of priority %s must be positive, got %d", level.Name, level.Rank)
+		}
+		for _, other := range l[:i] {
+			if other.Rank == level.Rank {
+				return nil, fmt.Errorf("priorities %s and %s have the same rank %d", other.Name, level.Name, level.Rank)
+			}
+			if strings.EqualFold(string(other.Name), string(level.Name)) {
+				return nil, fmt.Errorf("priority %s is given twice", level.Name)
+			}
+		}
+	}
+	return l, nil
+}
+
+// PriorityLevels returns the priority levels of the deployment, least pressing
+// first.
+func PriorityLevels() []PriorityLevel {
+	levelsMut.RLock()
+	defer levelsMut.RUnlock()
+	return slices.Clone(levels)
+}
+
+// PriorityNames returns the names of the priority levels, least pressing first.
+func PriorityNames() []string {
+	var names []string
+	for _, level := range PriorityLevels() {
+		names = append(names, string(level.Name))
+	}
+	return names
+}
+
+// ParsePriorityLevels reads and checks levels written as name=rank, such as
+// Urgent=40. A level written as only a name is ranked 10 above the one before
+// it, so "Low,Medium,High" gives the default levels.
+func ParsePriorityLevels(specs []string) ([]PriorityLevel, error) {
+	var l []PriorityLevel
+	rank := 0
+	for _, spec := range specs {
+		name, rankText, ranked := strings.Cut(strings.TrimSpace(spec), "=")
+		if ranked {
+			var err error
+			if rank, err = strconv.Atoi(strings.TrimSpace(rankText)); err != nil {
+				return nil, fmt.Errorf("invalid rank in priority level %q", spec)
+			}
+		} else {
+			rank += 10
+		}
+		l = append(l, PriorityLevel{Name: Priority(strings.TrimSpace(name)), Rank: rank})
+	}
+	return checkPriorityLevels(l)
+}
+
+// ParsePriority returns the level named p, in any case, or ranked p.
+func ParsePriority(p string) (Priority, error) {
+	l := PriorityLevels()
+	for _, level := range l {
+		if strings.EqualFold(string(level.Name), p) {
+			return level.Name, nil
+		}
+	}
+	if rank, err := strconv.Atoi(p); err == nil {
+		for _, level := range l {
+			if level.Rank == rank {
+				return level.Name, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("invalid priority: %s. Valid options are: %s", p, strings.Join(PriorityNames(), ", "))
+}
+
+// Rank returns the rank of the priority, or 0 when it is not one of the
+// levels of the deployment.
+func (p Priority) Rank() int {
+	for _, level := range PriorityLevels() {
+		if strings.EqualFold(string(level.Name), string(p)) {
+			return level.Rank
+		}
+	}
+	return 0
+}
+
+// ComparePriority orders a and b by rank, the less pressing first.
+func ComparePriority(a, b Priority) int {
+	return cmp.Compare(a.Rank(), b.Rank())
+}
+
+// UnmarshalJSON accepts a priority by name, as older clients send it, or by
+// rank. Either is checked against the levels by ToDo.Validate.
+func (p *Priority) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	var rank json.Number
+	if !bytes.HasPrefix(data, []byte(`"`)) && json.Unmarshal(data, &rank) == nil {
+		*p = Priority(rank.String())
+		return nil
+	}
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return errors.New("priority must be a name or a rank")
+	}
+	*p = Priority(name)
+	return nil
+}
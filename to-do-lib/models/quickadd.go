@@ -0,0 +1,277 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	todoerrors "go-to-do-app/to-do-lib/errors"
+)
+
+// MaxQuickAddLength is the most characters quick add text may hold.
+const MaxQuickAddLength = 1000
+
+// maxDuePhraseWords is the most words a due date phrase takes, as in
+// "due in 3 days".
+const maxDuePhraseWords = 4
+
+// quickAddTag matches a tag written in quick add text, which unlike a tag in
+// general must start with a letter so that "#1" stays in the title.
+var quickAddTag = regexp.MustCompile(`^#(\p{L}[\p{L}\p{N}_-]*)$`)
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "sun": time.Sunday,
+	"monday": time.Monday, "mon": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday, "tues": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday,
+	"saturday": time.Saturday, "sat": time.Saturday,
+}
+
+// dueIntroductions may start a due date, as in "by friday", and are only
+// taken out of the title along with one.
+var dueIntroductions = map[string]bool{"due": true, "by": true, "on": true}
+
+// quickAddToken is a word of quick add text. Escaped words, written with a
+// leading \, are always kept in the title.
+type quickAddToken struct {
+	text    string
+	escaped bool
+	// marker is set for priority markers & tags, which end a due date phrase
+	marker bool
+}
+
+// ParseQuickAdd reads an item from a line of text, such as
+// "pay rent tomorrow !high #home", taking out of the title:
+//
+//   - priority markers, "!" followed by a priority or the start of one, such
+//     as !high or !h, or "!!" for the second level, Medium by default, "!!!"
+//     for the third & so on
+//   - tags, "#" followed by a word starting with a letter
+//   - a due date, see ParseDue, optionally introduced by "due", "by" or
+//     "on". Weekday abbreviations like "fri" are only read as dates after
+//     one of those, or "next", as they are also words. When there are
+//     several, the last one is the due date and the others stay in the title.
+//
+// Relative dates are counted from now, in its location. Words written with a
+// leading \, like \#1 or \tomorrow, stay in the title without the \. The
+// item has the least pressing priority unless a marker gives another.
+func ParseQuickAdd(text string, now time.Time) (ToDo, error) {
+	if utf8.RuneCountInString(text) > MaxQuickAddLength {
+		return ToDo{}, &todoerrors.ValidationError{Field: "text", Err: fmt.Errorf("text must be at most %d characters", MaxQuickAddLength)}
+	}
+	item := ToDo{Priority: PriorityLevels()[0].Name}
+	var tokens []quickAddToken
+	for _, word := range strings.Fields(text) {
+		if literal, escaped := strings.CutPrefix(word, `\`); escaped && literal != "" {
+			tokens = append(tokens, quickAddToken{text: literal, escaped: true})
+			continue
+		}
+		if p, ok := parsePriorityMarker(word); ok {
+			item.Priority = p
+			tokens = append(tokens, quickAddToken{marker: true})
+			continue
+		}
+		if match := quickAddTag.FindStringSubmatch(word); match != nil {
+			item.Tags = appendTag(item.Tags, strings.ToLower(match[1]))
+			tokens = append(tokens, quickAddToken{marker: true})
+			continue
+		}
+		tokens = append(tokens, quickAddToken{text: word})
+	}
+
+	// find the last due date phrase, which may not span an escaped word or
+	// a marker
+	dueStart, dueEnd := -1, -1
+	var due time.Time
+	for i := 0; i < len(tokens); i++ {
+		var words []string
+		for _, token := range tokens[i:min(i+maxDuePhraseWords, len(tokens))] {
+			if token.escaped || token.marker {
+				break
+			}
+			words = append(words, strings.ToLower(token.text))
+		}
+		if date, n := matchDue(words, now); n > 0 {
+			due, dueStart, dueEnd = date, i, i+n
+			i += n - 1
+		}
+	}
+	if dueStart >= 0 {
+		item.Due = &due
+	}
+
+	var title []string
+	for i, token := range tokens {
+		if !token.marker && (i < dueStart || i >= dueEnd) {
+			title = append(title, token.text)
+		}
+	}
+	item.Title = strings.Join(title, " ")
+	if item.Title == "" {
+		return ToDo{}, &todoerrors.ValidationError{Field: "title", Err: errors.New("no title left once the priority, tags & due date are taken out")}
+	}
+	return item, nil
+}
+
+// ParseDue reads a due date, one of:
+//
+//   - "today", "tonight" or "tomorrow"
+//   - a weekday, such as "friday" or "fri", or "next friday", meaning the
+//     coming one, a week ahead when it is today
+//   - "next week", "next month" or "next year"
+//   - "in 3 days", "in a week", "in 2 months" or "in 1 year"
+//   - a date as 2006-01-02
+//
+// The due date is the start of the day in the location of now.
+func ParseDue(phrase string, now time.Time) (time.Time, error) {
+	words := strings.Fields(strings.ToLower(phrase))
+	due, n := matchDate(words, now, true)
+	if n == 0 || n != len(words) {
+		return time.Time{}, &todoerrors.ValidationError{
+			Field: "due",
+			Err:   fmt.Errorf("unknown due date %q, try today, tomorrow, friday, next week, in 3 days or 2006-01-02", phrase),
+		}
+	}
+	return due, nil
+}
+
+// matchDue matches a due date phrase at the start of words, which are in
+// lowercase, returning the date and the number of words it takes.
+func matchDue(words []string, now time.Time) (time.Time, int) {
+	if len(words) > 0 && dueIntroductions[words[0]] {
+		if due, n := matchDate(words[1:], now, true); n > 0 {
+			return due, n + 1
+		}
+		return time.Time{}, 0
+	}
+	return matchDate(words, now, false)
+}
+
+// matchDate matches a date at the start of words. Weekday abbreviations are
+// only matched when introduced, as by "on" or "next".
+func matchDate(words []string, now time.Time, introduced bool) (time.Time, int) {
+	if len(words) == 0 {
+		return time.Time{}, 0
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch words[0] {
+	case "today", "tonight":
+		return today, 1
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), 1
+	case "next":
+		if len(words) < 2 {
+			return time.Time{}, 0
+		}
+		if day, ok := weekdays[words[1]]; ok {
+			return comingWeekday(today, day), 2
+		}
+		if years, months, days, ok := dateUnit(1, words[1]); ok {
+			return inDueRange(today.AddDate(years, months, days), 2)
+		}
+		return time.Time{}, 0
+	case "in":
+		if len(words) < 3 {
+			return time.Time{}, 0
+		}
+		count, ok := dateCount(words[1])
+		if !ok {
+			return time.Time{}, 0
+		}
+		if years, months, days, ok := dateUnit(count, words[2]); ok {
+			return inDueRange(today.AddDate(years, months, days), 3)
+		}
+		return time.Time{}, 0
+	}
+	if day, ok := weekdays[words[0]]; ok && (introduced || words[0] == strings.ToLower(day.String())) {
+		return comingWeekday(today, day), 1
+	}
+	if date, err := time.ParseInLocation(time.DateOnly, words[0], now.Location()); err == nil {
+		return date, 1
+	}
+	return time.Time{}, 0
+}
+
+// inDueRange returns the date counted ahead and the n words it took, or no
+// match when the date is after MaxDueYear.
+func inDueRange(date time.Time, n int) (time.Time, int) {
+	if date.Year() > MaxDueYear {
+		return time.Time{}, 0
+	}
+	return date, n
+}
+
+// comingWeekday is the next day after today falling on a weekday.
+func comingWeekday(today time.Time, day time.Weekday) time.Time {
+	days := (int(day)-int(today.Weekday())+6)%7 + 1
+	return today.AddDate(0, 0, days)
+}
+
+// dateCount reads the count of "in 3 days", which may also be "a" or "an".
+func dateCount(word string) (int, bool) {
+	if word == "a" || word == "an" {
+		return 1, true
+	}
+	n, err := strconv.Atoi(word)
+	if err != nil || n < 1 || n > 9999 {
+		return 0, false
+	}
+	return n, true
+}
+
+// dateUnit returns the years, months & days of count of a unit, such as
+// 3 weeks.
+func dateUnit(count int, unit string) (int, int, int, bool) {
+	switch unit {
+	case "day", "days":
+		return 0, 0, count, true
+	case "week", "weeks":
+		return 0, 0, 7 * count, true
+	case "month", "months":
+		return 0, count, 0, true
+	case "year", "years":
+		return count, 0, 0, true
+	}
+	return 0, 0, 0, false
+}
+
+// parsePriorityMarker reads a quick add priority marker, such as !high, !h
+// or !!!, which counts the priority levels up from the least pressing.
+func parsePriorityMarker(word string) (Priority, bool) {
+	marker, found := strings.CutPrefix(word, "!")
+	if !found || marker == "" {
+		return "", false
+	}
+	levels := PriorityLevels()
+	if strings.Trim(marker, "!") == "" {
+		bangs := len(marker) + 1
+		if bangs > len(levels) {
+			return "", false
+		}
+		return levels[bangs-1].Name, true
+	}
+	var match Priority
+	matches := 0
+	for _, level := range levels {
+		if strings.HasPrefix(strings.ToLower(string(level.Name)), strings.ToLower(marker)) {
+			match = level.Name
+			matches++
+		}
+	}
+	return match, matches == 1
+}
+
+func appendTag(tags []string, tag string) []string {
+	for _, t := range tags {
+		if t == tag {
+			return tags
+		}
+	}
+	return append(tags, tag)
+}
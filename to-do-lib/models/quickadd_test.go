@@ -0,0 +1,248 @@
+package models_test
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go-to-do-app/to-do-lib/models"
+)
+
+// quickAddNow is a Wednesday evening
+var quickAddNow = time.Date(2024, time.May, 15, 18, 30, 0, 0, time.UTC)
+
+func day(year int, month time.Month, d int) *time.Time {
+	date := time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+	return &date
+}
+
+func TestParseQuickAdd(t *testing.T) {
+	tests := []struct {
+		text     string
+		title    string
+		priority models.Priority
+		tags     []string
+		due      *time.Time
+	}{
+		{"buy milk", "buy milk", "Low", nil, nil},
+		{"  buy   milk  ", "buy milk", "Low", nil, nil},
+		{"pay rent tomorrow !high #home", "pay rent", "High", []string{"home"}, day(2024, 5, 16)},
+
+		// priority markers
+		{"call mom !high", "call mom", "High", nil, nil},
+		{"call mom !HIGH", "call mom", "High", nil, nil},
+		{"!medium call mom", "call mom", "Medium", nil, nil},
+		{"call !low mom", "call mom", "Low", nil, nil},
+		{"call mom !h", "call mom", "High", nil, nil},
+		{"call mom !m", "call mom", "Medium", nil, nil},
+		{"call mom !med", "call mom", "Medium", nil, nil},
+		{"call mom !!", "call mom", "Medium", nil, nil},
+		{"call mom !!!", "call mom", "High", nil, nil},
+		{"call mom !low !high", "call mom", "High", nil, nil},
+		{"call mom !!!!", "call mom !!!!", "Low", nil, nil},
+		{"call mom !", "call mom !", "Low", nil, nil},
+		{"call mom !urgent", "call mom !urgent", "Low", nil, nil},
+		{"wow! great", "wow! great", "Low", nil, nil},
+
+		// tags
+		{"buy milk #groceries", "buy milk", "Low", []string{"groceries"}, nil},
+		{"#errands buy milk #Groceries", "buy milk", "Low", []string{"errands", "groceries"}, nil},
+		{"buy milk #shop #shop #SHOP", "buy milk", "Low", []string{"shop"}, nil},
+		{"fix bug #v2-api #team_a", "fix bug", "Low", []string{"v2-api", "team_a"}, nil},
+		{"watch #1 movie", "watch #1 movie", "Low", nil, nil},
+		{"email # later", "email # later", "Low", nil, nil},
+		{"see c# docs", "see c# docs", "Low", nil, nil},
+		{"café #déjà", "café", "Low", []string{"déjà"}, nil},
+
+		// due dates
+		{"pay rent today", "pay rent", "Low", nil, day(2024, 5, 15)},
+		{"pay rent tonight", "pay rent", "Low", nil, day(2024, 5, 15)},
+		{"pay rent TOMORROW", "pay rent", "Low", nil, day(2024, 5, 16)},
+		{"tomorrow pay rent", "pay rent", "Low", nil, day(2024, 5, 16)},
+		{"pay rent friday", "pay rent", "Low", nil, day(2024, 5, 17)},
+		{"pay rent Friday", "pay rent", "Low", nil, day(2024, 5, 17)},
+		{"pay rent monday", "pay rent", "Low", nil, day(2024, 5, 20)},
+		{"pay rent wednesday", "pay rent", "Low", nil, day(2024, 5, 22)},
+		{"pay rent next friday", "pay rent", "Low", nil, day(2024, 5, 17)},
+		{"pay rent next fri", "pay rent", "Low", nil, day(2024, 5, 17)},
+		{"pay rent next week", "pay rent", "Low", nil, day(2024, 5, 22)},
+		{"pay rent next month", "pay rent", "Low", nil, day(2024, 6, 15)},
+		{"pay rent next year", "pay rent", "Low", nil, day(2025, 5, 15)},
+		{"pay rent in 3 days", "pay rent", "Low", nil, day(2024, 5, 18)},
+		{"pay rent in 1 day", "pay rent", "Low", nil, day(2024, 5, 16)},
+		{"pay rent in a week", "pay rent", "Low", nil, day(2024, 5, 22)},
+		{"pay rent in 2 weeks", "pay rent", "Low", nil, day(2024, 5, 29)},
+		{"pay rent in a year", "pay rent", "Low", nil, day(2025, 5, 15)},
+		{"pay rent in 20 days", "pay rent", "Low", nil, day(2024, 6, 4)},
+		{"pay rent in 2 months", "pay rent", "Low", nil, day(2024, 7, 15)},
+		{"pay rent 2024-06-01", "pay rent", "Low", nil, day(2024, 6, 1)},
+		{"pay rent by friday", "pay rent", "Low", nil, day(2024, 5, 17)},
+		{"pay rent on fri", "pay rent", "Low", nil, day(2024, 5, 17)},
+		{"pay rent due tomorrow", "pay rent", "Low", nil, day(2024, 5, 16)},
+		{"pay rent on 2024-06-01", "pay rent", "Low", nil, day(2024, 6, 1)},
+		{"pay rent by in 3 days", "pay rent", "Low", nil, day(2024, 5, 18)},
+
+		// words that only look like dates
+		{"buy sun cream", "buy sun cream", "Low", nil, nil},
+		{"plan wed party", "plan wed party", "Low", nil, nil},
+		{"put coins in 3 jars", "put coins in 3 jars", "Low", nil, nil},
+		{"put coins in jars", "put coins in jars", "Low", nil, nil},
+		{"read the next chapter", "read the next chapter", "Low", nil, nil},
+		{"pay due bill", "pay due bill", "Low", nil, nil},
+		{"stand by me", "stand by me", "Low", nil, nil},
+		{"sleep in 0 days", "sleep in 0 days", "Low", nil, nil},
+		{"pay rent 2024-13-01", "pay rent 2024-13-01", "Low", nil, nil},
+		{"film next", "film next", "Low", nil, nil},
+		{"count in", "count in", "Low", nil, nil},
+
+		// the last due date wins, the others staying in the title
+		{"plan today's talk tomorrow", "plan today's talk", "Low", nil, day(2024, 5, 16)},
+		{"move today to friday", "move today to", "Low", nil, day(2024, 5, 17)},
+
+		// escaped words
+		{`watch \tomorrow never dies`, "watch tomorrow never dies", "Low", nil, nil},
+		{`tweet \#1 \!high`, "tweet #1 !high", "Low", nil, nil},
+		{`sort by \friday`, "sort by friday", "Low", nil, nil},
+		{`pay \in 3 days`, "pay in 3 days", "Low", nil, nil},
+		{`path a\b`, `path a\b`, "Low", nil, nil},
+		{`lone \ slash`, `lone \ slash`, "Low", nil, nil},
+
+		// markers end a due date phrase
+		{"call in #home 3 days", "call in 3 days", "Low", []string{"home"}, nil},
+		{"call next !high friday", "call next", "High", nil, day(2024, 5, 17)},
+
+		// everything together
+		{"!!! #work #urgent ship release by next friday", "ship release", "High", []string{"work", "urgent"}, day(2024, 5, 17)},
+		{"dentist in 2 weeks !m #health", "dentist", "Medium", []string{"health"}, day(2024, 5, 29)},
+	}
+	for _, test := range tests {
+		item, err := models.ParseQuickAdd(test.text, quickAddNow)
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", test.text, err)
+			continue
+		}
+		expected := models.ToDo{Title: test.title, Priority: test.priority, Tags: test.tags, Due: test.due}
+		if !reflect.DeepEqual(item, expected) {
+			t.Errorf("%q: Expected: %+v, Got: %+v", test.text, describe(expected), describe(item))
+		}
+	}
+}
+
+func TestParseQuickAddRejectsEmptyTitle(t *testing.T) {
+	for _, text := range []string{"", "   ", "!high", "#home tomorrow", "!!! #a by friday"} {
+		if item, err := models.ParseQuickAdd(text, quickAddNow); err == nil {
+			t.Errorf("%q: Expected an error, Got: %+v", text, item)
+		}
+	}
+}
+
+func TestParseQuickAddRejectsLongText(t *testing.T) {
+	text := strings.Repeat("a ", models.MaxQuickAddLength/2) + "b"
+	if item, err := models.ParseQuickAdd(text, quickAddNow); err == nil {
+		t.Errorf("Expected an error, Got: %+v", item)
+	}
+	text = strings.Repeat("in ", (models.MaxQuickAddLength-10)/3) + "tomorrow"
+	if item, err := models.ParseQuickAdd(text, quickAddNow); err != nil || !reflect.DeepEqual(item.Due, day(2024, 5, 16)) {
+		t.Errorf("Expected: %v, Got: %+v (%v)", day(2024, 5, 16), item.Due, err)
+	}
+}
+
+func TestParseQuickAddUsesLocationOfNow(t *testing.T) {
+	tokyo := time.FixedZone("JST", 9*60*60)
+	// still Wednesday in UTC, already Thursday in Tokyo
+	now := time.Date(2024, time.May, 15, 20, 0, 0, 0, time.UTC).In(tokyo)
+	item, err := models.ParseQuickAdd("call home tomorrow", now)
+	expected := time.Date(2024, time.May, 17, 0, 0, 0, 0, tokyo)
+	if err != nil || item.Due == nil || !item.Due.Equal(expected) {
+		t.Errorf("Expected: %v, Got: %+v (%v)", expected, item.Due, err)
+	}
+}
+
+func TestParseQuickAddWeekdayIsNeverToday(t *testing.T) {
+	item, _ := models.ParseQuickAdd("review wednesday", quickAddNow)
+	if expected := day(2024, 5, 22); item.Due == nil || !item.Due.Equal(*expected) {
+		t.Errorf("Expected: %v, Got: %v", expected, item.Due)
+	}
+}
+
+func TestParseDue(t *testing.T) {
+	tests := []struct {
+		phrase string
+		due    *time.Time
+	}{
+		{"today", day(2024, 5, 15)},
+		{"Tomorrow", day(2024, 5, 16)},
+		{"fri", day(2024, 5, 17)},
+		{"saturday", day(2024, 5, 18)},
+		{"next sun", day(2024, 5, 19)},
+		{"next week", day(2024, 5, 22)},
+		{" in  3  days ", day(2024, 5, 18)},
+		{"2024-12-31", day(2024, 12, 31)},
+		{"", nil},
+		{"someday", nil},
+		{"tomorrow evening", nil},
+		{"by friday", nil},
+		{"in 3", nil},
+		{"31/12/2024", nil},
+		{"in 7975 years", day(9999, 5, 15)},
+		{"in 7976 years", nil},
+		{"in 9999 years", nil},
+	}
+	for _, test := range tests {
+		due, err := models.ParseDue(test.phrase, quickAddNow)
+		switch {
+		case test.due == nil && err == nil:
+			t.Errorf("%q: Expected an error, Got: %v", test.phrase, due)
+		case test.due != nil && (err != nil || !due.Equal(*test.due)):
+			t.Errorf("%q: Expected: %v, Got: %v (%v)", test.phrase, test.due, due, err)
+		}
+	}
+}
+
+func TestValidateTags(t *testing.T) {
+	tests := []struct {
+		tags  []string
+		valid bool
+	}{
+		{nil, true},
+		{[]string{"home", "v2-api", "team_a", "déjà"}, true},
+		{[]string{""}, false},
+		{[]string{"two words"}, false},
+		{[]string{"#home"}, false},
+		{make([]string, models.MaxTags+1), false},
+	}
+	for _, test := range tests {
+		item := models.ToDo{UserId: "TestToDoUser", Title: "test", Priority: "Low", Tags: test.tags}
+		if err := item.Validate(models.V2); (err == nil) != test.valid {
+			t.Errorf("%q: Expected valid: %t, Got: %v", test.tags, test.valid, err)
+		}
+	}
+	v1 := models.ToDo{Title: "test", Priority: "Low", Tags: []string{"home"}}
+	if err := v1.Validate(models.V1); err == nil {
+		t.Errorf("Expected v1 item with tags to be rejected")
+	}
+}
+
+func TestValidateDue(t *testing.T) {
+	for _, due := range []*time.Time{day(10000, 1, 1), day(0, 12, 31)} {
+		item := models.ToDo{UserId: "TestToDoUser", Title: "test", Priority: "Low", Due: due}
+		if err := item.Validate(models.V2); err == nil {
+			t.Errorf("%v: Expected an error", due)
+		}
+	}
+	item, err := models.ParseQuickAdd("party in 9999 years", quickAddNow)
+	if err != nil || item.Due != nil || item.Title != "party in 9999 years" {
+		t.Errorf("Expected the date to stay in the title, Got: %+v (%v)", item, err)
+	}
+}
+
+// describe prints the due date of an item rather than its address.
+func describe(item models.ToDo) string {
+	due := "<nil>"
+	if item.Due != nil {
+		due = item.Due.Format(time.DateOnly)
+	}
+	return fmt.Sprintf("%s | %s | %q | %s", item.Title, item.Priority, item.Tags, due)
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	todoerrors "go-to-do-app/to-do-lib/errors"
+
+	"github.com/google/uuid"
+)
+
+// Role is what a user may do with an item. Owners may do anything, editors
+// may change an item but not delete or share it, and viewers may only read it.
+type Role string
+
+const (
+	RoleViewer Role = "viewer"
+	RoleEditor Role = "editor"
+	RoleOwner  Role = "owner"
+)
+
+var roleRanks = map[Role]int{RoleViewer: 1, RoleEditor: 2, RoleOwner: 3}
+
+// ParseRole returns the role named r, which may be given to collaborators.
+func ParseRole(r string) (Role, error) {
+	switch role := Role(r); role {
+	case RoleViewer, RoleEditor:
+		return role, nil
+	}
+	return "", &todoerrors.ValidationError{Field: "role", Err: fmt.Errorf("invalid role: %s. Valid options are: %s, %s", r, RoleViewer, RoleEditor)}
+}
+
+// Allows reports whether a user with role r may do what needs role required.
+func (r Role) Allows(required Role) bool {
+	return roleRanks[r] >= roleRanks[required]
+}
+
+// Share gives Collaborator a Role on one of OwnerId's items, or on every item
+// of theirs when ItemId is nil.
+type Share struct {
+	OwnerId      string     `json:"owner_id"`
+	ItemId       *uuid.UUID `json:"item_id,omitempty"`
+	Collaborator string     `json:"collaborator"`
+	Role         Role       `json:"role"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// Item returns the id of the shared item, or uuid.Nil for a shared list.
+func (s Share) Item() uuid.UUID {
+	if s.ItemId == nil {
+		return uuid.Nil
+	}
+	return *s.ItemId
+}
+
+// Covers reports whether the share is of item.
+func (s Share) Covers(item ToDo) bool {
+	return s.OwnerId == item.UserId && (s.ItemId == nil || *s.ItemId == item.Id)
+}
+
+// Validate checks the share names an owner and a collaborator other than
+// them, and a role they may be given.
+func (s Share) Validate() error {
+	if s.OwnerId == "" {
+		return &todoerrors.ValidationError{Field: "owner_id", Err: errors.New("owner_id is required")}
+	}
+	if s.Collaborator == "" || s.Collaborator == s.OwnerId {
+		return &todoerrors.ValidationError{Field: "collaborator", Err: errors.New("collaborator must be another user than the owner")}
+	}
+	_, err := ParseRole(string(s.Role))
+	return err
+}
+
+// Access is the role a user has on an item, and whose item it is.
+type Access struct {
+	OwnerId string `json:"owner_id"`
+	Role    Role   `json:"role"`
+}
+
+// SharedItem is an item shared with a user, and their role on it.
+type SharedItem struct {
+	ToDo
+	Role Role `json:"role"`
+}
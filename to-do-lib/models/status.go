@@ -0,0 +1,244 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	todoerrors "go-to-do-app/to-do-lib/errors"
+)
+
+// Status is the step of the workflow an item is at. Statuses are only set
+// through the v3 api, v1 & v2 clients see whether the status is a done one as
+// Complete.
+type Status string
+
+const (
+	StatusBacklog    Status = "backlog"
+	StatusInProgress Status = "in_progress"
+	StatusBlocked    Status = "blocked"
+	StatusDone       Status = "done"
+)
+
+// Transition is a move of an item from one status to another.
+type Transition struct {
+	From Status `json:"from"`
+	To   Status `json:"to"`
+}
+
+// Workflow is the statuses items move through and the transitions allowed
+// between them.
+type Workflow struct {
+	// Initial is the status of new items that are not given one
+	Initial Status `json:"initial"`
+	// Done are the statuses an item is complete at. An item completed by a
+	// v1 or v2 client moves to the first of them, and one they reopen moves
+	// back to Initial.
+	Done        []Status     `json:"done"`
+	Transitions []Transition `json:"transitions"`
+}
+
+// DefaultWorkflow moves items from the backlog through being in progress,
+// and maybe blocked, to done.
+var DefaultWorkflow = Workflow{
+	Initial: StatusBacklog,
+	Done:    []Status{StatusDone},
+	Transitions: []Transition{
+		{StatusBacklog, StatusInProgress},
+		{StatusBacklog, StatusDone},
+		{StatusInProgress, StatusBacklog},
+		{StatusInProgress, StatusBlocked},
+		{StatusInProgress, StatusDone},
+		{StatusBlocked, StatusInProgress},
+		{StatusBlocked, StatusBacklog},
+		{StatusDone, StatusInProgress},
+		{StatusDone, StatusBacklog},
+	},
+}
+
+var statusPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,29}$`)
+
+var (
+	workflowMut sync.RWMutex
+	workflow    = DefaultWorkflow
+)
+
+// SetWorkflow replaces the workflow of the deployment. Items keep the status
+// they were saved with, so an item left at a status the workflow no longer
+// has may move to any of its statuses.
+func SetWorkflow(w Workflow) error {
+	if err := w.Check(); err != nil {
+		return err
+	}
+	workflowMut.Lock()
+	defer workflowMut.Unlock()
+	workflow = w
+	return nil
+}
+
+// CurrentWorkflow returns the workflow of the deployment.
+func CurrentWorkflow() Workflow {
+	workflowMut.RLock()
+	defer workflowMut.RUnlock()
+	return workflow
+}
+
+// ParseWorkflow reads a workflow whose transitions are written as from>to,
+// such as backlog>in_progress.
+func ParseWorkflow(initial string, done []string, transitions []string) (Workflow, error) {
+	w := Workflow{Initial: Status(strings.TrimSpace(initial))}
+	for _, status := range done {
+		w.Done = append(w.Done, Status(strings.TrimSpace(status)))
+	}
+	for _, spec := range transitions {
+		from, to, found := strings.Cut(spec, ">")
+		if !found {
+			return Workflow{}, fmt.Errorf("invalid transition %q, write it as from>to", spec)
+		}
+		w.Transitions = append(w.Transitions, Transition{Status(strings.TrimSpace(from)), Status(strings.TrimSpace(to))})
+	}
+	return w, w.Check()
+}
+
+// Check reports a workflow without an initial or done status, or with an
+// invalid status name.
+func (w Workflow) Check() error {
+	if w.Initial == "" {
+		return errors.New("an initial status is required")
+	}
+	if len(w.Done) == 0 {
+		return errors.New("at least one done status is required")
+	}
+	for _, status := range w.Statuses() {
+		if !statusPattern.MatchString(string(status)) {
+			return fmt.Errorf("invalid status %q, use up to 30 lowercase letters, digits and _ starting with a letter", status)
+		}
+	}
+	if w.IsDone(w.Initial) {
+		return fmt.Errorf("initial status %s cannot be a done status", w.Initial)
+	}
+	for _, t := range w.Transitions {
+		if t.From == t.To {
+			return fmt.Errorf("transition %s>%s does not change the status", t.From, t.To)
+		}
+	}
+	return nil
+}
+
+// Statuses returns every status of the workflow, the initial one first and
+// the others in the order they are first named.
+func (w Workflow) Statuses() []Status {
+	statuses := []Status{w.Initial}
+	add := func(s Status) {
+		if !slices.Contains(statuses, s) {
+			statuses = append(statuses, s)
+		}
+	}
+	for _, t := range w.Transitions {
+		add(t.From)
+		add(t.To)
+	}
+	for _, s := range w.Done {
+		add(s)
+	}
+	return statuses
+}
+
+// Has reports whether s is one of the statuses of the workflow.
+func (w Workflow) Has(s Status) bool {
+	return slices.Contains(w.Statuses(), s)
+}
+
+// IsDone reports whether an item at s is complete.
+func (w Workflow) IsDone(s Status) bool {
+	return slices.Contains(w.Done, s)
+}
+
+// Allows reports whether an item may move from one status to another.
+func (w Workflow) Allows(from, to Status) bool {
+	return slices.Contains(w.Transitions, Transition{from, to})
+}
+
+// next lists the statuses an item at s may move to.
+func (w Workflow) next(s Status) []string {
+	var statuses []string
+	for _, t := range w.Transitions {
+		if t.From == s {
+			statuses = append(statuses, string(t.To))
+		}
+	}
+	return statuses
+}
+
+// StatusOf returns the status of item, which for an item saved before it had
+// one follows whether it is complete.
+func (w Workflow) StatusOf(item ToDo) Status {
+	switch {
+	case item.Status != "":
+		return item.Status
+	case item.Complete:
+		return w.Done[0]
+	default:
+		return w.Initial
+	}
+}
+
+// Start sets the status of a new item, and its completion from that.
+func (w Workflow) Start(item *ToDo) {
+	item.Status = w.StatusOf(*item)
+	item.Complete = w.IsDone(item.Status)
+}
+
+// Move applies an update of before to item. An item keeping the status of
+// before, or sent without one as by v1 & v2 clients, stays at it unless
+// Complete changes, which moves it to the first done status or back to the
+// initial one whatever the transitions. Otherwise the move to the item's
+// status must be one of the transitions, and Complete follows the status.
+func (w Workflow) Move(before ToDo, item *ToDo) error {
+	from := w.StatusOf(before)
+	if item.Status == "" || item.Status == from {
+		switch {
+		case item.Complete == w.IsDone(from):
+			item.Status = from
+		case item.Complete:
+			item.Status = w.Done[0]
+		default:
+			item.Status = w.Initial
+		}
+		return nil
+	}
+	if !w.Has(item.Status) {
+		return unknownStatusError(w, item.Status)
+	}
+	// an item at a status the workflow no longer has may move to any
+	if w.Has(from) && !w.Allows(from, item.Status) {
+		next := w.next(from)
+		if len(next) == 0 {
+			return &todoerrors.ValidationError{Field: "status", Err: fmt.Errorf("cannot move from %s, it has no transitions", from)}
+		}
+		return &todoerrors.ValidationError{Field: "status", Err: fmt.Errorf("cannot move from %s to %s, it can move to %s", from, item.Status, strings.Join(next, ", "))}
+	}
+	item.Complete = w.IsDone(item.Status)
+	return nil
+}
+
+// Revert applies the return of an item to before, an earlier state of it,
+// which may take it back to any status whatever the transitions.
+func (w Workflow) Revert(before ToDo, item *ToDo) error {
+	if item.Status == "" {
+		return w.Move(before, item)
+	}
+	item.Complete = w.IsDone(item.Status)
+	return nil
+}
+
+func unknownStatusError(w Workflow, s Status) error {
+	var statuses []string
+	for _, status := range w.Statuses() {
+		statuses = append(statuses, string(status))
+	}
+	return &todoerrors.ValidationError{Field: "status", Err: fmt.Errorf("invalid status: %s. Valid options are: %s", s, strings.Join(statuses, ", "))}
+}
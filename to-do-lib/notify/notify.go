@@ -0,0 +1,225 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go-to-do-app/to-do-lib/datastores"
+	"go-to-do-app/to-do-lib/events"
+	"go-to-do-app/to-do-lib/logging"
+	"go-to-do-app/to-do-lib/models"
+)
+
+// Message is an email to one recipient.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers messages, e.g. SMTPSender.
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Addresses finds the email address of users. An address in Users wins, and
+// other users are given one at Domain when it is set. User ids are chosen by
+// clients, so they are never used as addresses themselves; only addresses the
+// server is configured with are emailed.
+type Addresses struct {
+	Users  map[string]string
+	Domain string
+}
+
+// Lookup returns the address of userId, if there is one.
+func (a Addresses) Lookup(userId string) (string, bool) {
+	if address, exists := a.Users[userId]; exists {
+		return address, true
+	}
+	if a.Domain != "" && userId != "" && !strings.ContainsAny(userId, "@<>,; \t\r\n") {
+		return userId + "@" + a.Domain, true
+	}
+	return "", false
+}
+
+type Options struct {
+	Sender    Sender
+	Addresses Addresses
+	// DueWithin is how long before an item is due its reminder is sent.
+	DueWithin time.Duration
+	// Interval is how often due items are checked.
+	Interval  time.Duration
+	QueueSize int
+	Timeout   time.Duration
+}
+
+func (o Options) withDefaults() Options {
+	if o.DueWithin <= 0 {
+		o.DueWithin = 24 * time.Hour
+	}
+	if o.Interval <= 0 {
+		o.Interval = time.Minute
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = 100
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 30 * time.Second
+	}
+	return o
+}
+
+// Notifier is an events.Publisher that emails users when an item is assigned
+// to them, and reminds them of the items they are assigned, or own when no
+// one is, as they fall due. Each item is reminded of once per due time, the
+// reminder being claimed in the store so restarts and other servers on the
+// store do not send it again.
+type Notifier struct {
+	store datastores.AssignmentStore
+	opts  Options
+	queue chan Message
+	stop  chan struct{}
+	wg    sync.WaitGroup
+}
+
+func NewNotifier(store datastores.AssignmentStore, opts Options) *Notifier {
+	opts = opts.withDefaults()
+	return &Notifier{
+		store: store,
+		opts:  opts,
+		queue: make(chan Message, opts.QueueSize),
+		stop:  make(chan struct{}),
+	}
+}
+
+// Assigned reports whether e gives its item to a new assignee.
+func Assigned(e events.Event) bool {
+	if e.Item.Assignee == "" || e.Item.Assignee == e.Item.UserId {
+		return false
+	}
+	switch e.Type {
+	case events.Created:
+		return true
+	case events.Updated:
+		return e.Previous != nil && e.Previous.Assignee != e.Item.Assignee
+	}
+	return false
+}
+
+func (n *Notifier) Publish(e events.Event) {
+	if !Assigned(e) {
+		return
+	}
+	item := e.Item
+	body := fmt.Sprintf("You have been assigned %q, of the list of %s.\n", item.Title, item.UserId)
+	if item.Due != nil {
+		body += fmt.Sprintf("It is due %s.\n", item.Due.Format(time.RFC1123))
+	}
+	n.notify(item.Assignee, "Assigned: "+item.Title, body+itemFooter(item))
+}
+
+// RemindDue queues a reminder for each item due within DueWithin of now that
+// has not been reminded of, returning how many were queued.
+func (n *Notifier) RemindDue(now time.Time) int {
+	ctx := logging.AddTraceID(context.Background())
+	items, err := n.store.ListDue(now.Add(n.opts.DueWithin))
+	if err != nil {
+		logging.LogWithTrace(ctx, map[string]interface{}{"error": err.Error()}, "unable to list due items")
+		return 0
+	}
+	due := make([]models.ToDo, 0)
+	for _, item := range items {
+		claimed, err := n.store.ClaimReminder(item.Id, *item.Due)
+		if err != nil {
+			logging.LogWithTrace(ctx, map[string]interface{}{"error": err.Error(), "itemId": item.Id}, "unable to claim reminder")
+			continue
+		}
+		if claimed {
+			due = append(due, item)
+		}
+	}
+
+	for _, item := range due {
+		recipient := item.Assignee
+		if recipient == "" {
+			recipient = item.UserId
+		}
+		subject := "Due soon: " + item.Title
+		if item.Due.Before(now) {
+			subject = "Overdue: " + item.Title
+		}
+		body := fmt.Sprintf("%q is due %s.\n", item.Title, item.Due.Format(time.RFC1123)) + itemFooter(item)
+		n.notify(recipient, subject, body)
+	}
+	return len(due)
+}
+
+func itemFooter(item models.ToDo) string {
+	return fmt.Sprintf("\nPriority: %s\nId: %s\n", item.Priority, item.Id)
+}
+
+func (n *Notifier) notify(userId string, subject string, body string) {
+	ctx := logging.AddTraceID(context.Background())
+	to, ok := n.opts.Addresses.Lookup(userId)
+	if !ok {
+		logging.LogWithTrace(ctx, map[string]interface{}{"user_id": userId}, "no email address to notify user")
+		return
+	}
+	select {
+	case n.queue <- Message{To: to, Subject: subject, Body: body}:
+	default:
+		logging.LogWithTrace(ctx, map[string]interface{}{"to": to, "subject": subject}, "notification queue is full")
+	}
+}
+
+// Start sends queued messages and checks for due items every Interval until
+// Stop is called.
+func (n *Notifier) Start() {
+	n.wg.Add(2)
+	go n.work()
+	go n.remind()
+}
+
+// Stop waits for the message being sent to finish. Queued messages are
+// dropped.
+func (n *Notifier) Stop() {
+	close(n.stop)
+	n.wg.Wait()
+}
+
+func (n *Notifier) work() {
+	defer n.wg.Done()
+	for {
+		select {
+		case <-n.stop:
+			return
+		case msg := <-n.queue:
+			n.send(msg)
+		}
+	}
+}
+
+func (n *Notifier) send(msg Message) {
+	ctx, cancel := context.WithTimeout(logging.AddTraceID(context.Background()), n.opts.Timeout)
+	defer cancel()
+	if err := n.opts.Sender.Send(ctx, msg); err != nil {
+		logging.LogWithTrace(ctx, map[string]interface{}{"to": msg.To, "subject": msg.Subject, "error": err.Error()}, "unable to send notification")
+	}
+}
+
+func (n *Notifier) remind() {
+	defer n.wg.Done()
+	ticker := time.NewTicker(n.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-n.stop:
+			return
+		case now := <-ticker.C:
+			n.RemindDue(now)
+		}
+	}
+}
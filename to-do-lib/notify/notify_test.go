@@ -0,0 +1,226 @@
+package notify_test
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go-to-do-app/to-do-lib/datastores"
+	"go-to-do-app/to-do-lib/events"
+	"go-to-do-app/to-do-lib/models"
+	"go-to-do-app/to-do-lib/notify"
+)
+
+// received is a message taken in by smtpStandIn.
+type received struct {
+	From string
+	To   string
+	Data string
+}
+
+// smtpStandIn is a local SMTP server accepting every message sent to it.
+func smtpStandIn(t *testing.T) (string, <-chan received) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	messages := make(chan received, 10)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(conn, messages)
+		}
+	}()
+	return l.Addr().String(), messages
+}
+
+func serveSMTP(conn net.Conn, messages chan<- received) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+	reply("220 localhost stand-in")
+	var msg received
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		switch verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); verb {
+		case "EHLO", "HELO":
+			reply("250 localhost")
+		case "MAIL":
+			msg.From = strings.TrimPrefix(line, "MAIL FROM:")
+			reply("250 OK")
+		case "RCPT":
+			msg.To = strings.TrimPrefix(line, "RCPT TO:")
+			reply("250 OK")
+		case "DATA":
+			reply("354 end data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			msg.Data = data.String()
+			messages <- msg
+			reply("250 OK")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func TestSMTPSender(t *testing.T) {
+	addr, messages := smtpStandIn(t)
+	sender := notify.SMTPSender{Addr: addr, From: "todo@example.com"}
+	msg := notify.Message{To: "user@example.com", Subject: "Assigned: test", Body: "line one\nline two"}
+	if err := sender.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Expected message to be sent, Got: %v", err)
+	}
+	got := <-messages
+	if got.From != "<todo@example.com>" || got.To != "<user@example.com>" {
+		t.Errorf("Expected: %s to %s, Got: %s to %s", "<todo@example.com>", "<user@example.com>", got.From, got.To)
+	}
+	for _, expected := range []string{"Subject: Assigned: test\r\n", "To: user@example.com\r\n", "\r\n\r\nline one\r\nline two\r\n"} {
+		if !strings.Contains(got.Data, expected) {
+			t.Errorf("Expected: %q in %q", expected, got.Data)
+		}
+	}
+}
+
+func TestAddressesLookup(t *testing.T) {
+	addresses := notify.Addresses{Users: map[string]string{"alice": "a@example.org"}, Domain: "example.com"}
+	for userId, expected := range map[string]string{"alice": "a@example.org", "bob": "bob@example.com"} {
+		if actual, ok := addresses.Lookup(userId); !ok || actual != expected {
+			t.Errorf("Expected: %s, Got: %s", expected, actual)
+		}
+	}
+	for _, userId := range []string{"c@example.net", "d@example.net>,<e", "f\r\nBcc: g"} {
+		if actual, ok := addresses.Lookup(userId); ok {
+			t.Errorf("Expected no address for %q, Got: %s", userId, actual)
+		}
+	}
+	if _, ok := (notify.Addresses{}).Lookup("bob"); ok {
+		t.Errorf("Expected no address without a domain")
+	}
+}
+
+// fakeSender keeps the messages sent through it.
+type fakeSender struct {
+	mut  sync.Mutex
+	sent []notify.Message
+}
+
+func (f *fakeSender) Send(ctx context.Context, msg notify.Message) error {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	f.sent = append(f.sent, msg)
+	return nil
+}
+
+func (f *fakeSender) await(t *testing.T, n int) []notify.Message {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		f.mut.Lock()
+		if len(f.sent) >= n {
+			sent := append([]notify.Message(nil), f.sent...)
+			f.mut.Unlock()
+			return sent
+		}
+		f.mut.Unlock()
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("fewer than %d messages were sent", n)
+	return nil
+}
+
+func TestNotifierAssignments(t *testing.T) {
+	sender := &fakeSender{}
+	n := notify.NewNotifier(datastores.NewInMemDataStore(), notify.Options{Sender: sender, Addresses: notify.Addresses{Domain: "example.com"}, Interval: time.Hour})
+	n.Start()
+	defer n.Stop()
+
+	item := models.ToDo{UserId: "owner", Title: "test", Priority: "High", Assignee: "alice"}
+	renamed := item
+	renamed.Title = "renamed"
+	reassigned := renamed
+	reassigned.Assignee = "bob"
+	n.Publish(events.Event{Type: events.Created, Item: item})
+	n.Publish(events.Event{Type: events.Updated, Item: renamed, Previous: &item})
+	n.Publish(events.Event{Type: events.Updated, Item: reassigned, Previous: &renamed})
+
+	sent := sender.await(t, 2)
+	if len(sent) != 2 || sent[0].To != "alice@example.com" || sent[1].To != "bob@example.com" {
+		t.Errorf("Expected messages to alice then bob, Got: %+v", sent)
+	}
+	if sent[1].Subject != "Assigned: renamed" {
+		t.Errorf("Expected: %s, Got: %s", "Assigned: renamed", sent[1].Subject)
+	}
+}
+
+func TestNotifierRemindDue(t *testing.T) {
+	store := datastores.NewInMemDataStore()
+	now := time.Now()
+	soon, later := now.Add(time.Hour), now.Add(72*time.Hour)
+	assigned := store.AddItem(models.ToDo{UserId: "owner", Title: "assigned", Priority: "Low", Assignee: "alice", Due: &soon})
+	store.AddItem(models.ToDo{UserId: "owner", Title: "unassigned", Priority: "Low", Due: &soon})
+	store.AddItem(models.ToDo{UserId: "owner", Title: "later", Priority: "Low", Due: &later})
+
+	sender := &fakeSender{}
+	n := notify.NewNotifier(store, notify.Options{Sender: sender, Addresses: notify.Addresses{Domain: "example.com"}, Interval: time.Hour})
+	n.Start()
+	defer n.Stop()
+
+	if count := n.RemindDue(now); count != 2 {
+		t.Errorf("Expected: %d, Got: %d", 2, count)
+	}
+	sent := sender.await(t, 2)
+	recipients := map[string]string{}
+	for _, msg := range sent {
+		recipients[msg.To] = msg.Subject
+	}
+	if recipients["alice@example.com"] != "Due soon: assigned" || recipients["owner@example.com"] != "Due soon: unassigned" {
+		t.Errorf("Expected reminders to the assignee and the owner, Got: %+v", sent)
+	}
+	if count := n.RemindDue(now); count != 0 {
+		t.Errorf("Expected items to be reminded of once, Got: %d", count)
+	}
+
+	// moving the due time reminds of the item again
+	overdue := now.Add(-time.Minute)
+	assigned.Due = &overdue
+	store.UpdateItem(assigned)
+	if count := n.RemindDue(now); count != 1 {
+		t.Errorf("Expected: %d, Got: %d", 1, count)
+	}
+	if sent := sender.await(t, 3); sent[2].Subject != "Overdue: assigned" {
+		t.Errorf("Expected: %s, Got: %s", "Overdue: assigned", sent[2].Subject)
+	}
+
+	// reminders are claimed in the store, so a restarted or second server
+	// does not send them again
+	restarted := notify.NewNotifier(store, notify.Options{Sender: sender, Addresses: notify.Addresses{Domain: "example.com"}, Interval: time.Hour})
+	if count := restarted.RemindDue(now); count != 0 {
+		t.Errorf("Expected items to be reminded of once, Got: %d", count)
+	}
+}
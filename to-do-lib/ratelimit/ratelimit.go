@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often buckets that have refilled are forgotten, so
+// the limiter does not grow with every client it has ever seen.
+const sweepInterval = time.Minute
+
+// Limit allows Rate requests per second on average, with bursts of up to
+// Burst requests. A Limit with a Rate of zero allows everything.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+func (l Limit) Enabled() bool {
+	return l.Rate > 0
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter is a set of token buckets, one per key.
+type Limiter struct {
+	mut       sync.Mutex
+	limit     Limit
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewLimiter(limit Limit) *Limiter {
+	l := &Limiter{buckets: make(map[string]*bucket)}
+	l.SetLimit(limit)
+	return l
+}
+
+// SetLimit changes the limit for every key. Existing buckets keep their
+// tokens, capped at the new burst.
+func (l *Limiter) SetLimit(limit Limit) {
+	if limit.Burst < 1 {
+		limit.Burst = int(math.Max(1, math.Ceil(limit.Rate)))
+	}
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	l.limit = limit
+}
+
+func (l *Limiter) Limit() Limit {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	return l.limit
+}
+
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	return l.AllowAt(key, time.Now())
+}
+
+// AllowAt takes a token from key's bucket at the given time. When the bucket
+// is empty it reports how long until the next token is available.
+func (l *Limiter) AllowAt(key string, now time.Time) (bool, time.Duration) {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	if !l.limit.Enabled() {
+		return true, 0
+	}
+	burst := float64(l.limit.Burst)
+	if now.Sub(l.lastSweep) > sweepInterval {
+		l.sweep(now, burst)
+	}
+	b, exists := l.buckets[key]
+	if !exists {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[key] = b
+	}
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(burst, b.tokens+elapsed*l.limit.Rate)
+		b.last = now
+	}
+	b.tokens = math.Min(burst, b.tokens)
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / l.limit.Rate * float64(time.Second))
+	return false, wait
+}
+
+// sweep expects the caller to hold l.mut
+func (l *Limiter) sweep(now time.Time, burst float64) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.limit.Rate >= burst {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
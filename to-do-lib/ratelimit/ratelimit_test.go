@@ -0,0 +1,50 @@
+package ratelimit_test
+
+import (
+	"testing"
+	"time"
+
+	"go-to-do-app/to-do-lib/ratelimit"
+)
+
+func TestLimiterAllowsBurstThenThrottles(t *testing.T) {
+	limiter := ratelimit.NewLimiter(ratelimit.Limit{Rate: 2, Burst: 3})
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		if ok, _ := limiter.AllowAt("client", now); !ok {
+			t.Fatalf("Expected request %d of the burst to be allowed", i+1)
+		}
+	}
+	ok, wait := limiter.AllowAt("client", now)
+	if ok || wait != 500*time.Millisecond {
+		t.Errorf("Expected: throttled for %v, Got: allowed %v, wait %v", 500*time.Millisecond, ok, wait)
+	}
+	if ok, _ := limiter.AllowAt("other", now); !ok {
+		t.Errorf("Expected other keys to have their own bucket")
+	}
+	if ok, _ := limiter.AllowAt("client", now.Add(500*time.Millisecond)); !ok {
+		t.Errorf("Expected a token to be refilled after 500ms")
+	}
+}
+
+func TestLimiterZeroRateAllowsEverything(t *testing.T) {
+	limiter := ratelimit.NewLimiter(ratelimit.Limit{})
+	for i := 0; i < 100; i++ {
+		if ok, _ := limiter.Allow("client"); !ok {
+			t.Fatalf("Expected disabled limiter to allow request %d", i+1)
+		}
+	}
+}
+
+func TestLimiterSetLimit(t *testing.T) {
+	limiter := ratelimit.NewLimiter(ratelimit.Limit{Rate: 1, Burst: 10})
+	now := time.Now()
+	limiter.AllowAt("client", now)
+	limiter.SetLimit(ratelimit.Limit{Rate: 1, Burst: 1})
+	if ok, _ := limiter.AllowAt("client", now); !ok {
+		t.Fatalf("Expected bucket capped at the new burst to allow one request")
+	}
+	if ok, _ := limiter.AllowAt("client", now); ok {
+		t.Errorf("Expected the lowered burst to throttle the second request")
+	}
+}
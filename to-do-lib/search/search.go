@@ -0,0 +1,165 @@
+package search
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// prefixWeight scales the score of a term that only matches a query token by
+// prefix, so exact matches rank first.
+const prefixWeight = 0.5
+
+// Tokenize lower-cases text and splits it into runs of letters & digits.
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+type document struct {
+	userId string
+	terms  map[string]int
+}
+
+type Result struct {
+	Id    uuid.UUID
+	Score float64
+}
+
+// Index is an in-memory inverted index of documents owned by users. Every
+// query token must match a term of a document, either exactly or as a
+// prefix, and results are ranked by tf-idf.
+type Index struct {
+	mut      sync.Mutex
+	docs     map[uuid.UUID]document
+	postings map[string]map[uuid.UUID]struct{}
+	// terms is the sorted list of keys of postings, used for prefix lookups
+	terms []string
+	dirty bool
+}
+
+func NewIndex() *Index {
+	return &Index{docs: make(map[uuid.UUID]document), postings: make(map[string]map[uuid.UUID]struct{})}
+}
+
+// Add indexes text as the document id, replacing any previous version.
+func (ix *Index) Add(userId string, id uuid.UUID, text string) {
+	ix.mut.Lock()
+	defer ix.mut.Unlock()
+	ix.remove(id)
+	doc := document{userId: userId, terms: make(map[string]int)}
+	for _, token := range Tokenize(text) {
+		doc.terms[token]++
+	}
+	for term := range doc.terms {
+		if _, exists := ix.postings[term]; !exists {
+			ix.postings[term] = make(map[uuid.UUID]struct{})
+			ix.dirty = true
+		}
+		ix.postings[term][id] = struct{}{}
+	}
+	ix.docs[id] = doc
+}
+
+func (ix *Index) Remove(id uuid.UUID) {
+	ix.mut.Lock()
+	defer ix.mut.Unlock()
+	ix.remove(id)
+}
+
+// remove expects the caller to hold ix.mut
+func (ix *Index) remove(id uuid.UUID) {
+	doc, exists := ix.docs[id]
+	if !exists {
+		return
+	}
+	for term := range doc.terms {
+		delete(ix.postings[term], id)
+		if len(ix.postings[term]) == 0 {
+			delete(ix.postings, term)
+			ix.dirty = true
+		}
+	}
+	delete(ix.docs, id)
+}
+
+// matching returns the indexed terms starting with token. The caller must
+// hold ix.mut.
+func (ix *Index) matching(token string) []string {
+	start := sort.SearchStrings(ix.terms, token)
+	end := start
+	for end < len(ix.terms) && strings.HasPrefix(ix.terms[end], token) {
+		end++
+	}
+	return ix.terms[start:end]
+}
+
+// Search returns the documents of userId matching query, best match first.
+func (ix *Index) Search(userId string, query string) []Result {
+	tokens := Tokenize(query)
+	if len(tokens) == 0 {
+		return []Result{}
+	}
+	ix.mut.Lock()
+	defer ix.mut.Unlock()
+	if ix.dirty {
+		ix.terms = ix.terms[:0]
+		for term := range ix.postings {
+			ix.terms = append(ix.terms, term)
+		}
+		slices.Sort(ix.terms)
+		ix.dirty = false
+	}
+
+	total := float64(len(ix.docs))
+	var scores map[uuid.UUID]float64
+	for _, token := range tokens {
+		tokenScores := make(map[uuid.UUID]float64)
+		for _, term := range ix.matching(token) {
+			weight := 1.0
+			if term != token {
+				weight = prefixWeight
+			}
+			idf := math.Log(1 + total/float64(len(ix.postings[term])))
+			for id := range ix.postings[term] {
+				doc := ix.docs[id]
+				if doc.userId != userId {
+					continue
+				}
+				tokenScores[id] += weight * float64(doc.terms[term]) * idf
+			}
+		}
+		if scores == nil {
+			scores = tokenScores
+			continue
+		}
+		for id := range scores {
+			if s, matched := tokenScores[id]; matched {
+				scores[id] += s
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+
+	results := make([]Result, 0, len(scores))
+	for id, score := range scores {
+		results = append(results, Result{Id: id, Score: score})
+	}
+	slices.SortFunc(results, func(a, b Result) int {
+		if a.Score != b.Score {
+			if a.Score > b.Score {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Id.String(), b.Id.String())
+	})
+	return results
+}
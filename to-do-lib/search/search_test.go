@@ -0,0 +1,79 @@
+package search_test
+
+import (
+	"slices"
+	"testing"
+
+	"go-to-do-app/to-do-lib/search"
+
+	"github.com/google/uuid"
+)
+
+func TestTokenize(t *testing.T) {
+	expected := []string{"buy", "milk", "2", "litres", "café"}
+	actual := search.Tokenize("Buy MILK (2 litres) @café!")
+	if !slices.Equal(expected, actual) {
+		t.Errorf("Expected: %+v, Got: %+v", expected, actual)
+	}
+}
+
+func ids(results []search.Result) []uuid.UUID {
+	list := make([]uuid.UUID, len(results))
+	for i, res := range results {
+		list[i] = res.Id
+	}
+	return list
+}
+
+func TestSearchMatchesEveryToken(t *testing.T) {
+	ix := search.NewIndex()
+	milk, bread := uuid.New(), uuid.New()
+	ix.Add("TestToDoUser", milk, "Buy milk")
+	ix.Add("TestToDoUser", bread, "Buy bread")
+	ix.Add("SomeoneElse", uuid.New(), "Buy milk")
+
+	tests := []struct {
+		query    string
+		expected []uuid.UUID
+	}{
+		{"milk", []uuid.UUID{milk}},
+		{"BUY MILK", []uuid.UUID{milk}},
+		{"mil", []uuid.UUID{milk}},
+		{"bu br", []uuid.UUID{bread}},
+		{"eggs", []uuid.UUID{}},
+		{"  ", []uuid.UUID{}},
+	}
+	for _, test := range tests {
+		if actual := ids(ix.Search("TestToDoUser", test.query)); !slices.Equal(test.expected, actual) {
+			t.Errorf("%q Expected: %+v, Got: %+v", test.query, test.expected, actual)
+		}
+	}
+}
+
+func TestSearchRanksExactMatchesFirst(t *testing.T) {
+	ix := search.NewIndex()
+	prefix, exact := uuid.New(), uuid.New()
+	ix.Add("TestToDoUser", prefix, "Paint the garage")
+	ix.Add("TestToDoUser", exact, "Paint the gar")
+	expected := []uuid.UUID{exact, prefix}
+	if actual := ids(ix.Search("TestToDoUser", "gar")); !slices.Equal(expected, actual) {
+		t.Errorf("Expected: %+v, Got: %+v", expected, actual)
+	}
+}
+
+func TestSearchReflectsUpdatesAndRemovals(t *testing.T) {
+	ix := search.NewIndex()
+	id := uuid.New()
+	ix.Add("TestToDoUser", id, "Buy milk")
+	ix.Add("TestToDoUser", id, "Buy bread")
+	if actual := ix.Search("TestToDoUser", "milk"); len(actual) != 0 {
+		t.Errorf("Expected old text to be unindexed, Got: %+v", actual)
+	}
+	if actual := ids(ix.Search("TestToDoUser", "bread")); !slices.Equal([]uuid.UUID{id}, actual) {
+		t.Errorf("Expected: %+v, Got: %+v", id, actual)
+	}
+	ix.Remove(id)
+	if actual := ix.Search("TestToDoUser", "bread"); len(actual) != 0 {
+		t.Errorf("Expected removed document to be unindexed, Got: %+v", actual)
+	}
+}
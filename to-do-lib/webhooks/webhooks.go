@@ -0,0 +1,400 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"go-to-do-app/to-do-lib/datastores"
+	todoerrors "go-to-do-app/to-do-lib/errors"
+	"go-to-do-app/to-do-lib/events"
+	"go-to-do-app/to-do-lib/logging"
+
+	"github.com/google/uuid"
+)
+
+// EventCompleted can be used in a subscription's EventTypes to match updates
+// that mark an item as complete.
+const EventCompleted = "completed"
+
+const (
+	SignatureHeader = "X-ToDo-Signature"
+	EventHeader     = "X-ToDo-Event"
+	DeliveryHeader  = "X-ToDo-Delivery"
+)
+
+const (
+	StatusPending   = datastores.DeliveryPending
+	StatusDelivered = datastores.DeliveryDelivered
+	StatusDead      = datastores.DeliveryDead
+)
+
+// Delivery tracks the attempts to send one event to one subscription.
+type Delivery = datastores.WebhookDelivery
+
+type payload struct {
+	DeliveryId uuid.UUID    `json:"delivery_id"`
+	Event      events.Event `json:"event"`
+}
+
+type Options struct {
+	Workers        int
+	QueueSize      int
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Timeout        time.Duration
+	// HistorySize bounds how many finished deliveries are kept for listing.
+	HistorySize int
+	// AllowedNetworks may be sent deliveries though they are not public,
+	// such as a receiver on the same host. Loopback, private, link-local &
+	// other internal addresses are refused otherwise, wherever the webhook's
+	// host resolves to.
+	AllowedNetworks []netip.Prefix
+}
+
+func (o Options) withDefaults() Options {
+	if o.Workers <= 0 {
+		o.Workers = 4
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = 1000
+	}
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = 5
+	}
+	if o.InitialBackoff <= 0 {
+		o.InitialBackoff = time.Second
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = 5 * time.Minute
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 10 * time.Second
+	}
+	if o.HistorySize <= 0 {
+		o.HistorySize = 1000
+	}
+	return o
+}
+
+// internalNetworks are refused deliveries along with the loopback, private,
+// link-local, multicast & unspecified addresses: "this" network and the
+// shared address space of carrier NAT, where some clouds serve metadata.
+var internalNetworks = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+}
+
+// Allows reports whether deliveries may be sent to addr.
+func (o Options) Allows(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, network := range o.AllowedNetworks {
+		if network.Contains(addr) {
+			return true
+		}
+	}
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() {
+		return false
+	}
+	return !slices.ContainsFunc(internalNetworks, func(network netip.Prefix) bool { return network.Contains(addr) })
+}
+
+// checkAddress refuses connections to addresses deliveries may not be sent
+// to. It runs once the host is resolved, for every connection including
+// those of redirects, so a webhook cannot be pointed inside the network by
+// its DNS.
+func (o Options) checkAddress(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !o.Allows(addr) {
+		return fmt.Errorf("webhooks may not be sent to %s", addr)
+	}
+	return nil
+}
+
+// Dispatcher is an events.Publisher that delivers each event to the webhooks
+// subscribed to it. Failed deliveries are retried with exponential backoff
+// and moved to the dead letter list once MaxAttempts is reached. Deliveries
+// are kept in the store, so any server on it can list and retry them.
+type Dispatcher struct {
+	store  datastores.WebhookStore
+	opts   Options
+	client *http.Client
+	queue  chan uuid.UUID
+	stop   chan struct{}
+	wg     sync.WaitGroup
+
+	mut sync.Mutex
+	// waiting holds the deliveries queued or waiting to be retried
+	waiting map[uuid.UUID]bool
+}
+
+func NewDispatcher(store datastores.WebhookStore, opts Options) *Dispatcher {
+	opts = opts.withDefaults()
+	dialer := &net.Dialer{Timeout: opts.Timeout, Control: opts.checkAddress}
+	// no proxy, as the dialer would only check the proxy's address
+	transport := &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: opts.Timeout}
+	return &Dispatcher{
+		store:   store,
+		opts:    opts,
+		client:  &http.Client{Timeout: opts.Timeout, Transport: transport},
+		queue:   make(chan uuid.UUID, opts.QueueSize),
+		stop:    make(chan struct{}),
+		waiting: make(map[uuid.UUID]bool),
+	}
+}
+
+// Matches reports whether sub has asked for e.
+func Matches(sub datastores.WebhookSubscription, e events.Event) bool {
+	if len(sub.Priorities) > 0 && !slices.ContainsFunc(sub.Priorities, func(p string) bool {
+		return strings.EqualFold(p, string(e.Item.Priority))
+	}) {
+		return false
+	}
+	if len(sub.EventTypes) == 0 {
+		return true
+	}
+	return slices.Contains(sub.EventTypes, e.Type) ||
+		(e.Completed() && slices.Contains(sub.EventTypes, EventCompleted))
+}
+
+// Sign returns the value of SignatureHeader for body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (d *Dispatcher) Publish(e events.Event) {
+	ctx := logging.AddTraceID(context.Background())
+	subs, err := d.store.ListWebhooks(e.Item.UserId)
+	if err != nil {
+		logging.LogWithTrace(ctx, map[string]interface{}{"error": err.Error()}, "unable to list webhooks")
+		return
+	}
+	for _, sub := range subs {
+		if !Matches(sub, e) {
+			continue
+		}
+		now := time.Now()
+		delivery := Delivery{
+			Id:             uuid.New(),
+			SubscriptionId: sub.Id,
+			UserId:         sub.UserId,
+			URL:            sub.URL,
+			Event:          e,
+			Status:         StatusPending,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		}
+		if err := d.store.SaveDelivery(delivery); err != nil {
+			logging.LogWithTrace(ctx, map[string]interface{}{"error": err.Error()}, "unable to save webhook delivery")
+			continue
+		}
+		d.enqueue(delivery.Id)
+	}
+}
+
+func (d *Dispatcher) enqueue(id uuid.UUID) {
+	d.mut.Lock()
+	d.waiting[id] = true
+	d.mut.Unlock()
+	select {
+	case d.queue <- id:
+	default:
+		d.mut.Lock()
+		delete(d.waiting, id)
+		d.mut.Unlock()
+		if delivery, err := d.store.GetDelivery(id); err == nil {
+			d.finish(delivery, StatusDead, 0, "delivery queue is full")
+		}
+	}
+}
+
+// Start runs the worker pool until Stop is called.
+func (d *Dispatcher) Start() {
+	for i := 0; i < d.opts.Workers; i++ {
+		d.wg.Add(1)
+		go d.work()
+	}
+}
+
+// Stop waits for in-flight attempts to finish. Deliveries still waiting for
+// an attempt are abandoned as dead letters, so they can be retried.
+func (d *Dispatcher) Stop() {
+	close(d.stop)
+	d.wg.Wait()
+	d.mut.Lock()
+	waiting := d.waiting
+	d.waiting = make(map[uuid.UUID]bool)
+	d.mut.Unlock()
+	for id := range waiting {
+		if delivery, err := d.store.GetDelivery(id); err == nil && delivery.Status == StatusPending {
+			d.finish(delivery, StatusDead, delivery.ResponseCode, "the server stopped before it was delivered")
+		}
+	}
+}
+
+func (d *Dispatcher) work() {
+	defer d.wg.Done()
+	for {
+		select {
+		case <-d.stop:
+			return
+		case id := <-d.queue:
+			d.attempt(id)
+		}
+	}
+}
+
+func (d *Dispatcher) attempt(id uuid.UUID) {
+	d.mut.Lock()
+	delete(d.waiting, id)
+	d.mut.Unlock()
+	delivery, err := d.store.GetDelivery(id)
+	if err != nil || delivery.Status != StatusPending {
+		return
+	}
+	secret, subscribed := d.secret(delivery)
+	if !subscribed {
+		d.finish(delivery, StatusDead, 0, "the webhook has been deleted")
+		return
+	}
+	delivery.Attempts++
+	code, err := d.send(id, delivery.URL, secret, delivery.Event)
+	if err == nil {
+		d.finish(delivery, StatusDelivered, code, "")
+		return
+	}
+	if delivery.Attempts >= d.opts.MaxAttempts {
+		d.finish(delivery, StatusDead, code, err.Error())
+		return
+	}
+	delivery.ResponseCode = code
+	delivery.LastError = err.Error()
+	delivery.UpdatedAt = time.Now()
+	d.save(delivery)
+	d.mut.Lock()
+	d.waiting[id] = true
+	d.mut.Unlock()
+	time.AfterFunc(d.backoff(delivery.Attempts), func() {
+		select {
+		case <-d.stop:
+		default:
+			d.enqueue(id)
+		}
+	})
+}
+
+// secret returns the secret deliveries to the subscription of delivery are
+// signed with, read when sending so secrets are never stored twice.
+func (d *Dispatcher) secret(delivery Delivery) (string, bool) {
+	subs, err := d.store.ListWebhooks(delivery.UserId)
+	if err != nil {
+		return "", false
+	}
+	for _, sub := range subs {
+		if sub.Id == delivery.SubscriptionId {
+			return sub.Secret, true
+		}
+	}
+	return "", false
+}
+
+func (d *Dispatcher) save(delivery Delivery) {
+	if err := d.store.SaveDelivery(delivery); err != nil {
+		ctx := logging.AddTraceID(context.Background())
+		logging.LogWithTrace(ctx, map[string]interface{}{"error": err.Error(), "delivery": delivery.Id}, "unable to save webhook delivery")
+	}
+}
+
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	wait := d.opts.InitialBackoff << (attempt - 1)
+	if wait <= 0 || wait > d.opts.MaxBackoff {
+		return d.opts.MaxBackoff
+	}
+	return wait
+}
+
+func (d *Dispatcher) send(id uuid.UUID, url string, secret string, e events.Event) (int, error) {
+	body, err := json.Marshal(payload{DeliveryId: id, Event: e})
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, e.Type)
+	req.Header.Set(DeliveryHeader, id.String())
+	if secret != "" {
+		req.Header.Set(SignatureHeader, Sign(secret, body))
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook responded with %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func (d *Dispatcher) finish(delivery Delivery, status string, code int, lastError string) {
+	delivery.Status = status
+	delivery.ResponseCode = code
+	delivery.LastError = lastError
+	delivery.UpdatedAt = time.Now()
+	d.save(delivery)
+	if err := d.store.PruneDeliveries(d.opts.HistorySize); err != nil {
+		ctx := logging.AddTraceID(context.Background())
+		logging.LogWithTrace(ctx, map[string]interface{}{"error": err.Error()}, "unable to prune webhook deliveries")
+	}
+}
+
+// Deliveries lists the known deliveries for userId, or every user when userId
+// is empty, optionally limited to one status. Newest deliveries come first.
+func (d *Dispatcher) Deliveries(userId string, status string) ([]Delivery, error) {
+	return d.store.ListDeliveries(userId, status)
+}
+
+// Redeliver queues a dead delivery for another round of attempts.
+func (d *Dispatcher) Redeliver(id uuid.UUID) (Delivery, error) {
+	delivery, err := d.store.GetDelivery(id)
+	if err != nil {
+		return Delivery{}, err
+	}
+	if delivery.Status != StatusDead {
+		return Delivery{}, &todoerrors.NotFoundError{Message: "no dead delivery with that id"}
+	}
+	delivery.Status = StatusPending
+	delivery.Attempts = 0
+	delivery.UpdatedAt = time.Now()
+	if err := d.store.SaveDelivery(delivery); err != nil {
+		return Delivery{}, err
+	}
+	d.enqueue(id)
+	return delivery, nil
+}
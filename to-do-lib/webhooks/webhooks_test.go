@@ -0,0 +1,173 @@
+package webhooks_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-to-do-app/to-do-lib/datastores"
+	"go-to-do-app/to-do-lib/events"
+	"go-to-do-app/to-do-lib/models"
+	"go-to-do-app/to-do-lib/webhooks"
+
+	"github.com/google/uuid"
+)
+
+// fastRetries also allows the loopback receivers of the tests
+var fastRetries = webhooks.Options{
+	Workers: 2, MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond,
+	AllowedNetworks: []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")},
+}
+
+func awaitStatus(t *testing.T, d *webhooks.Dispatcher, status string) webhooks.Delivery {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if list, _ := d.Deliveries("TestToDoUser", status); len(list) > 0 {
+			return list[0]
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("no delivery reached status %s", status)
+	return webhooks.Delivery{}
+}
+
+func TestDispatcherSignsDeliveries(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+	}))
+	defer receiver.Close()
+
+	store := datastores.NewInMemDataStore()
+	store.AddWebhook(datastores.WebhookSubscription{UserId: "TestToDoUser", URL: receiver.URL, Secret: "shh"})
+	d := webhooks.NewDispatcher(store, fastRetries)
+	d.Start()
+	defer d.Stop()
+
+	d.Publish(events.Event{Type: events.Created, Item: models.ToDo{UserId: "TestToDoUser", Title: "test", Priority: "High"}})
+	r, body := <-received, <-bodies
+	if expected := webhooks.Sign("shh", body); r.Header.Get(webhooks.SignatureHeader) != expected {
+		t.Errorf("Expected signature %s, Got %s", expected, r.Header.Get(webhooks.SignatureHeader))
+	}
+	if r.Header.Get(webhooks.EventHeader) != events.Created {
+		t.Errorf("Expected event header %s, Got %s", events.Created, r.Header.Get(webhooks.EventHeader))
+	}
+	var payload struct {
+		Event events.Event `json:"event"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil || payload.Event.Item.Title != "test" {
+		t.Errorf("Expected event payload for test item, Got %s", body)
+	}
+	awaitStatus(t, d, webhooks.StatusDelivered)
+}
+
+func TestDispatcherRetriesThenDeadLetters(t *testing.T) {
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	store := datastores.NewInMemDataStore()
+	store.AddWebhook(datastores.WebhookSubscription{UserId: "TestToDoUser", URL: receiver.URL})
+	d := webhooks.NewDispatcher(store, fastRetries)
+	d.Start()
+	defer d.Stop()
+
+	d.Publish(events.Event{Type: events.Created, Item: models.ToDo{UserId: "TestToDoUser", Title: "test"}})
+	dead := awaitStatus(t, d, webhooks.StatusDead)
+	if dead.Attempts != 3 || calls.Load() != 3 || dead.ResponseCode != http.StatusInternalServerError {
+		t.Errorf("Expected 3 failed attempts, Got %d calls and %+v", calls.Load(), dead)
+	}
+	redelivered, err := d.Redeliver(dead.Id)
+	if err != nil || redelivered.Status != webhooks.StatusPending || redelivered.Attempts != 0 {
+		t.Errorf("Expected dead delivery to be redelivered, Got: %+v, %v", redelivered, err)
+	}
+	if _, err := d.Redeliver(uuid.New()); err == nil {
+		t.Error("Expected an unknown delivery not to be redelivered")
+	}
+}
+
+func TestDispatcherRefusesInternalAddresses(t *testing.T) {
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer receiver.Close()
+
+	store := datastores.NewInMemDataStore()
+	// localhost resolves to the loopback address the dialer refuses
+	store.AddWebhook(datastores.WebhookSubscription{UserId: "TestToDoUser", URL: strings.Replace(receiver.URL, "127.0.0.1", "localhost", 1)})
+	opts := fastRetries
+	opts.AllowedNetworks = nil
+	d := webhooks.NewDispatcher(store, opts)
+	d.Start()
+	defer d.Stop()
+
+	d.Publish(events.Event{Type: events.Created, Item: models.ToDo{UserId: "TestToDoUser", Title: "test"}})
+	dead := awaitStatus(t, d, webhooks.StatusDead)
+	if calls.Load() != 0 || !strings.Contains(dead.LastError, "may not be sent") {
+		t.Errorf("Expected the delivery to be refused, Got %d calls and %+v", calls.Load(), dead)
+	}
+}
+
+func TestOptionsAllows(t *testing.T) {
+	opts := webhooks.Options{AllowedNetworks: []netip.Prefix{netip.MustParsePrefix("10.1.0.0/16")}}
+	cases := []struct {
+		addr     string
+		expected bool
+	}{
+		{"93.184.215.14", true},
+		{"2606:2800:21f:cb07:6820:80da:af6b:8b2c", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"10.0.0.1", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fd00:ec2::254", false},
+		{"fe80::1", false},
+		{"100.100.100.200", false},
+		{"0.0.0.0", false},
+		{"10.1.2.3", true},
+	}
+	for _, c := range cases {
+		if actual := opts.Allows(netip.MustParseAddr(c.addr)); actual != c.expected {
+			t.Errorf("%s: Expected %t, Got %t", c.addr, c.expected, actual)
+		}
+	}
+}
+
+func TestMatchesFilters(t *testing.T) {
+	sub := datastores.WebhookSubscription{EventTypes: []string{webhooks.EventCompleted, events.Created}, Priorities: []string{"high"}}
+	open := models.ToDo{Priority: "High"}
+	done := models.ToDo{Priority: "High", Complete: true}
+	cases := []struct {
+		name     string
+		event    events.Event
+		expected bool
+	}{
+		{"created high", events.Event{Type: events.Created, Item: open}, true},
+		{"created low", events.Event{Type: events.Created, Item: models.ToDo{Priority: "Low"}}, false},
+		{"completed", events.Event{Type: events.Updated, Item: done, Previous: &open}, true},
+		{"already complete", events.Event{Type: events.Updated, Item: done, Previous: &done}, false},
+		{"deleted", events.Event{Type: events.Deleted, Item: open}, false},
+	}
+	for _, c := range cases {
+		if actual := webhooks.Matches(sub, c.event); actual != c.expected {
+			t.Errorf("%s: Expected %t, Got %t", c.name, c.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"os"
+)
+
+// embeddedAssets are the templates, styles & api specs built into the binary,
+// so the server can be started from any directory.
+//
+//go:embed templates api-specs
+var embeddedAssets embed.FS
+
+// serverAssets are the embedded assets, or in dev mode the files on disk
+// under dir, so edits show without rebuilding.
+func serverAssets(cfg Config) fs.FS {
+	if cfg.Dev {
+		return os.DirFS(cfg.AssetsDir)
+	}
+	return embeddedAssets
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"go-to-do-app/to-do-lib/config"
+	"go-to-do-app/to-do-lib/logging"
+	"go-to-do-app/to-do-lib/models"
+	"go-to-do-app/to-do-lib/notify"
+	"go-to-do-app/to-do-lib/ratelimit"
+	"go-to-do-app/to-do-lib/webhooks"
+	"go-to-do-app/to-do-server/server"
+)
+
+// Config is every setting of the server. Settings are read, from lowest to
+// highest precedence, from the defaults, a YAML or TOML config file, TODO_*
+// environment variables and the command line flags.
+type Config struct {
+	Mode     string `config:"mode" flag:"mode" usage:"set the mode the application should run in (in-mem, json-store, pgdb)"`
+	Address  string `config:"address" flag:"address" usage:"set the address for the server"`
+	JSONPath string `config:"json.path" flag:"json" usage:"filepath of json file to use as datastore"`
+	Database struct {
+		Name     string `config:"name" flag:"dbname" usage:"database name"`
+		User     string `config:"user" flag:"user" usage:"database username"`
+		Password string `config:"password" flag:"password" usage:"database password" secret:"true"`
+	} `config:"database"`
+	Dev               bool          `config:"dev" flag:"dev" usage:"serve templates, styles & api specs from --assets-dir on disk, reloading templates when they change"`
+	AssetsDir         string        `config:"assets.dir" flag:"assets-dir" usage:"directory holding the templates & api-specs directories, for --dev"`
+	LogLevel          string        `config:"log.level" flag:"log-level" usage:"lowest level logged (debug, info, warn, error)"`
+	IdempotencyWindow time.Duration `config:"idempotency.window" flag:"idempotency-window" usage:"how long responses are replayed for a repeated Idempotency-Key"`
+	TrashRetention    time.Duration `config:"trash.retention" flag:"trash-retention" usage:"how long deleted items are kept in the trash before being purged"`
+	Admins            []string      `config:"admins" flag:"admins" usage:"comma separated user ids allowed to manage webhook deliveries under /v2/admin with their client certificates, which is not served without any"`
+	Webhooks          struct {
+		AllowedNetworks []string `config:"allowed-networks" flag:"webhook-allowed-networks" usage:"comma separated CIDR networks webhooks may be sent to though they are internal, such as 127.0.0.0/8 for a receiver on the same host"`
+	} `config:"webhooks"`
+	Priorities []string `config:"priorities" flag:"priorities" usage:"comma separated priority levels, least pressing first, each a name or name=rank"`
+	Workflow   struct {
+		Initial     string   `config:"initial" flag:"workflow-initial" usage:"status new items start at"`
+		Done        []string `config:"done" flag:"workflow-done" usage:"comma separated statuses items are complete at, the first being where v1 & v2 clients complete them to"`
+		Transitions []string `config:"transitions" flag:"workflow-transitions" usage:"comma separated moves allowed between statuses, each written as from>to"`
+	} `config:"workflow"`
+	Attachments struct {
+		Dir     string `config:"dir" flag:"attachments-dir" usage:"directory attachments are stored in when no s3 endpoint is set"`
+		MaxSize int64  `config:"max-size" flag:"max-attachment-size" usage:"largest attachment accepted, in bytes"`
+		S3      struct {
+			Endpoint  string `config:"endpoint" flag:"s3-endpoint" usage:"base url of an s3 compatible service to store attachments in"`
+			Bucket    string `config:"bucket" flag:"s3-bucket" usage:"bucket attachments are stored in"`
+			Region    string `config:"region" flag:"s3-region" usage:"region of the s3 bucket"`
+			AccessKey string `config:"access-key" flag:"s3-access-key" usage:"s3 access key id"`
+			SecretKey string `config:"secret-key" flag:"s3-secret-key" usage:"s3 secret access key" secret:"true"`
+		} `config:"s3"`
+	} `config:"attachments"`
+	Limits struct {
+		IPRate      float64 `config:"ip.rate" flag:"rate-limit-ip" usage:"requests per second allowed from each client address, 0 disables the limit"`
+		IPBurst     int     `config:"ip.burst" flag:"rate-burst-ip" usage:"requests a client address may burst above its rate"`
+		UserRate    float64 `config:"user.rate" flag:"rate-limit-user" usage:"requests per second allowed for each user named by a client certificate, 0 disables the limit"`
+		UserBurst   int     `config:"user.burst" flag:"rate-burst-user" usage:"requests a user may burst above their rate"`
+		MaxBodySize int64   `config:"max-body-size" flag:"max-body-size" usage:"largest request body accepted, in bytes, apart from attachments"`
+	} `config:"limits"`
+	CORS struct {
+		Origins     []string      `config:"origins" flag:"cors-origins" usage:"comma separated origins browser apps may call the api from, * for any"`
+		Methods     []string      `config:"methods" flag:"cors-methods" usage:"comma separated methods allowed cross origin"`
+		Headers     []string      `config:"headers" flag:"cors-headers" usage:"comma separated request headers allowed cross origin"`
+		Credentials bool          `config:"credentials" flag:"cors-credentials" usage:"allow cross origin requests with cookies & client certificates"`
+		MaxAge      time.Duration `config:"max-age" flag:"cors-max-age" usage:"how long browsers may cache preflight responses"`
+	} `config:"cors"`
+	TLS struct {
+		Cert              string   `config:"cert" flag:"tls-cert" usage:"PEM certificate file, serving https when set"`
+		Key               string   `config:"key" flag:"tls-key" usage:"PEM private key file of the certificate"`
+		SelfSigned        bool     `config:"self-signed" flag:"tls-self-signed" usage:"generate a self-signed certificate for local development, if the cert & key files do not exist"`
+		ClientCA          string   `config:"client-ca" flag:"tls-client-ca" usage:"PEM file of CAs trusted to sign client certificates, enabling mutual TLS"`
+		RequireClientCert bool     `config:"require-client-cert" flag:"tls-require-client-cert" usage:"reject clients without a certificate signed by the client CA"`
+		ClientUsers       []string `config:"client-users" flag:"tls-client-users" usage:"comma separated common-name=user-id pairs mapping client certificates to users"`
+		RedirectAddress   string   `config:"redirect-address" flag:"tls-redirect-address" usage:"http address redirecting to https, such as :80"`
+	} `config:"tls"`
+	Notifications struct {
+		SMTP struct {
+			Address  string `config:"address" flag:"smtp-address" usage:"host:port of the SMTP server notifications are emailed through, enabling them when set"`
+			Username string `config:"username" flag:"smtp-username" usage:"SMTP username, if the server needs one"`
+			Password string `config:"password" flag:"smtp-password" usage:"SMTP password" secret:"true"`
+			From     string `config:"from" flag:"smtp-from" usage:"address notifications are sent from"`
+		} `config:"smtp"`
+		Domain    string        `config:"domain" flag:"notify-domain" usage:"domain users are emailed at, as user-id@domain"`
+		Addresses []string      `config:"addresses" flag:"notify-addresses" usage:"comma separated user-id=email pairs, overriding the notify domain"`
+		DueWithin time.Duration `config:"due-within" flag:"notify-due-within" usage:"how long before an item is due its reminder is sent"`
+	} `config:"notifications"`
+}
+
+// selfSignedCert & selfSignedKey are where a development certificate is
+// written when no paths are set.
+const (
+	selfSignedCert = "dev-cert.pem"
+	selfSignedKey  = "dev-key.pem"
+)
+
+// reloadable are the settings applied to a running server on SIGHUP. Changes
+// to any other setting need a restart.
+var reloadable = []string{"log.level", "priorities", "workflow.initial", "workflow.done", "workflow.transitions", "limits.ip.rate", "limits.ip.burst", "limits.user.rate", "limits.user.burst"}
+
+var modes = []string{"in-mem", "json-store", "pgdb"}
+
+func defaultConfig() Config {
+	var cfg Config
+	cfg.Address = ":8081"
+	cfg.Database.Name = "todo"
+	cfg.Database.User = "postgres"
+	cfg.AssetsDir = "."
+	cfg.LogLevel = "info"
+	cfg.IdempotencyWindow = server.DefaultIdempotencyWindow
+	cfg.TrashRetention = server.DefaultTrashRetention
+	for _, level := range models.DefaultPriorityLevels {
+		cfg.Priorities = append(cfg.Priorities, fmt.Sprintf("%s=%d", level.Name, level.Rank))
+	}
+	cfg.Workflow.Initial = string(models.DefaultWorkflow.Initial)
+	for _, status := range models.DefaultWorkflow.Done {
+		cfg.Workflow.Done = append(cfg.Workflow.Done, string(status))
+	}
+	for _, t := range models.DefaultWorkflow.Transitions {
+		cfg.Workflow.Transitions = append(cfg.Workflow.Transitions, fmt.Sprintf("%s>%s", t.From, t.To))
+	}
+	cfg.Attachments.Dir = "attachments"
+	cfg.Attachments.MaxSize = server.DefaultMaxAttachmentSize
+	cfg.Attachments.S3.Region = "us-east-1"
+	cfg.Limits.IPRate = 20
+	cfg.Limits.IPBurst = 40
+	cfg.Limits.UserRate = 10
+	cfg.Limits.UserBurst = 20
+	cfg.Limits.MaxBodySize = server.DefaultMaxBodySize
+	cfg.CORS.Methods = server.DefaultCORSMethods
+	cfg.CORS.Headers = server.DefaultCORSHeaders
+	cfg.CORS.MaxAge = 10 * time.Minute
+	cfg.Notifications.DueWithin = 24 * time.Hour
+	return cfg
+}
+
+// loadConfig layers the config file at path, if any, the environment and the
+// flags given on the command line over the defaults.
+func loadConfig(path string, flags *config.Flags) (Config, error) {
+	cfg := defaultConfig()
+	var file config.Values
+	if path != "" {
+		var err error
+		if file, err = config.ReadFile(path); err != nil {
+			return cfg, err
+		}
+	}
+	env, err := config.FromEnv(&cfg)
+	if err != nil {
+		return cfg, err
+	}
+	if err := config.Apply(&cfg, file, env, flags.Values()); err != nil {
+		return cfg, err
+	}
+	cfg.applySelfSigned()
+	return cfg, nil
+}
+
+// Validate reports every invalid setting at once.
+func (c Config) Validate() error {
+	var errs []error
+	if !slices.Contains(modes, c.Mode) {
+		errs = append(errs, fmt.Errorf("mode must be one of %v, got %q", modes, c.Mode))
+	}
+	if c.Mode == "json-store" && filepath.Ext(c.JSONPath) != ".json" {
+		errs = append(errs, fmt.Errorf("json.path must be a .json file for the json-store mode, got %q", c.JSONPath))
+	}
+	if c.Mode == "pgdb" && c.Database.Name == "" {
+		errs = append(errs, errors.New("database.name is required for the pgdb mode"))
+	}
+	if c.Dev {
+		if info, err := os.Stat(filepath.Join(c.AssetsDir, "templates")); err != nil || !info.IsDir() {
+			errs = append(errs, fmt.Errorf("assets.dir %q has no templates directory for dev mode", c.AssetsDir))
+		}
+	}
+	if _, err := logging.ParseLevel(c.LogLevel); err != nil {
+		errs = append(errs, fmt.Errorf("log.level: %w", err))
+	}
+	if _, err := models.ParsePriorityLevels(c.Priorities); err != nil {
+		errs = append(errs, fmt.Errorf("priorities: %w", err))
+	}
+	if _, err := c.workflow(); err != nil {
+		errs = append(errs, fmt.Errorf("workflow: %w", err))
+	}
+	if c.IdempotencyWindow <= 0 || c.TrashRetention <= 0 {
+		errs = append(errs, errors.New("idempotency.window and trash.retention must be positive"))
+	}
+	if c.Attachments.MaxSize <= 0 || c.Limits.MaxBodySize <= 0 {
+		errs = append(errs, errors.New("attachments.max-size and limits.max-body-size must be positive"))
+	}
+	if c.Attachments.S3.Endpoint != "" && c.Attachments.S3.Bucket == "" {
+		errs = append(errs, errors.New("attachments.s3.bucket is required with an s3 endpoint"))
+	}
+	if c.Limits.IPRate < 0 || c.Limits.UserRate < 0 || c.Limits.IPBurst < 0 || c.Limits.UserBurst < 0 {
+		errs = append(errs, errors.New("rate limits and bursts must not be negative"))
+	}
+	if err := c.corsOptions().Validate(); err != nil {
+		errs = append(errs, errors.New("cors.origins cannot be * when cors.credentials is set"))
+	}
+	if c.CORS.MaxAge < 0 {
+		errs = append(errs, errors.New("cors.max-age must not be negative"))
+	}
+	if (c.TLS.Cert == "") != (c.TLS.Key == "") {
+		errs = append(errs, errors.New("tls.cert and tls.key must be set together"))
+	}
+	if c.TLS.Cert == "" && (c.TLS.ClientCA != "" || c.TLS.RedirectAddress != "") {
+		errs = append(errs, errors.New("tls.client-ca and tls.redirect-address need a tls.cert"))
+	}
+	if c.TLS.RequireClientCert && c.TLS.ClientCA == "" {
+		errs = append(errs, errors.New("tls.require-client-cert needs a tls.client-ca"))
+	}
+	if len(c.Admins) > 0 && c.TLS.ClientCA == "" {
+		errs = append(errs, errors.New("admins need a tls.client-ca, as they are named by their client certificates"))
+	}
+	if _, err := c.clientUsers(); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := c.webhooks(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Notifications.SMTP.Address != "" && c.Notifications.SMTP.From == "" {
+		errs = append(errs, errors.New("notifications.smtp.from is required with an smtp address"))
+	}
+	if _, err := c.notifyAddresses(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Notifications.DueWithin <= 0 {
+		errs = append(errs, errors.New("notifications.due-within must be positive"))
+	}
+	return errors.Join(errs...)
+}
+
+// applySelfSigned points the cert & key at the development certificate when
+// self-signed certificates are enabled without paths.
+func (c *Config) applySelfSigned() {
+	if c.TLS.SelfSigned && c.TLS.Cert == "" && c.TLS.Key == "" {
+		c.TLS.Cert, c.TLS.Key = selfSignedCert, selfSignedKey
+	}
+}
+
+func (c Config) clientUsers() (map[string]string, error) {
+	users := map[string]string{}
+	for _, pair := range c.TLS.ClientUsers {
+		name, user, found := strings.Cut(pair, "=")
+		if !found || name == "" || user == "" {
+			return nil, fmt.Errorf("tls.client-users must be common-name=user-id pairs, got %q", pair)
+		}
+		users[name] = user
+	}
+	return users, nil
+}
+
+func (c Config) webhooks() (webhooks.Options, error) {
+	var opts webhooks.Options
+	for _, network := range c.Webhooks.AllowedNetworks {
+		prefix, err := netip.ParsePrefix(network)
+		if err != nil {
+			return webhooks.Options{}, fmt.Errorf("webhooks.allowed-networks must be CIDR networks, got %q", network)
+		}
+		opts.AllowedNetworks = append(opts.AllowedNetworks, prefix)
+	}
+	return opts, nil
+}
+
+func (c Config) notifyAddresses() (map[string]string, error) {
+	addresses := map[string]string{}
+	for _, pair := range c.Notifications.Addresses {
+		user, address, found := strings.Cut(pair, "=")
+		if !found || user == "" || !strings.Contains(address, "@") {
+			return nil, fmt.Errorf("notifications.addresses must be user-id=email pairs, got %q", pair)
+		}
+		addresses[user] = address
+	}
+	return addresses, nil
+}
+
+// notifications returns the options of the notifier, which has no sender
+// when no smtp address is set.
+func (c Config) notifications() notify.Options {
+	users, _ := c.notifyAddresses()
+	opts := notify.Options{
+		Addresses: notify.Addresses{Users: users, Domain: c.Notifications.Domain},
+		DueWithin: c.Notifications.DueWithin,
+	}
+	if smtp := c.Notifications.SMTP; smtp.Address != "" {
+		opts.Sender = notify.SMTPSender{Addr: smtp.Address, From: smtp.From, Username: smtp.Username, Password: smtp.Password}
+	}
+	return opts
+}
+
+func (c Config) workflow() (models.Workflow, error) {
+	return models.ParseWorkflow(c.Workflow.Initial, c.Workflow.Done, c.Workflow.Transitions)
+}
+
+func (c Config) ipRateLimit() ratelimit.Limit {
+	return ratelimit.Limit{Rate: c.Limits.IPRate, Burst: c.Limits.IPBurst}
+}
+
+func (c Config) userRateLimit() ratelimit.Limit {
+	return ratelimit.Limit{Rate: c.Limits.UserRate, Burst: c.Limits.UserBurst}
+}
+
+func (c Config) corsOptions() server.CORSOptions {
+	return server.CORSOptions{
+		AllowedOrigins:   c.CORS.Origins,
+		AllowedMethods:   c.CORS.Methods,
+		AllowedHeaders:   c.CORS.Headers,
+		AllowCredentials: c.CORS.Credentials,
+		MaxAge:           c.CORS.MaxAge,
+	}
+}
+
+// changedSettings lists the keys whose values differ between two configs.
+func changedSettings(before, after Config) []string {
+	old, _ := config.Dump(&before, false)
+	updated, _ := config.Dump(&after, false)
+	var changed []string
+	for key, value := range updated {
+		if old[key] != value {
+			changed = append(changed, key)
+		}
+	}
+	slices.Sort(changed)
+	return changed
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"go-to-do-app/to-do-lib/logging"
+	"go-to-do-app/to-do-lib/markdown"
+)
+
+// templateFuncs are available to every page template
+var templateFuncs = template.FuncMap{"markdown": markdown.ToHTML}
+
+// assets serves the page templates, stylesheet and api specs. Templates are
+// parsed once, unless reload is set, when a template is parsed again whenever
+// its file has changed.
+type assets struct {
+	fs     fs.FS
+	reload bool
+
+	mut       sync.Mutex
+	templates map[string]parsedTemplate
+}
+
+type parsedTemplate struct {
+	tmpl    *template.Template
+	modTime time.Time
+}
+
+func newAssets(fsys fs.FS, reload bool) *assets {
+	a := &assets{fs: fsys, reload: reload, templates: map[string]parsedTemplate{}}
+	names, err := fs.Glob(fsys, "templates/*.html")
+	if err != nil || len(names) == 0 {
+		logging.LogWithTrace(context.Background(), map[string]interface{}{}, "no page templates found")
+	}
+	for _, name := range names {
+		if _, err := a.template(name); err != nil {
+			logging.LogWithTrace(context.Background(), map[string]interface{}{"error": err.Error(), "template": name}, "unable to parse template")
+		}
+	}
+	return a
+}
+
+// template returns the parsed template at name.
+func (a *assets) template(name string) (*template.Template, error) {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	parsed, exists := a.templates[name]
+	var modTime time.Time
+	if a.reload || !exists {
+		info, err := fs.Stat(a.fs, name)
+		if err != nil {
+			return nil, err
+		}
+		modTime = info.ModTime()
+	}
+	if exists && (!a.reload || modTime.Equal(parsed.modTime)) {
+		return parsed.tmpl, nil
+	}
+	tmpl, err := template.New(path.Base(name)).Funcs(templateFuncs).ParseFS(a.fs, name)
+	if err != nil {
+		return nil, err
+	}
+	a.templates[name] = parsedTemplate{tmpl: tmpl, modTime: modTime}
+	return tmpl, nil
+}
+
+func (a *assets) serveTemplate(name string, data interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tmpl, err := a.template(name)
+		if err != nil {
+			http.Error(w, "Error parsing template", http.StatusInternalServerError)
+			return
+		}
+		// render fully before writing, so a failure can still be reported
+		var page bytes.Buffer
+		if err := tmpl.Execute(&page, data); err != nil {
+			http.Error(w, "Error rendering template", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(page.Bytes())
+	}
+}
+
+func (a *assets) serveFile(name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFileFS(w, r, a.fs, name)
+	}
+}
+
+// defaultAssets reads the assets from the working directory, for servers
+// started from the to-do-server directory without embedded assets.
+func defaultAssets() fs.FS {
+	return os.DirFS(".")
+}
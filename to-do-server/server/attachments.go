@@ -0,0 +1,191 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-to-do-app/to-do-lib/datastores"
+	"go-to-do-app/to-do-lib/logging"
+	"go-to-do-app/to-do-lib/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultMaxAttachmentSize = 10 << 20
+	// multipartOverhead allows for the multipart headers around the file
+	multipartOverhead = 64 << 10
+	// multipartMemory is how much of an upload is held in memory before it
+	// is spooled to a temporary file
+	multipartMemory = 1 << 20
+)
+
+// DefaultAttachmentTypes are the content types accepted for attachments when
+// none are configured. Types are sniffed from the uploaded bytes rather than
+// taken from the client.
+var DefaultAttachmentTypes = []string{
+	"image/png", "image/jpeg", "image/gif", "image/webp", "application/pdf", "text/plain",
+}
+
+func attachmentsHTTPHandler(datastore datastores.DataStore, opts Options) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if opts.Blobs == nil {
+			writeErrorResponse(w, r, http.StatusNotImplemented, "attachments are not configured")
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			if r.URL.Query().Has("attachment_id") {
+				downloadAttachment(datastore, opts, w, r)
+			} else {
+				listAttachments(datastore, w, r)
+			}
+		case http.MethodPost:
+			uploadAttachment(datastore, opts, w, r)
+		case http.MethodDelete:
+			deleteAttachment(datastore, opts, w, r)
+		default:
+			methodNotAllowed(w, r, http.MethodGet, http.MethodPost, http.MethodDelete)
+		}
+	}
+}
+
+// attachmentQuery reads the user_id, id & attachment_id query parameters that
+// identify an attachment, writing the error response when they do not.
+func attachmentQuery(datastore datastores.DataStore, w http.ResponseWriter, r *http.Request, required models.Role) (string, uuid.UUID, uuid.UUID, bool) {
+	userId, itemId, ok := sharedItemQuery(datastore, w, r, required)
+	if !ok {
+		return "", uuid.Nil, uuid.Nil, false
+	}
+	id, err := uuid.Parse(r.URL.Query().Get("attachment_id"))
+	if err != nil {
+		writeErrorResponse(w, r, http.StatusBadRequest, "missing 'attachment_id' query paramater")
+		return "", uuid.Nil, uuid.Nil, false
+	}
+	return userId, itemId, id, true
+}
+
+func listAttachments(datastore datastores.DataStore, w http.ResponseWriter, r *http.Request) {
+	userId, itemId, ok := sharedItemQuery(datastore, w, r, models.RoleViewer)
+	if !ok {
+		return
+	}
+	attachments, err := datastore.ListAttachments(userId, itemId)
+	if err != nil {
+		handleDataStoreError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, attachments)
+}
+
+// uploadAttachment stores the "file" part of a multipart form against an item.
+func uploadAttachment(datastore datastores.DataStore, opts Options, w http.ResponseWriter, r *http.Request) {
+	userId, itemId, ok := sharedItemQuery(datastore, w, r, models.RoleEditor)
+	if !ok {
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, opts.MaxAttachmentSize+multipartOverhead)
+	if err := r.ParseMultipartForm(multipartMemory); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeErrorResponse(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("attachments are limited to %d bytes", opts.MaxAttachmentSize))
+			return
+		}
+		writeErrorResponse(w, r, http.StatusBadRequest, "expected a multipart/form-data body")
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeErrorResponse(w, r, http.StatusBadRequest, "missing 'file' form field")
+		return
+	}
+	defer file.Close()
+	if header.Size > opts.MaxAttachmentSize {
+		writeErrorResponse(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("attachments are limited to %d bytes", opts.MaxAttachmentSize))
+		return
+	}
+
+	sniff := make([]byte, 512)
+	n, _ := io.ReadFull(file, sniff)
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(sniff[:n]))
+	if !slices.Contains(opts.AttachmentTypes, contentType) {
+		writeErrorResponse(w, r, http.StatusUnsupportedMediaType, fmt.Sprintf("attachments of type %s are not allowed", contentType))
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		handleDataStoreError(w, r, err)
+		return
+	}
+
+	attachment := models.Attachment{
+		Id:          uuid.New(),
+		UserId:      userId,
+		ItemId:      itemId,
+		Filename:    filepath.Base(strings.ReplaceAll(header.Filename, `\`, "/")),
+		ContentType: contentType,
+		Size:        header.Size,
+		CreatedAt:   time.Now(),
+	}
+	if err := opts.Blobs.Put(r.Context(), attachment.Id.String(), file, attachment.Size, contentType); err != nil {
+		handleDataStoreError(w, r, err)
+		return
+	}
+	stored, err := datastore.AddAttachment(attachment)
+	if err != nil {
+		opts.Blobs.Delete(r.Context(), attachment.Id.String())
+		handleDataStoreError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusCreated, stored)
+}
+
+func downloadAttachment(datastore datastores.DataStore, opts Options, w http.ResponseWriter, r *http.Request) {
+	userId, itemId, id, ok := attachmentQuery(datastore, w, r, models.RoleViewer)
+	if !ok {
+		return
+	}
+	attachment, err := datastore.GetAttachment(userId, itemId, id)
+	if err != nil {
+		handleDataStoreError(w, r, err)
+		return
+	}
+	contents, err := opts.Blobs.Get(r.Context(), attachment.Id.String())
+	if err != nil {
+		handleDataStoreError(w, r, err)
+		return
+	}
+	defer contents.Close()
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, contents); err != nil {
+		logging.LogWithTrace(r.Context(), map[string]interface{}{"error": err.Error(), "attachmentId": id}, "attachment download interrupted")
+	}
+}
+
+func deleteAttachment(datastore datastores.DataStore, opts Options, w http.ResponseWriter, r *http.Request) {
+	userId, itemId, id, ok := attachmentQuery(datastore, w, r, models.RoleEditor)
+	if !ok {
+		return
+	}
+	attachment, err := datastore.DeleteAttachment(userId, itemId, id)
+	if err != nil {
+		handleDataStoreError(w, r, err)
+		return
+	}
+	if err := opts.Blobs.Delete(r.Context(), attachment.Id.String()); err != nil {
+		logging.LogWithTrace(r.Context(), map[string]interface{}{"error": err.Error(), "attachmentId": id}, "unable to delete attachment contents")
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"go-to-do-app/to-do-lib/datastores"
+	todoerrors "go-to-do-app/to-do-lib/errors"
+	"go-to-do-app/to-do-lib/models"
+)
+
+const maxBatchOps = 1000
+
+type batchRequest struct {
+	Operations []datastores.BatchOp `json:"operations"`
+}
+
+type batchItemResult struct {
+	Index  int          `json:"index"`
+	Op     string       `json:"op"`
+	Status int          `json:"status"`
+	Item   *models.ToDo `json:"item,omitempty"`
+	Error  string       `json:"error,omitempty"`
+}
+
+type batchResponse struct {
+	Results []batchItemResult `json:"results"`
+}
+
+func batchHTTPHandler(datastore datastores.DataStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, r, http.MethodPost)
+			return
+		}
+		postBatch(datastore, w, r)
+	}
+}
+
+// validateBatchOp checks the item of a create or update against the v2 rules.
+// Deletes only need to identify the item.
+func validateBatchOp(op *datastores.BatchOp) error {
+	switch op.Op {
+	case datastores.OpCreate, datastores.OpUpdate:
+		return op.Item.Validate(models.V2)
+	case datastores.OpDelete:
+		if op.Item.UserId == "" {
+			return &todoerrors.ValidationError{Field: "user_id", Err: errors.New("invalid user_id")}
+		}
+		return nil
+	default:
+		return &todoerrors.ValidationError{Field: "op", Err: fmt.Errorf("invalid op: %s", op.Op)}
+	}
+}
+
+// authorizeBatchOp checks the user may make op, with the same rules as the
+// single item requests: items are added to the user's own list, updated by
+// their editors and deleted by their owner. Ops on shared items are made as
+// the owner.
+func authorizeBatchOp(datastore datastores.DataStore, userId string, op *datastores.BatchOp) error {
+	switch op.Op {
+	case datastores.OpCreate:
+		if op.Item.UserId != userId {
+			return &todoerrors.ForbiddenError{Message: fmt.Sprintf("only %s may add ToDos to their list", op.Item.UserId)}
+		}
+	case datastores.OpUpdate:
+		ownerId, err := checkAccess(datastore, userId, op.Item.Id, models.RoleEditor)
+		if err != nil {
+			return err
+		}
+		if ownerId != userId {
+			current, err := datastore.GetItem(ownerId, op.Item.Id)
+			if err != nil {
+				return err
+			}
+			if current.Assignee != op.Item.Assignee {
+				return errAssigneeOwnerOnly
+			}
+		}
+		op.Item.UserId = ownerId
+	case datastores.OpDelete:
+		ownerId, err := checkAccess(datastore, userId, op.Item.Id, models.RoleOwner)
+		if err != nil {
+			return err
+		}
+		op.Item.UserId = ownerId
+	}
+	return nil
+}
+
+func postBatch(datastore datastores.DataStore, w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	userId, ok := caller(w, r)
+	if !ok {
+		return
+	}
+	var req batchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+	if len(req.Operations) == 0 || len(req.Operations) > maxBatchOps {
+		writeErrorResponse(w, r, http.StatusBadRequest, fmt.Sprintf("a batch must contain between 1 and %d operations", maxBatchOps))
+		return
+	}
+
+	results := make([]batchItemResult, len(req.Operations))
+	valid := make([]datastores.BatchOp, 0, len(req.Operations))
+	indexes := make([]int, 0, len(req.Operations))
+	for i := range req.Operations {
+		op := &req.Operations[i]
+		results[i] = batchItemResult{Index: i, Op: op.Op}
+		err := validateBatchOp(op)
+		if err == nil {
+			err = authorizeBatchOp(datastore, userId, op)
+		}
+		if err != nil {
+			results[i].Status, results[i].Error = errorStatus(err)
+			continue
+		}
+		valid = append(valid, *op)
+		indexes = append(indexes, i)
+	}
+
+	if len(valid) > 0 {
+		stored, err := datastores.WithHistory(r.Context(), datastore).Batch(valid)
+		if err != nil {
+			handleDataStoreError(w, r, err)
+			return
+		}
+		for j, res := range stored {
+			i := indexes[j]
+			if res.Err != nil {
+				results[i].Status, results[i].Error = errorStatus(res.Err)
+				continue
+			}
+			results[i].Status = http.StatusOK
+			switch valid[j].Op {
+			case datastores.OpCreate:
+				results[i].Status = http.StatusCreated
+			case datastores.OpDelete:
+				results[i].Status = http.StatusNoContent
+				continue
+			}
+			item := res.Item
+			results[i].Item = &item
+		}
+	}
+
+	writeJSON(w, r, http.StatusOK, batchResponse{Results: results})
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"go-to-do-app/to-do-lib/datastores"
+	"go-to-do-app/to-do-lib/models"
+
+	"github.com/google/uuid"
+)
+
+func TestBatchResults(t *testing.T) {
+	datastore := datastores.NewInMemDataStore()
+	existing := datastore.AddItem(models.ToDo{UserId: "alice", Title: "existing", Priority: "Low"})
+	toDelete := datastore.AddItem(models.ToDo{UserId: "alice", Title: "delete me", Priority: "Low"})
+	stale := datastore.AddItem(models.ToDo{UserId: "alice", Title: "stale", Priority: "Low"})
+	others := datastore.AddItem(models.ToDo{UserId: "bob", Title: "bob's", Priority: "Low"})
+	srv := testServer(t, datastore)
+
+	update := func(item models.ToDo, title string, revision int64) datastores.BatchOp {
+		item.Title = title
+		item.Revision = revision
+		return datastores.BatchOp{Op: datastores.OpUpdate, Item: item}
+	}
+	tests := []struct {
+		op     datastores.BatchOp
+		status int
+	}{
+		{datastores.BatchOp{Op: datastores.OpCreate, Item: models.ToDo{UserId: "alice", Title: "created", Priority: "High"}}, http.StatusCreated},
+		{update(existing, "renamed", 0), http.StatusOK},
+		{datastores.BatchOp{Op: datastores.OpDelete, Item: toDelete}, http.StatusNoContent},
+		{update(models.ToDo{UserId: "alice", Id: uuid.Max, Priority: "Low"}, "missing", 0), http.StatusNotFound},
+		{update(stale, "stale", stale.Revision+1), http.StatusConflict},
+		{datastores.BatchOp{Op: datastores.OpCreate, Item: models.ToDo{UserId: "alice", Priority: "High"}}, http.StatusBadRequest},
+		{datastores.BatchOp{Op: "upsert", Item: existing}, http.StatusBadRequest},
+		{datastores.BatchOp{Op: datastores.OpCreate, Item: models.ToDo{UserId: "bob", Title: "for bob", Priority: "Low"}}, http.StatusForbidden},
+		{datastores.BatchOp{Op: datastores.OpDelete, Item: others}, http.StatusNotFound},
+	}
+	ops := make([]datastores.BatchOp, len(tests))
+	for i, test := range tests {
+		ops[i] = test.op
+	}
+	body, _ := json.Marshal(batchRequest{Operations: ops})
+	resp, data := send(t, nil, http.MethodPost, srv.URL+"/v2/todos:batch", "alice", string(body))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected: %d, Got: %d", http.StatusOK, resp.StatusCode)
+	}
+	var actual batchResponse
+	if err := json.Unmarshal(data, &actual); err != nil {
+		t.Fatal(err)
+	}
+	if len(actual.Results) != len(tests) {
+		t.Fatalf("Expected %d results, Got %d", len(tests), len(actual.Results))
+	}
+	for i, test := range tests {
+		result := actual.Results[i]
+		if result.Index != i || result.Op != test.op.Op || result.Status != test.status {
+			t.Errorf("op %d: Expected: %d %s %d, Got: %+v", i, i, test.op.Op, test.status, result)
+		}
+		if ok := result.Status < http.StatusBadRequest; ok == (result.Error != "") {
+			t.Errorf("op %d: Expected an error only for a failed op, Got: %+v", i, result)
+		}
+	}
+
+	// the results carry the stored items, and the failures changed nothing
+	if created := actual.Results[0].Item; created == nil || created.Title != "created" || created.Id == uuid.Nil {
+		t.Errorf("Expected the created item, Got: %+v", created)
+	}
+	if renamed, _ := datastore.GetItem("alice", existing.Id); renamed.Title != "renamed" || actual.Results[1].Item == nil || actual.Results[1].Item.Revision != renamed.Revision {
+		t.Errorf("Expected: %+v, Got: %+v", renamed, actual.Results[1].Item)
+	}
+	if actual.Results[2].Item != nil {
+		t.Errorf("Expected no item for a delete, Got: %+v", actual.Results[2].Item)
+	}
+	if _, err := datastore.GetItem("alice", toDelete.Id); err == nil {
+		t.Errorf("Expected deleted item %s to be gone", toDelete.Id)
+	}
+	if current, _ := datastore.GetItem("alice", stale.Id); !reflect.DeepEqual(current, stale) {
+		t.Errorf("Expected: %+v, Got: %+v", stale, current)
+	}
+	if _, err := datastore.GetItem("bob", others.Id); err != nil {
+		t.Errorf("Expected: %+v, Got: %+v", nil, err)
+	}
+	if items, _ := datastore.ListItems("bob", datastores.OrderByTitle); len(items) != 1 {
+		t.Errorf("Expected: %d, Got: %d", 1, len(items))
+	}
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-to-do-app/to-do-lib/logging"
+)
+
+// CORSOptions lets browser apps on other origins call the api. CORS is
+// disabled when AllowedOrigins is empty.
+type CORSOptions struct {
+	// AllowedOrigins are the origins, such as https://app.example.com,
+	// allowed to call the api. "*" allows any origin, and
+	// https://*.example.com any subdomain.
+	AllowedOrigins []string
+	// AllowedMethods & AllowedHeaders are accepted in preflight requests.
+	AllowedMethods []string
+	AllowedHeaders []string
+	// AllowCredentials lets browsers send cookies & client certificates. It
+	// cannot be combined with the "*" origin, see Validate.
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response.
+	MaxAge time.Duration
+}
+
+var (
+	DefaultCORSMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete}
+	DefaultCORSHeaders = []string{"Content-Type", "Idempotency-Key", actorHeader, "Last-Event-ID"}
+	// corsExposedHeaders are the response headers readable by browser apps
+	corsExposedHeaders = "X-Trace-Id, Retry-After, Idempotent-Replayed, Content-Disposition"
+)
+
+func (o CORSOptions) withDefaults() CORSOptions {
+	if len(o.AllowedMethods) == 0 {
+		o.AllowedMethods = DefaultCORSMethods
+	}
+	if len(o.AllowedHeaders) == 0 {
+		o.AllowedHeaders = DefaultCORSHeaders
+	}
+	return o
+}
+
+// Validate rejects allowing credentials from any origin, which would let
+// every site call the api as a visitor.
+func (o CORSOptions) Validate() error {
+	if o.AllowCredentials && slices.Contains(o.AllowedOrigins, "*") {
+		return errors.New("origins cannot be * when credentials are allowed")
+	}
+	return nil
+}
+
+func (o CORSOptions) allowsOrigin(origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, allowed := range o.AllowedOrigins {
+		allowed = strings.ToLower(allowed)
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+		scheme, host, found := strings.Cut(allowed, "://*.")
+		if found && strings.HasPrefix(origin, scheme+"://") && strings.HasSuffix(origin, "."+host) {
+			return true
+		}
+	}
+	return false
+}
+
+func (o CORSOptions) allowsHeaders(requested string) bool {
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.TrimSpace(header)
+		if header != "" && !slices.ContainsFunc(o.AllowedHeaders, func(allowed string) bool {
+			return strings.EqualFold(allowed, header)
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+// withCORS adds CORS headers to responses for allowed origins and answers
+// preflight requests itself. Credentials are never allowed for invalid
+// options.
+func withCORS(opts CORSOptions, next http.Handler) http.Handler {
+	if len(opts.AllowedOrigins) == 0 {
+		return next
+	}
+	opts = opts.withDefaults()
+	if err := opts.Validate(); err != nil {
+		logging.LogWithTrace(context.Background(), map[string]interface{}{"error": err.Error()}, "not allowing cors credentials")
+		opts.AllowCredentials = false
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if preflight {
+			w.Header().Add("Vary", "Origin, Access-Control-Request-Method, Access-Control-Request-Headers")
+		} else {
+			w.Header().Add("Vary", "Origin")
+		}
+		if origin == "" || !opts.allowsOrigin(origin) {
+			if preflight {
+				writeErrorResponse(w, r, http.StatusForbidden, "origin not allowed")
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if slices.Contains(opts.AllowedOrigins, "*") {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+		if opts.AllowCredentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+		if !preflight {
+			w.Header().Set("Access-Control-Expose-Headers", corsExposedHeaders)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		method := r.Header.Get("Access-Control-Request-Method")
+		if !slices.Contains(opts.AllowedMethods, method) || !opts.allowsHeaders(r.Header.Get("Access-Control-Request-Headers")) {
+			writeErrorResponse(w, r, http.StatusForbidden, "method or headers not allowed")
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(opts.AllowedMethods, ", "))
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(opts.AllowedHeaders, ", "))
+		if opts.MaxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(opts.MaxAge.Seconds())))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,25 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORSAnyOriginNeverAllowsCredentials(t *testing.T) {
+	opts := CORSOptions{AllowedOrigins: []string{"*"}, AllowCredentials: true}
+	if err := opts.Validate(); err == nil {
+		t.Errorf("Expected * with credentials to be invalid")
+	}
+	handler := withCORS(opts, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest(http.MethodGet, "/v2/todos", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if origin := rec.Header().Get("Access-Control-Allow-Origin"); origin != "*" {
+		t.Errorf("Expected: %s, Got: %s", "*", origin)
+	}
+	if credentials := rec.Header().Get("Access-Control-Allow-Credentials"); credentials != "" {
+		t.Errorf("Expected no credentials, Got: %s", credentials)
+	}
+}
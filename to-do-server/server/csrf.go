@@ -0,0 +1,69 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+)
+
+// The /item form is protected from cross site request forgery with a double
+// submit token: the form pages set a random token in a cookie and in a hidden
+// field, and changes are only made when the two match. Another site can make
+// a browser send the cookie, but cannot read it to fill in the field.
+const (
+	csrfCookie = "csrf_token"
+	csrfField  = "csrf_token"
+	// csrfTokenLength is the length of an encoded 32 byte token
+	csrfTokenLength = 43
+)
+
+// formPage is the data of the todoform.html template.
+type formPage struct {
+	Method    string
+	CSRFToken string
+}
+
+// serveForm renders the item form for method, with a CSRF token.
+func serveForm(assets *assets, method string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page := formPage{Method: method, CSRFToken: csrfToken(w, r)}
+		assets.serveTemplate("templates/todoform.html", page)(w, r)
+	}
+}
+
+// csrfToken returns the token of the request's cookie, setting a new cookie
+// when there is none.
+func csrfToken(w http.ResponseWriter, r *http.Request) string {
+	if cookie, err := r.Cookie(csrfCookie); err == nil && len(cookie.Value) == csrfTokenLength {
+		return cookie.Value
+	}
+	token := make([]byte, 32)
+	rand.Read(token)
+	value := base64.RawURLEncoding.EncodeToString(token)
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    value,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return value
+}
+
+// validCSRF checks the submitted token matches the cookie, and that a
+// request sent with an Origin header came from this server.
+func validCSRF(r *http.Request) bool {
+	if origin := r.Header.Get("Origin"); origin != "" {
+		if u, err := url.Parse(origin); err != nil || u.Host != r.Host {
+			return false
+		}
+	}
+	cookie, err := r.Cookie(csrfCookie)
+	if err != nil || len(cookie.Value) != csrfTokenLength {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(r.PostFormValue(csrfField))) == 1
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-to-do-app/to-do-lib/events"
+)
+
+const eventsHeartbeatInterval = 15 * time.Second
+
+func eventsHTTPHandler(broker *events.Broker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+		streamEvents(broker, w, r)
+	}
+}
+
+// streamEvents writes the changes to a user's items as Server-Sent Events. A
+// reconnecting client resumes after the id sent in its Last-Event-ID header.
+func streamEvents(broker *events.Broker, w http.ResponseWriter, r *http.Request) {
+	userId := r.URL.Query().Get("user_id")
+	if userId == "" {
+		writeErrorResponse(w, r, http.StatusBadRequest, "missing 'user_id' query paramater")
+		return
+	}
+	if !actsAs(w, r, userId) {
+		return
+	}
+	var lastId uint64
+	if header := r.Header.Get("Last-Event-ID"); header != "" {
+		id, err := strconv.ParseUint(header, 10, 64)
+		if err != nil {
+			writeErrorResponse(w, r, http.StatusBadRequest, "invalid Last-Event-ID header")
+			return
+		}
+		lastId = id
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeErrorResponse(w, r, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+
+	stream, cancel := broker.Subscribe(userId, lastId)
+	defer cancel()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(eventsHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		case e, open := <-stream:
+			if !open {
+				return
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.Id, e.Type, data)
+		}
+		flusher.Flush()
+	}
+}
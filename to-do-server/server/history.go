@@ -0,0 +1,87 @@
+package server
+
+import (
+	"net/http"
+
+	"go-to-do-app/to-do-lib/datastores"
+	"go-to-do-app/to-do-lib/models"
+)
+
+func historyHTTPHandler(datastore datastores.DataStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+		getHistory(datastore, w, r)
+	}
+}
+
+// getHistory lists every recorded change to an item, oldest first. The
+// history outlives the item so it can still be read after a delete.
+func getHistory(datastore datastores.DataStore, w http.ResponseWriter, r *http.Request) {
+	userId, id, ok := sharedItemQuery(datastore, w, r, models.RoleViewer)
+	if !ok {
+		return
+	}
+	entries, err := datastore.ListHistory(userId, id)
+	if err != nil {
+		handleDataStoreError(w, r, err)
+		return
+	}
+	if len(entries) == 0 {
+		writeErrorResponse(w, r, http.StatusNotFound, "No history for ToDo")
+		return
+	}
+	writeJSON(w, r, http.StatusOK, entries)
+}
+
+// transitionsHTTPHandler lists the moves of an item between statuses, oldest
+// first, read from its history.
+func transitionsHTTPHandler(datastore datastores.DataStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+		userId, id, ok := sharedItemQuery(datastore, w, r, models.RoleViewer)
+		if !ok {
+			return
+		}
+		entries, err := datastore.ListHistory(userId, id)
+		if err != nil {
+			handleDataStoreError(w, r, err)
+			return
+		}
+		if len(entries) == 0 {
+			writeErrorResponse(w, r, http.StatusNotFound, "No history for ToDo")
+			return
+		}
+		writeJSON(w, r, http.StatusOK, models.StatusChanges(entries))
+	}
+}
+
+// assignmentsHTTPHandler lists the reassignments of an item, oldest first,
+// read from its history.
+func assignmentsHTTPHandler(datastore datastores.DataStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+		userId, id, ok := sharedItemQuery(datastore, w, r, models.RoleViewer)
+		if !ok {
+			return
+		}
+		entries, err := datastore.ListHistory(userId, id)
+		if err != nil {
+			handleDataStoreError(w, r, err)
+			return
+		}
+		if len(entries) == 0 {
+			writeErrorResponse(w, r, http.StatusNotFound, "No history for ToDo")
+			return
+		}
+		writeJSON(w, r, http.StatusOK, models.AssignmentChanges(entries))
+	}
+}
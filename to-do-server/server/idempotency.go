@@ -0,0 +1,109 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"go-to-do-app/to-do-lib/datastores"
+	todoerrors "go-to-do-app/to-do-lib/errors"
+	"go-to-do-app/to-do-lib/logging"
+)
+
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// recordingWriter passes a response through while keeping a copy of the status
+// & body so it can be stored against an idempotency key.
+type recordingWriter struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (w *recordingWriter) WriteHeader(statusCode int) {
+	w.statusCode = statusCode
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// scopedKey keeps the Idempotency-Keys of users apart, a user being both the
+// actor of a request and the user_id it is made for, so one user's key can
+// neither replay nor block the requests of another.
+func scopedKey(r *http.Request, body []byte, key string) string {
+	var target struct {
+		UserId string `json:"user_id"`
+	}
+	json.Unmarshal(body, &target)
+	scoped, _ := json.Marshal([]string{logging.GetActor(r.Context()), target.UserId, key})
+	return string(scoped)
+}
+
+// idempotent replays the stored response of a POST carrying an
+// Idempotency-Key header that has been seen within window, instead of calling
+// next again. Server errors are not stored so the client can retry them.
+func idempotent(store datastores.IdempotencyStore, window time.Duration, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if r.Method != http.MethodPost || key == "" {
+			next(w, r)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			writeBodyError(w, r, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		hash := requestHash(r, body)
+		key = scopedKey(r, body, key)
+
+		rec, reserved, err := store.ReserveIdempotencyKey(key, hash, time.Now().Add(-window))
+		if err != nil {
+			handleDataStoreError(w, r, err)
+			return
+		}
+		if !reserved {
+			switch {
+			case rec.RequestHash != hash:
+				writeErrorResponse(w, r, http.StatusUnprocessableEntity, "Idempotency-Key has already been used with a different request")
+			case rec.StatusCode == 0:
+				writeErrorResponse(w, r, http.StatusConflict, "a request with this Idempotency-Key is still being processed")
+			default:
+				w.Header().Set("Idempotent-Replayed", "true")
+				contentType := "application/json"
+				if rec.StatusCode >= http.StatusBadRequest {
+					contentType = todoerrors.ProblemContentType
+				}
+				writeResponse(w, r, rec.StatusCode, contentType, rec.Body)
+			}
+			return
+		}
+
+		rw := &recordingWriter{ResponseWriter: w}
+		next(rw, r)
+		if rw.statusCode >= http.StatusInternalServerError || rw.statusCode == 0 {
+			store.ReleaseIdempotencyKey(key)
+			return
+		}
+		store.CompleteIdempotencyKey(key, rw.statusCode, rw.body.Bytes())
+	}
+}
@@ -0,0 +1,28 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"go-to-do-app/to-do-lib/datastores"
+)
+
+func TestIdempotencyKeysPerUser(t *testing.T) {
+	datastore := datastores.NewInMemDataStore()
+	srv := testServer(t, datastore)
+
+	alice := `{"user_id": "alice", "title": "test", "priority": "Low"}`
+	bob := `{"user_id": "bob", "title": "test", "priority": "Low"}`
+	if resp, _ := send(t, nil, http.MethodPost, srv.URL+"/v2/todo", "alice", alice, idempotencyKeyHeader, "key"); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected: %d, Got: %d", http.StatusCreated, resp.StatusCode)
+	}
+	if resp, _ := send(t, nil, http.MethodPost, srv.URL+"/v2/todo", "alice", alice, idempotencyKeyHeader, "key"); resp.Header.Get("Idempotent-Replayed") != "true" {
+		t.Errorf("Expected a retry by alice to be replayed")
+	}
+	if resp, _ := send(t, nil, http.MethodPost, srv.URL+"/v2/todo", "bob", bob, idempotencyKeyHeader, "key"); resp.StatusCode != http.StatusCreated || resp.Header.Get("Idempotent-Replayed") != "" {
+		t.Errorf("Expected the key of alice not to apply to bob, Got: %d", resp.StatusCode)
+	}
+	if items, _ := datastore.ListItems("bob", datastores.OrderByTitle); len(items) != 1 {
+		t.Errorf("Expected 1 item for bob, Got: %+v", items)
+	}
+}
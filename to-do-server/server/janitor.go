@@ -0,0 +1,47 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"go-to-do-app/to-do-lib/logging"
+)
+
+const janitorInterval = time.Hour
+
+// janitor periodically removes expired data from the datastore until stop is
+// closed.
+func (s *ToDoServer) janitor(stop chan bool) {
+	ticker := time.NewTicker(janitorInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.purgeExpired()
+		}
+	}
+}
+
+func (s *ToDoServer) purgeExpired() {
+	ctx := logging.AddTraceID(context.Background())
+	if err := s.datastore.PurgeIdempotencyKeys(time.Now().Add(-s.opts.IdempotencyWindow)); err != nil {
+		logging.LogWithTrace(ctx, map[string]interface{}{"error": err.Error()}, "failed to purge idempotency keys")
+	}
+	purged, attachments, err := s.datastore.PurgeTrash(time.Now().Add(-s.opts.TrashRetention))
+	if err != nil {
+		logging.LogWithTrace(ctx, map[string]interface{}{"error": err.Error()}, "failed to purge trash")
+	} else if purged > 0 {
+		logging.LogWithTrace(ctx, map[string]interface{}{"purged": purged}, "purged trash")
+	}
+	// the contents of purged attachments go with them
+	if s.opts.Blobs == nil {
+		return
+	}
+	for _, id := range attachments {
+		if err := s.opts.Blobs.Delete(ctx, id.String()); err != nil {
+			logging.LogWithTrace(ctx, map[string]interface{}{"attachment_id": id.String(), "error": err.Error()}, "failed to delete purged attachment")
+		}
+	}
+}
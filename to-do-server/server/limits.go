@@ -0,0 +1,92 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-to-do-app/to-do-lib/logging"
+	"go-to-do-app/to-do-lib/ratelimit"
+)
+
+const DefaultMaxBodySize = 1 << 20
+
+// limits throttles clients by IP address and by user, and caps the size of
+// request bodies. Every request counts against the bucket of its address.
+// Users are only identified by their client certificates for their own
+// buckets, as anyone may send any X-User-Id header or user_id to get a fresh
+// bucket.
+type limits struct {
+	ip          *ratelimit.Limiter
+	user        *ratelimit.Limiter
+	maxBodySize int64
+	// bodySizes overrides maxBodySize for routes that accept uploads
+	bodySizes map[string]int64
+}
+
+func newLimits(opts Options) *limits {
+	return &limits{
+		ip:          ratelimit.NewLimiter(opts.IPRateLimit),
+		user:        ratelimit.NewLimiter(opts.UserRateLimit),
+		maxBodySize: opts.MaxBodySize,
+		bodySizes: map[string]int64{
+			"/v2/todo/attachments": opts.MaxAttachmentSize + multipartOverhead,
+		},
+	}
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func (l *limits) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ok, wait := l.ip.Allow(clientIP(r)); !ok {
+			tooManyRequests(w, r, wait, "ip")
+			return
+		}
+		if user := certifiedUser(r.Context()); user != "" {
+			if ok, wait := l.user.Allow(user); !ok {
+				tooManyRequests(w, r, wait, "user")
+				return
+			}
+		}
+
+		max := l.maxBodySize
+		if size, exists := l.bodySizes[r.URL.Path]; exists {
+			max = size
+		}
+		if r.ContentLength > max {
+			writeErrorResponse(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body is limited to %d bytes", max))
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, max)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func tooManyRequests(w http.ResponseWriter, r *http.Request, wait time.Duration, limitedBy string) {
+	retryAfter := int(math.Ceil(wait.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	logging.LogWithTrace(r.Context(), map[string]interface{}{"limitedBy": limitedBy, "retryAfter": retryAfter}, "request rate limited")
+	writeErrorResponse(w, r, http.StatusTooManyRequests, fmt.Sprintf("too many requests, retry after %d seconds", retryAfter))
+}
+
+// writeBodyError reports a body that could not be read or decoded, which is
+// a 413 when the body was cut off by the size limit.
+func writeBodyError(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeErrorResponse(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body is limited to %d bytes", tooLarge.Limit))
+		return
+	}
+	writeErrorResponse(w, r, http.StatusBadRequest, err.Error())
+}
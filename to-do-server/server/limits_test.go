@@ -0,0 +1,53 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-to-do-app/to-do-lib/ratelimit"
+)
+
+func TestLimitsKeyUsersOnCertificates(t *testing.T) {
+	l := newLimits(Options{
+		IPRateLimit:   ratelimit.Limit{Rate: 0.001, Burst: 3},
+		UserRateLimit: ratelimit.Limit{Rate: 0.001, Burst: 1},
+		MaxBodySize:   DefaultMaxBodySize,
+	})
+	handler := l.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	request := func(ip string, header string, certified string) int {
+		req := httptest.NewRequest(http.MethodGet, "/v2/todos?user_id="+header, nil)
+		req.RemoteAddr = ip + ":1234"
+		req.Header.Set(actorHeader, header)
+		if certified != "" {
+			req = req.WithContext(context.WithValue(req.Context(), certifiedUserKey, certified))
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	tests := []struct {
+		ip        string
+		header    string
+		certified string
+		status    int
+	}{
+		// a new X-User-Id or user_id does not get a new bucket
+		{"10.0.0.1", "a", "", http.StatusOK},
+		{"10.0.0.1", "b", "", http.StatusOK},
+		{"10.0.0.1", "c", "", http.StatusOK},
+		{"10.0.0.1", "d", "", http.StatusTooManyRequests},
+		// a certified user has a bucket of their own wherever they connect from
+		{"10.0.0.2", "", "alice", http.StatusOK},
+		{"10.0.0.3", "", "alice", http.StatusTooManyRequests},
+		{"10.0.0.3", "", "bob", http.StatusOK},
+		// and still counts against their address
+		{"10.0.0.1", "", "carol", http.StatusTooManyRequests},
+	}
+	for i, test := range tests {
+		if status := request(test.ip, test.header, test.certified); status != test.status {
+			t.Errorf("request %d from %s as %q/%q: Expected: %d, Got: %d", i, test.ip, test.header, test.certified, test.status, status)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"net/http"
+
+	"go-to-do-app/to-do-lib/datastores"
+	"go-to-do-app/to-do-lib/models"
+)
+
+func listHTTPHandler(datastore datastores.DataStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+		userId := r.URL.Query().Get("user_id")
+		if userId == "" {
+			writeErrorResponse(w, r, http.StatusBadRequest, "missing 'user_id' query paramater")
+			return
+		}
+		order, err := datastores.ParseItemOrder(r.URL.Query().Get("sort"))
+		if err != nil {
+			handleDataStoreError(w, r, err)
+			return
+		}
+		// another user's list may be read when they have shared it
+		ownerId := userId
+		if owner := r.URL.Query().Get("owner"); owner != "" {
+			ownerId = owner
+		}
+		if !listAccess(datastore, w, r, ownerId) {
+			return
+		}
+		items, err := datastore.ListItems(ownerId, order)
+		if err != nil {
+			handleDataStoreError(w, r, err)
+			return
+		}
+		writeJSON(w, r, http.StatusOK, items)
+	}
+}
+
+// assignedHTTPHandler lists the items assigned to the user, whoever owns
+// them.
+func assignedHTTPHandler(datastore datastores.DataStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+		userId := r.URL.Query().Get("user_id")
+		if userId == "" {
+			writeErrorResponse(w, r, http.StatusBadRequest, "missing 'user_id' query paramater")
+			return
+		}
+		order, err := datastores.ParseItemOrder(r.URL.Query().Get("sort"))
+		if err != nil {
+			handleDataStoreError(w, r, err)
+			return
+		}
+		if !actsAs(w, r, userId) {
+			return
+		}
+		items, err := datastore.ListAssigned(userId, order)
+		if err != nil {
+			handleDataStoreError(w, r, err)
+			return
+		}
+		writeJSON(w, r, http.StatusOK, items)
+	}
+}
+
+// prioritiesHTTPHandler lists the priority levels of the deployment, least
+// pressing first.
+func prioritiesHTTPHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+		writeJSON(w, r, http.StatusOK, models.PriorityLevels())
+	}
+}
+
+// workflowHTTPHandler describes the statuses of the deployment and the moves
+// allowed between them.
+func workflowHTTPHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+		writeJSON(w, r, http.StatusOK, models.CurrentWorkflow())
+	}
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go-to-do-app/to-do-lib/datastores"
+	todoerrors "go-to-do-app/to-do-lib/errors"
+	"go-to-do-app/to-do-lib/models"
+)
+
+// quickAddRequest is a line of text to add as an item, see
+// models.ParseQuickAdd. Relative due dates are read in TimeZone, an IANA
+// name such as Europe/London, defaulting to UTC.
+type quickAddRequest struct {
+	UserId   string `json:"user_id"`
+	Text     string `json:"text"`
+	TimeZone string `json:"time_zone,omitempty"`
+}
+
+func quickAddHTTPHandler(datastore datastores.DataStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, r, http.MethodPost)
+			return
+		}
+		postQuickAdd(datastores.WithHistory(r.Context(), datastore), w, r)
+	}
+}
+
+func postQuickAdd(datastore datastores.DataStore, w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req quickAddRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+	location, err := time.LoadLocation(req.TimeZone)
+	if err != nil {
+		handleDataStoreError(w, r, &todoerrors.ValidationError{Field: "time_zone", Err: fmt.Errorf("unknown time zone %q", req.TimeZone)})
+		return
+	}
+	if req.UserId == "" {
+		handleDataStoreError(w, r, &todoerrors.ValidationError{Field: "user_id", Err: errors.New("invalid user_id")})
+		return
+	}
+	if !actsAs(w, r, req.UserId) {
+		return
+	}
+	item, err := models.ParseQuickAdd(req.Text, time.Now().In(location))
+	if err != nil {
+		handleDataStoreError(w, r, err)
+		return
+	}
+	item.UserId = req.UserId
+	if err := item.Validate(models.V2); err != nil {
+		handleDataStoreError(w, r, err)
+		return
+	}
+	MarshalAndWrite(w, r, datastore.AddItem(item), http.StatusCreated)
+}
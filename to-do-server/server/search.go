@@ -0,0 +1,31 @@
+package server
+
+import (
+	"net/http"
+
+	"go-to-do-app/to-do-lib/datastores"
+)
+
+func searchHTTPHandler(datastore datastores.DataStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+		userId := r.URL.Query().Get("user_id")
+		query := r.URL.Query().Get("q")
+		if userId == "" || query == "" {
+			writeErrorResponse(w, r, http.StatusBadRequest, "missing 'user_id' or 'q' query paramater")
+			return
+		}
+		if !actsAs(w, r, userId) {
+			return
+		}
+		items, err := datastore.Search(userId, query)
+		if err != nil {
+			handleDataStoreError(w, r, err)
+			return
+		}
+		writeJSON(w, r, http.StatusOK, items)
+	}
+}
@@ -4,30 +4,143 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"html/template"
+	"io/fs"
 	"net/http"
 	"strings"
 	"time"
 
 	"go-to-do-app/to-do-lib/apiclient"
+	"go-to-do-app/to-do-lib/blobs"
 	"go-to-do-app/to-do-lib/datastores"
 	todoerrors "go-to-do-app/to-do-lib/errors"
+	"go-to-do-app/to-do-lib/events"
 	"go-to-do-app/to-do-lib/logging"
 	"go-to-do-app/to-do-lib/models"
-
-	"github.com/google/uuid"
+	"go-to-do-app/to-do-lib/notify"
+	"go-to-do-app/to-do-lib/ratelimit"
+	"go-to-do-app/to-do-lib/webhooks"
 )
 
 type ToDoServer struct {
 	server       *http.Server
 	shutdownChan chan bool
+	datastore    datastores.DataStore
+	webhooks     *webhooks.Dispatcher
+	// notifier is nil when notifications are disabled
+	notifier *notify.Notifier
+	limits   *limits
+	// redirect serves the http to https redirect, when configured
+	redirect *http.Server
+	opts     Options
+}
+
+// Options holds the optional behaviour of a ToDoServer. The zero value is
+// valid and falls back to the defaults below.
+type Options struct {
+	// IdempotencyWindow is how long a response is replayed for a repeated
+	// Idempotency-Key.
+	IdempotencyWindow time.Duration
+	// TrashRetention is how long a deleted item stays in the trash before
+	// it is purged.
+	TrashRetention time.Duration
+	// Broker streams datastore changes to /v2/events. When nil a new
+	// in-process broker is used.
+	Broker *events.Broker
+	// Publisher receives the changes made through this server. It defaults
+	// to Broker, set it when changes reach Broker some other way, such as
+	// postgres NOTIFY.
+	Publisher events.Publisher
+	Webhooks  webhooks.Options
+	// Admins are the users who may list and retry webhook deliveries under
+	// /v2/admin. Those routes are not served when it is empty.
+	Admins []string
+	// Notifications emails users the items assigned to them and reminds them
+	// of items falling due. They are disabled when its Sender is nil.
+	Notifications notify.Options
+	// Blobs holds the contents of attachments. Attachments are disabled
+	// when it is nil.
+	Blobs blobs.BlobStore
+	// MaxAttachmentSize is the largest attachment accepted, in bytes.
+	MaxAttachmentSize int64
+	// AttachmentTypes are the content types accepted for attachments.
+	AttachmentTypes []string
+	// IPRateLimit & UserRateLimit throttle each client address and each
+	// user. A zero Rate disables the limit.
+	IPRateLimit   ratelimit.Limit
+	UserRateLimit ratelimit.Limit
+	// MaxBodySize caps request bodies, in bytes, apart from attachment
+	// uploads which are capped by MaxAttachmentSize.
+	MaxBodySize int64
+	// CORS lets browser apps on other origins call the api.
+	CORS CORSOptions
+	// Assets holds the templates, styles.css & api specs, under templates/
+	// and api-specs/. It defaults to the working directory.
+	Assets fs.FS
+	// ReloadAssets parses templates again whenever they change, for
+	// developing them.
+	ReloadAssets bool
+	// TLS serves https, and optionally mutual TLS, when its CertFile is set.
+	TLS TLSOptions
 }
 
-func NewToDoServer(address string, shutdownChannel chan bool, datastore datastores.DataStore) ToDoServer {
-	return ToDoServer{
-		server:       &http.Server{Addr: address, Handler: wiredMux(datastore)},
+const (
+	DefaultIdempotencyWindow = 24 * time.Hour
+	DefaultTrashRetention    = 30 * 24 * time.Hour
+)
+
+func (o Options) withDefaults() Options {
+	if o.IdempotencyWindow <= 0 {
+		o.IdempotencyWindow = DefaultIdempotencyWindow
+	}
+	if o.TrashRetention <= 0 {
+		o.TrashRetention = DefaultTrashRetention
+	}
+	if o.Broker == nil {
+		o.Broker = events.NewBroker(events.DefaultHistorySize)
+	}
+	if o.Publisher == nil {
+		o.Publisher = o.Broker
+	}
+	if o.MaxAttachmentSize <= 0 {
+		o.MaxAttachmentSize = DefaultMaxAttachmentSize
+	}
+	if len(o.AttachmentTypes) == 0 {
+		o.AttachmentTypes = DefaultAttachmentTypes
+	}
+	if o.MaxBodySize <= 0 {
+		o.MaxBodySize = DefaultMaxBodySize
+	}
+	if o.Assets == nil {
+		o.Assets = defaultAssets()
+	}
+	return o
+}
+
+func NewToDoServer(address string, shutdownChannel chan bool, datastore datastores.DataStore, opts Options) ToDoServer {
+	opts = opts.withDefaults()
+	dispatcher := webhooks.NewDispatcher(datastore, opts.Webhooks)
+	publishers := events.Publishers{opts.Publisher, dispatcher}
+	var notifier *notify.Notifier
+	if opts.Notifications.Sender != nil {
+		notifier = notify.NewNotifier(datastore, opts.Notifications)
+		publishers = append(publishers, notifier)
+	}
+	datastore = datastores.WithEvents(datastore, publishers)
+	limits := newLimits(opts)
+	mux := selfServingMux(datastore, dispatcher, newAssets(opts.Assets, opts.ReloadAssets), opts)
+	srv := ToDoServer{
+		server:       &http.Server{Addr: address, Handler: withRequestContext(withCORS(opts.CORS, withClientIdentity(opts.TLS.ClientUsers, limits.middleware(mux))))},
 		shutdownChan: shutdownChannel,
+		datastore:    datastore,
+		webhooks:     dispatcher,
+		notifier:     notifier,
+		limits:       limits,
+		opts:         opts,
+	}
+	if opts.TLS.enabled() && opts.TLS.RedirectAddress != "" {
+		srv.redirect = &http.Server{Addr: opts.TLS.RedirectAddress, Handler: redirectToHTTPS(address)}
 	}
+	return srv
 }
 
 func (s *ToDoServer) Shutdown() {
@@ -38,20 +151,67 @@ func (s *ToDoServer) AwaitShutdown() {
 	<-s.shutdownChan
 }
 
-func wiredMux(datastore datastores.DataStore) *http.ServeMux {
+// SetRateLimits changes the per IP and per user rate limits of a running
+// server.
+func (s *ToDoServer) SetRateLimits(ip, user ratelimit.Limit) {
+	s.limits.ip.SetLimit(ip)
+	s.limits.user.SetLimit(user)
+}
+
+// selfServingMux is wiredMux with the /item form calling the api in-process,
+// as the visitor submitting it, so it needs no address or client certificate
+// of its own.
+func selfServingMux(datastore datastores.DataStore, dispatcher *webhooks.Dispatcher, assets *assets, opts Options) *http.ServeMux {
+	var mux *http.ServeMux
+	client := apiclient.NewHandlerAPIClient(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.ServeHTTP(w, r)
+	}))
+	mux = wiredMux(datastore, dispatcher, assets, client, opts)
+	return mux
+}
+
+func wiredMux(datastore datastores.DataStore, dispatcher *webhooks.Dispatcher, assets *assets, client apiclient.APIClient, opts Options) *http.ServeMux {
 	routes := map[string]http.HandlerFunc{
-		"/":                serveTemplate("./templates/home.html", nil),
-		"/styles.css":      serveFile("./templates/styles.css"),
-		"/v1/swagger.yaml": serveFile("./api-specs/to-do-app-api-v1.yaml"),
-		"/v2/swagger.yaml": serveFile("./api-specs/to-do-app-api-v2.yaml"),
-		"/v1/swagger-ui":   serveTemplate("./templates/swagger-ui-template.html", "v1"),
-		"/v2/swagger-ui":   serveTemplate("./templates/swagger-ui-template.html", "v2"),
-		"/v1/todo":         toDoHTTPHandler(datastore),
-		"/v2/todo":         toDoHTTPHandler(datastore),
-		"/search":          serveTemplate("./templates/todoform.html", "GET"),
-		"/update":          serveTemplate("./templates/todoform.html", "PUT"),
-		"/add":             serveTemplate("./templates/todoform.html", "POST"),
-		"/item":            handleWebForm,
+		"/{$}":                 assets.serveTemplate("templates/home.html", nil),
+		"/":                    notFound,
+		"/styles.css":          assets.serveFile("templates/styles.css"),
+		"/v1/swagger.yaml":     assets.serveFile("api-specs/to-do-app-api-v1.yaml"),
+		"/v2/swagger.yaml":     assets.serveFile("api-specs/to-do-app-api-v2.yaml"),
+		"/v3/swagger.yaml":     assets.serveFile("api-specs/to-do-app-api-v3.yaml"),
+		"/v1/swagger-ui":       assets.serveTemplate("templates/swagger-ui-template.html", "v1"),
+		"/v2/swagger-ui":       assets.serveTemplate("templates/swagger-ui-template.html", "v2"),
+		"/v3/swagger-ui":       assets.serveTemplate("templates/swagger-ui-template.html", "v3"),
+		"/v1/todo":             idempotent(datastore, opts.IdempotencyWindow, toDoHTTPHandler(datastore)),
+		"/v2/todo":             idempotent(datastore, opts.IdempotencyWindow, toDoHTTPHandler(datastore)),
+		"/v2/todo:quickadd":    idempotent(datastore, opts.IdempotencyWindow, quickAddHTTPHandler(datastore)),
+		"/v2/todo/history":     historyHTTPHandler(datastore),
+		"/v2/todo/undo":        undoHTTPHandler(datastore),
+		"/v2/todo/attachments": attachmentsHTTPHandler(datastore, opts),
+		"/v2/todo/assignments": assignmentsHTTPHandler(datastore),
+		"/v2/trash":            trashHTTPHandler(datastore),
+		"/v2/trash/restore":    restoreHTTPHandler(datastore),
+		"/v2/todos":            listHTTPHandler(datastore),
+		"/v2/todos:batch":      batchHTTPHandler(datastore),
+		"/v2/todos/search":     searchHTTPHandler(datastore),
+		"/v2/shares":           sharesHTTPHandler(datastore),
+		"/v2/shared":           sharedHTTPHandler(datastore),
+		"/v2/assigned":         assignedHTTPHandler(datastore),
+		"/v2/priorities":       prioritiesHTTPHandler(),
+		"/v3/todo":             idempotent(datastore, opts.IdempotencyWindow, toDoHTTPHandler(datastore)),
+		"/v3/todo/transitions": transitionsHTTPHandler(datastore),
+		"/v3/todos":            listHTTPHandler(datastore),
+		"/v3/workflow":         workflowHTTPHandler(),
+		"/v2/events":           eventsHTTPHandler(opts.Broker),
+		"/v2/webhooks":         webhooksHTTPHandler(datastore, opts.Webhooks),
+		"/search":              serveForm(assets, "GET"),
+		"/update":              serveForm(assets, "PUT"),
+		"/add":                 serveForm(assets, "POST"),
+		"/item":                webFormHTTPHandler(assets, client),
+	}
+
+	if len(opts.Admins) > 0 {
+		routes["/v2/admin/deliveries"] = adminOnly(opts.Admins, deliveriesHTTPHandler(dispatcher))
+		routes["/v2/admin/redeliver"] = adminOnly(opts.Admins, redeliverHTTPHandler(dispatcher))
 	}
 
 	mux := http.NewServeMux()
@@ -61,15 +221,61 @@ func wiredMux(datastore datastores.DataStore) *http.ServeMux {
 	return mux
 }
 
+// actorHeader names the user making a request, for attributing changes in
+// the item history
+const actorHeader = "X-User-Id"
+
+// withRequestContext gives each request a trace id, returned in the
+// X-Trace-Id header, and records the actor making it.
+func withRequestContext(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := logging.AddTraceID(r.Context())
+		if actor := r.Header.Get(actorHeader); actor != "" {
+			ctx = logging.AddActor(ctx, actor)
+		}
+		w.Header().Set("X-Trace-Id", logging.GetTraceID(ctx))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 func (s *ToDoServer) Start() {
 	go func() {
-		if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if s.opts.TLS.enabled() {
+			if s.server.TLSConfig, err = s.opts.TLS.config(); err == nil {
+				err = s.server.ListenAndServeTLS(s.opts.TLS.CertFile, s.opts.TLS.KeyFile)
+			}
+		} else {
+			err = s.server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			fmt.Printf("ListenAndServe error: %v\n", err)
 		}
 	}()
+	if s.redirect != nil {
+		go func() {
+			if err := s.redirect.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				fmt.Printf("Redirect ListenAndServe error: %v\n", err)
+			}
+		}()
+	}
+	stopJanitor := make(chan bool)
+	go s.janitor(stopJanitor)
+	s.webhooks.Start()
+	if s.notifier != nil {
+		s.notifier.Start()
+	}
 	<-s.shutdownChan
+	close(stopJanitor)
+	s.webhooks.Stop()
+	if s.notifier != nil {
+		s.notifier.Stop()
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
 	defer cancel()
+	if s.redirect != nil {
+		s.redirect.Shutdown(ctx)
+	}
 	if err := s.server.Shutdown(ctx); err != nil {
 		fmt.Printf("Server Shutdown error: %v\n", err)
 	} else {
@@ -78,60 +284,77 @@ func (s *ToDoServer) Start() {
 	s.shutdownChan <- true
 }
 
-func serveFile(filePath string) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, filePath)
-	}
-}
-
 func toDoHTTPHandler(datastore datastores.DataStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		toDoHandler(datastore, w, r)
 	}
 }
 
-func serveTemplate(path string, data interface{}) http.HandlerFunc {
+func webFormHTTPHandler(assets *assets, client apiclient.APIClient) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		tmpl, err := template.ParseFiles(path)
-		if err != nil {
-			http.Error(w, "Error parsing template", http.StatusInternalServerError)
-			return
-		}
-		err = tmpl.Execute(w, data)
-		if err != nil {
-			http.Error(w, "Error rendering template", http.StatusInternalServerError)
-		}
+		handleWebForm(assets, client, w, r)
 	}
 }
 
-func handleWebForm(w http.ResponseWriter, r *http.Request) {
+func handleWebForm(assets *assets, client apiclient.APIClient, w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
 		http.Error(w, "Error parsing form data", http.StatusBadRequest)
 		return
 	}
 	method := r.FormValue("form_method")
+	// anything other than a read changes items, so must be a POST carrying
+	// the CSRF token of the form
+	if method != http.MethodGet && method != "SEARCH" {
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, r, http.MethodPost)
+			return
+		}
+		if !validCSRF(r) {
+			writeErrorResponse(w, r, http.StatusForbidden, "missing or invalid CSRF token, reload the form and try again")
+			return
+		}
+	}
 	args := map[string]string{
-		"user-id":  r.FormValue("user_id"),
-		"id":       r.FormValue("id"),
-		"version":  r.FormValue("api_version"),
-		"title":    r.FormValue("title"),
-		"priority": r.FormValue("priority"),
-		"complete": r.FormValue("complete"),
+		"user-id":     r.FormValue("user_id"),
+		"id":          r.FormValue("id"),
+		"version":     r.FormValue("api_version"),
+		"title":       r.FormValue("title"),
+		"description": r.FormValue("description"),
+		"priority":    r.FormValue("priority"),
+		"complete":    r.FormValue("complete"),
+		"query":       r.FormValue("q"),
 	}
 	// var itemIn models.ToDo
 	ctx := logging.AddTraceID(r.Context())
-	client := apiclient.NewAPIClient("http://localhost:8081/")
+	// visitors without a client certificate name themselves with the form,
+	// as api clients do with the X-User-Id header
+	if logging.GetActor(ctx) == "" && args["user-id"] != "" {
+		ctx = logging.AddActor(ctx, args["user-id"])
+	}
+	if method == "SEARCH" {
+		if items, err := client.Search(ctx, args); err != nil {
+			writeErrorResponse(w, r, http.StatusBadRequest, err.Error())
+		} else {
+			temp := assets.serveTemplate("templates/todolist.html", items)
+			temp(w, r)
+		}
+		return
+	}
 	if item, err := client.Req(ctx, method, args); err != nil {
 		writeErrorResponse(w, r, http.StatusBadRequest, err.Error())
 	} else {
-		temp := serveTemplate("./templates/todoitem.html", item)
+		temp := assets.serveTemplate("templates/todoitem.html", item)
 		temp(w, r)
 	}
 }
 
 func WriteJSONResponse(w http.ResponseWriter, r *http.Request, statusCode int, data []byte) {
+	writeResponse(w, r, statusCode, "application/json", data)
+}
+
+func writeResponse(w http.ResponseWriter, r *http.Request, statusCode int, contentType string, data []byte) {
 	ctx := logging.AddTraceID(r.Context())
-	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Type", contentType)
 	w.WriteHeader(statusCode)
 	w.Write(data)
 	logData := map[string]interface{}{
@@ -141,35 +364,77 @@ func WriteJSONResponse(w http.ResponseWriter, r *http.Request, statusCode int, d
 	logging.LogWithTrace(ctx, logData, "Json response Written")
 }
 
+// writeProblem writes an RFC 7807 problem+json error, tagged with the trace id
+// of the request so it can be matched with the server logs.
+func writeProblem(w http.ResponseWriter, r *http.Request, problem *todoerrors.Problem) {
+	problem.TraceId = logging.GetTraceID(r.Context())
+	data, err := json.Marshal(problem)
+	if err != nil {
+		data = []byte(`{"type":"about:blank","title":"Internal Server Error","status":500}`)
+	}
+	writeResponse(w, r, problem.Status, todoerrors.ProblemContentType, data)
+}
+
 func writeErrorResponse(w http.ResponseWriter, r *http.Request, statusCode int, message string) {
-	WriteJSONResponse(w, r, statusCode, []byte(fmt.Sprintf(`{"error": "%s"}`, message)))
+	writeProblem(w, r, todoerrors.NewProblem(statusCode, message))
+}
+
+// methodNotAllowed answers a request whose method the route does not handle,
+// listing the methods it does in the Allow header.
+func methodNotAllowed(w http.ResponseWriter, r *http.Request, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeErrorResponse(w, r, http.StatusMethodNotAllowed, fmt.Sprintf("%s is not allowed, use %s", r.Method, strings.Join(allowed, " or ")))
+}
+
+func errorStatus(err error) (int, string) {
+	problem := todoerrors.ProblemFor(err)
+	return problem.Status, problem.Detail
+}
+
+func notFound(w http.ResponseWriter, r *http.Request) {
+	writeErrorResponse(w, r, http.StatusNotFound, fmt.Sprintf("no route for %s", r.URL.Path))
 }
 
 func handleDataStoreError(w http.ResponseWriter, r *http.Request, err error) {
-	switch e := err.(type) {
-	case *todoerrors.NotFoundError:
-		writeErrorResponse(w, r, http.StatusNotFound, e.Message)
-	case *todoerrors.ValidationError:
-		writeErrorResponse(w, r, http.StatusBadRequest, e.Error())
-	default:
-		writeErrorResponse(w, r, http.StatusInternalServerError, "Internal server error")
-	}
+	writeProblem(w, r, todoerrors.ProblemFor(err))
 }
 
 func putToDo(datastore datastores.DataStore, w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	defer r.Body.Close()
-	var item models.ToDo
-	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
-		writeErrorResponse(w, r, http.StatusBadRequest, err.Error())
+	var ref models.ToDoRef
+	if err := json.NewDecoder(r.Body).Decode(&ref); err != nil {
+		writeBodyError(w, r, err)
 		return
 	}
+	item := ref.ToDo
 	pathparts := strings.Split(r.URL.Path, "/")
 	err := item.Validate(pathparts[1])
 	if err != nil {
-		writeErrorResponse(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid body: %s", err.Error()))
+		handleDataStoreError(w, r, err)
 		return
 	}
+	var ok bool
+	if item.Id, ok = resolveId(datastore, w, r, item.UserId, ref.Id); !ok {
+		return
+	}
+	// editors of a shared item update it as its owner
+	ownerId, ok := authorize(datastore, w, r, item.Id, models.RoleEditor)
+	if !ok {
+		return
+	}
+	if ownerId != callerOf(r) {
+		current, err := datastore.GetItem(ownerId, item.Id)
+		if err != nil {
+			handleDataStoreError(w, r, err)
+			return
+		}
+		if current.Assignee != item.Assignee {
+			handleDataStoreError(w, r, errAssigneeOwnerOnly)
+			return
+		}
+	}
+	item.UserId = ownerId
 	item, err = datastore.UpdateItem(item)
 	if err != nil {
 		handleDataStoreError(w, r, err)
@@ -183,13 +448,16 @@ func postToDo(datastore datastores.DataStore, w http.ResponseWriter, r *http.Req
 	defer r.Body.Close()
 	var item models.ToDo
 	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
-		writeErrorResponse(w, r, http.StatusBadRequest, err.Error())
+		writeBodyError(w, r, err)
 		return
 	}
 	pathparts := strings.Split(r.URL.Path, "/")
 	err := item.Validate(pathparts[1])
 	if err != nil {
-		writeErrorResponse(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid body: %s", err.Error()))
+		handleDataStoreError(w, r, err)
+		return
+	}
+	if !actsAs(w, r, item.UserId) {
 		return
 	}
 	item = datastore.AddItem(item)
@@ -197,16 +465,12 @@ func postToDo(datastore datastores.DataStore, w http.ResponseWriter, r *http.Req
 }
 
 func getToDo(datastore datastores.DataStore, w http.ResponseWriter, r *http.Request) {
-	id := r.URL.Query().Get("id")
-	userId := r.URL.Query().Get("user_id")
-	ver := strings.Split(r.URL.Path, "/")[1]
-	uuid, err := uuid.Parse(id)
-	if id == "" || (userId == "" && ver == "v2") || err != nil {
-		writeErrorResponse(w, r, http.StatusBadRequest, "missing 'id' query paramater")
+	userId, id, ok := sharedItemQuery(datastore, w, r, models.RoleViewer)
+	if !ok {
 		return
 	}
-	var item models.ToDo
-	if item, err = datastore.GetItem(userId, uuid); err != nil {
+	item, err := datastore.GetItem(userId, id)
+	if err != nil {
 		handleDataStoreError(w, r, err)
 		return
 	}
@@ -214,7 +478,11 @@ func getToDo(datastore datastores.DataStore, w http.ResponseWriter, r *http.Requ
 }
 
 func MarshalAndWrite(w http.ResponseWriter, r *http.Request, item models.ToDo, statusCode int) {
-	resp, err := json.Marshal(item)
+	writeJSON(w, r, statusCode, item)
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, statusCode int, data interface{}) {
+	resp, err := json.Marshal(data)
 	if err != nil {
 		writeErrorResponse(w, r, http.StatusInternalServerError, "Internal Server Error")
 		return
@@ -223,6 +491,7 @@ func MarshalAndWrite(w http.ResponseWriter, r *http.Request, item models.ToDo, s
 }
 
 func toDoHandler(datastore datastores.DataStore, w http.ResponseWriter, r *http.Request) {
+	datastore = datastores.WithHistory(r.Context(), datastore)
 	switch r.Method {
 	case http.MethodGet:
 		getToDo(datastore, w, r)
@@ -230,5 +499,12 @@ func toDoHandler(datastore datastores.DataStore, w http.ResponseWriter, r *http.
 		postToDo(datastore, w, r)
 	case http.MethodPut:
 		putToDo(datastore, w, r)
+	case http.MethodDelete:
+		deleteToDo(datastore, w, r)
+	case http.MethodOptions:
+		w.Header().Set("Allow", strings.Join([]string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodOptions}, ", "))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, r, http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodOptions)
 	}
 }
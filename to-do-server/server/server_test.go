@@ -0,0 +1,171 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"go-to-do-app/to-do-lib/datastores"
+	"go-to-do-app/to-do-lib/models"
+	"go-to-do-app/to-do-lib/webhooks"
+)
+
+// testServer serves the routes of a server over datastore.
+func testServer(t *testing.T, datastore datastores.DataStore) *httptest.Server {
+	t.Helper()
+	return testServerWith(t, datastore, Options{})
+}
+
+// testServerWith is testServer with opts.
+func testServerWith(t *testing.T, datastore datastores.DataStore, opts Options) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(testHandler(datastore, opts))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// testTLSServer is testServerWith over mutual TLS, verifying client
+// certificates signed by cas as clientAuth says.
+func testTLSServer(t *testing.T, datastore datastores.DataStore, opts Options, clientAuth tls.ClientAuthType, cas ...*x509.Certificate) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(testHandler(datastore, opts))
+	srv.TLS = &tls.Config{ClientAuth: clientAuth, ClientCAs: x509.NewCertPool()}
+	for _, ca := range cas {
+		srv.TLS.ClientCAs.AddCert(ca)
+	}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func testHandler(datastore datastores.DataStore, opts Options) http.Handler {
+	opts.Assets = os.DirFS("..")
+	opts = opts.withDefaults()
+	dispatcher := webhooks.NewDispatcher(datastore, opts.Webhooks)
+	mux := selfServingMux(datastore, dispatcher, newAssets(opts.Assets, false), opts)
+	return withRequestContext(withClientIdentity(nil, mux))
+}
+
+// tlsClient is a new client of srv presenting certs, with connections of its
+// own so they are not shared with clients presenting others.
+func tlsClient(srv *httptest.Server, certs ...tls.Certificate) *http.Client {
+	transport := srv.Client().Transport.(*http.Transport).Clone()
+	transport.TLSClientConfig.Certificates = certs
+	return &http.Client{Transport: transport}
+}
+
+// send sends method to url as actor, with body, through client, or
+// http.DefaultClient when nil. header holds pairs of names & values of other
+// headers. The response is returned with its body read.
+func send(t *testing.T, client *http.Client, method string, url string, actor string, body string, header ...string) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actor != "" {
+		req.Header.Set(actorHeader, actor)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, data
+}
+
+func TestWebFormDeleteNeedsCSRF(t *testing.T) {
+	datastore := datastores.NewInMemDataStore()
+	item := datastore.AddItem(models.ToDo{UserId: "TestToDoUser", Title: "test", Priority: "Low"})
+	srv := testServer(t, datastore)
+	form := url.Values{"form_method": {http.MethodDelete}, "api_version": {"v2"}, "user_id": {item.UserId}, "id": {item.Id.String()}}
+
+	resp, _ := send(t, nil, http.MethodGet, srv.URL+"/item?"+form.Encode(), "", "")
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("Expected: %d, Got: %d", http.StatusMethodNotAllowed, resp.StatusCode)
+	}
+	resp, _ = send(t, nil, http.MethodPost, srv.URL+"/item", "", form.Encode(), "Content-Type", "application/x-www-form-urlencoded")
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected: %d, Got: %d", http.StatusForbidden, resp.StatusCode)
+	}
+	if _, err := datastore.GetItem(item.UserId, item.Id); err != nil {
+		t.Fatalf("Expected item not to be deleted without a CSRF token, Got: %v", err)
+	}
+
+	token := strings.Repeat("a", csrfTokenLength)
+	form.Set(csrfField, token)
+	resp, _ = send(t, nil, http.MethodPost, srv.URL+"/item", "", form.Encode(), "Content-Type", "application/x-www-form-urlencoded", "Cookie", csrfCookie+"="+token)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected: %d, Got: %d", http.StatusOK, resp.StatusCode)
+	}
+	if _, err := datastore.GetItem(item.UserId, item.Id); err == nil {
+		t.Errorf("Expected item to be deleted with a CSRF token")
+	}
+}
+
+// clientCertificate is a self-signed certificate for clients of commonName.
+func clientCertificate(t *testing.T, commonName string) (tls.Certificate, *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, cert
+}
+
+func TestWebFormWithRequiredClientCert(t *testing.T) {
+	datastore := datastores.NewInMemDataStore()
+	clientCert, ca := clientCertificate(t, "alice")
+	srv := testTLSServer(t, datastore, Options{}, tls.RequireAndVerifyClientCert, ca)
+	client := tlsClient(srv, clientCert)
+
+	token := strings.Repeat("a", csrfTokenLength)
+	form := url.Values{"form_method": {http.MethodPost}, "api_version": {"v2"}, "user_id": {"alice"}, "title": {"test"}, "priority": {"Low"}, csrfField: {token}}
+	resp, _ := send(t, client, http.MethodPost, srv.URL+"/item", "", form.Encode(), "Content-Type", "application/x-www-form-urlencoded", "Cookie", csrfCookie+"="+token)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected: %d, Got: %d", http.StatusOK, resp.StatusCode)
+	}
+	if items, _ := datastore.ListItems("alice", datastores.OrderByTitle); len(items) != 1 {
+		t.Errorf("Expected the form to add an item, Got: %+v", items)
+	}
+}
@@ -0,0 +1,249 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go-to-do-app/to-do-lib/datastores"
+	todoerrors "go-to-do-app/to-do-lib/errors"
+	"go-to-do-app/to-do-lib/logging"
+	"go-to-do-app/to-do-lib/models"
+
+	"github.com/google/uuid"
+)
+
+// errAssigneeOwnerOnly rejects collaborators and assignees changing who an
+// item is assigned to, which would give another user access to it.
+var errAssigneeOwnerOnly = &todoerrors.ForbiddenError{Message: "only the owner of a ToDo may change its assignee"}
+var errDeleteOwnerOnly = &todoerrors.ForbiddenError{Message: "only the owner of a ToDo may delete it"}
+
+// callerOf returns the user making a request, named by the X-User-Id header
+// or a client certificate. v1 has no users, so its callers are anonymous.
+func callerOf(r *http.Request) string {
+	if strings.Split(r.URL.Path, "/")[1] == models.V1 {
+		return ""
+	}
+	return logging.GetActor(r.Context())
+}
+
+// caller is callerOf, rejecting requests that do not name their user.
+func caller(w http.ResponseWriter, r *http.Request) (string, bool) {
+	userId := callerOf(r)
+	if userId == "" && strings.Split(r.URL.Path, "/")[1] != models.V1 {
+		handleDataStoreError(w, r, &todoerrors.UnauthorizedError{Message: fmt.Sprintf("send the %s header or a client certificate naming you", actorHeader)})
+		return "", false
+	}
+	return userId, true
+}
+
+// checkAccess checks the user may act on an item with the required role,
+// returning the owner the item is stored under. Users without any role on the
+// item are told it is not found, and those with too low a role are forbidden.
+func checkAccess(datastore datastores.ShareStore, userId string, id uuid.UUID, required models.Role) (string, error) {
+	access, err := datastore.Access(userId, id)
+	if err != nil {
+		return "", err
+	}
+	if !access.Role.Allows(required) {
+		return "", &todoerrors.ForbiddenError{
+			Message: fmt.Sprintf("ToDo is shared with you as %s, this needs the %s role", access.Role, required),
+		}
+	}
+	return access.OwnerId, nil
+}
+
+// authorize checks the caller may act on an item with the required role,
+// returning the owner the item is stored under.
+func authorize(datastore datastores.ShareStore, w http.ResponseWriter, r *http.Request, id uuid.UUID, required models.Role) (string, bool) {
+	userId, ok := caller(w, r)
+	if !ok {
+		return "", false
+	}
+	ownerId, err := checkAccess(datastore, userId, id, required)
+	if err != nil {
+		handleDataStoreError(w, r, err)
+		return "", false
+	}
+	return ownerId, true
+}
+
+// sharedItemQuery is itemQuery for an item the caller may own or have been
+// shared, returning its owner. The user_id names the list a short id is
+// looked up in, and grants nothing by itself.
+func sharedItemQuery(datastore datastores.DataStore, w http.ResponseWriter, r *http.Request, required models.Role) (string, uuid.UUID, bool) {
+	_, id, ok := itemQuery(datastore, w, r)
+	if !ok {
+		return "", uuid.Nil, false
+	}
+	ownerId, ok := authorize(datastore, w, r, id, required)
+	return ownerId, id, ok
+}
+
+// listAccess checks the caller may read the owner's list, their own or one
+// shared with them.
+func listAccess(datastore datastores.ShareStore, w http.ResponseWriter, r *http.Request, ownerId string) bool {
+	userId, ok := caller(w, r)
+	if !ok {
+		return false
+	}
+	if ownerId == userId {
+		return true
+	}
+	shares, err := datastore.ListShares(ownerId, uuid.Nil)
+	if err != nil {
+		handleDataStoreError(w, r, err)
+		return false
+	}
+	for _, share := range shares {
+		if share.Collaborator == userId {
+			return true
+		}
+	}
+	handleDataStoreError(w, r, &todoerrors.ForbiddenError{Message: fmt.Sprintf("the ToDos of %s are not shared with you", ownerId)})
+	return false
+}
+
+// actsAs checks the request is made by userId, for requests on what only
+// the user has, such as their trash, searches and subscriptions.
+func actsAs(w http.ResponseWriter, r *http.Request, userId string) bool {
+	actor, ok := caller(w, r)
+	if !ok {
+		return false
+	}
+	if actor != userId {
+		handleDataStoreError(w, r, &todoerrors.ForbiddenError{Message: fmt.Sprintf("only %s may make this request for themselves", userId)})
+		return false
+	}
+	return true
+}
+
+// actsAsOwner checks the request is made by ownerId, named by the X-User-Id
+// header or a client certificate, as only owners may see and change who their
+// items are shared with.
+func actsAsOwner(w http.ResponseWriter, r *http.Request, ownerId string) bool {
+	if actor := logging.GetActor(r.Context()); actor != ownerId {
+		handleDataStoreError(w, r, &todoerrors.ForbiddenError{Message: fmt.Sprintf("only %s may share their ToDos, send the %s header or a client certificate naming them", ownerId, actorHeader)})
+		return false
+	}
+	return true
+}
+
+func sharesHTTPHandler(datastore datastores.DataStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			listShares(datastore, w, r)
+		case http.MethodPost:
+			postShare(datastore, w, r)
+		case http.MethodDelete:
+			deleteShare(datastore, w, r)
+		default:
+			methodNotAllowed(w, r, http.MethodGet, http.MethodPost, http.MethodDelete)
+		}
+	}
+}
+
+// shareQuery reads the user_id query parameter of the owner, and the id of
+// the item shared when one is given rather than their whole list.
+func shareQuery(datastore datastores.DataStore, w http.ResponseWriter, r *http.Request) (string, uuid.UUID, bool) {
+	userId := r.URL.Query().Get("user_id")
+	if userId == "" {
+		writeErrorResponse(w, r, http.StatusBadRequest, "missing 'user_id' query paramater")
+		return "", uuid.Nil, false
+	}
+	if !actsAsOwner(w, r, userId) {
+		return "", uuid.Nil, false
+	}
+	if r.URL.Query().Get("id") == "" {
+		return userId, uuid.Nil, true
+	}
+	return sharedItemQuery(datastore, w, r, models.RoleOwner)
+}
+
+// listShares lists who an item, or the user's whole list, is shared with.
+func listShares(datastore datastores.DataStore, w http.ResponseWriter, r *http.Request) {
+	ownerId, id, ok := shareQuery(datastore, w, r)
+	if !ok {
+		return
+	}
+	shares, err := datastore.ListShares(ownerId, id)
+	if err != nil {
+		handleDataStoreError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, shares)
+}
+
+// postShare shares one of the owner's items, or their whole list when no
+// item_id is given, replacing the role of a collaborator it is already
+// shared with. Only owners may share their items, see actsAsOwner.
+func postShare(datastore datastores.DataStore, w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var share models.Share
+	if err := json.NewDecoder(r.Body).Decode(&share); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+	if err := share.Validate(); err != nil {
+		handleDataStoreError(w, r, err)
+		return
+	}
+	if !actsAsOwner(w, r, share.OwnerId) {
+		return
+	}
+	if share.ItemId != nil {
+		if _, ok := authorize(datastore, w, r, *share.ItemId, models.RoleOwner); !ok {
+			return
+		}
+	}
+	share, err := datastore.ShareItem(share)
+	if err != nil {
+		handleDataStoreError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusCreated, share)
+}
+
+func deleteShare(datastore datastores.DataStore, w http.ResponseWriter, r *http.Request) {
+	collaborator := r.URL.Query().Get("collaborator")
+	if collaborator == "" {
+		writeErrorResponse(w, r, http.StatusBadRequest, "missing 'collaborator' query paramater")
+		return
+	}
+	ownerId, id, ok := shareQuery(datastore, w, r)
+	if !ok {
+		return
+	}
+	if err := datastore.Unshare(ownerId, id, collaborator); err != nil {
+		handleDataStoreError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sharedHTTPHandler lists the items other users have shared with the user,
+// with their role on each.
+func sharedHTTPHandler(datastore datastores.DataStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+		userId := r.URL.Query().Get("user_id")
+		if userId == "" {
+			writeErrorResponse(w, r, http.StatusBadRequest, "missing 'user_id' query paramater")
+			return
+		}
+		if !actsAs(w, r, userId) {
+			return
+		}
+		items, err := datastore.ListShared(userId)
+		if err != nil {
+			handleDataStoreError(w, r, err)
+			return
+		}
+		writeJSON(w, r, http.StatusOK, items)
+	}
+}
@@ -0,0 +1,182 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+
+	"go-to-do-app/to-do-lib/datastores"
+	"go-to-do-app/to-do-lib/models"
+
+	"github.com/google/uuid"
+)
+
+func TestPostShareNeedsOwner(t *testing.T) {
+	datastore := datastores.NewInMemDataStore()
+	item := datastore.AddItem(models.ToDo{UserId: "alice", Title: "test", Priority: "Low"})
+	other := datastore.AddItem(models.ToDo{UserId: "bob", Title: "test", Priority: "Low"})
+	srv := testServer(t, datastore)
+
+	list := `{"owner_id": "alice", "collaborator": "mallory", "role": "editor"}`
+	itemShare := `{"owner_id": "alice", "item_id": "` + item.Id.String() + `", "collaborator": "mallory", "role": "editor"}`
+	otherShare := `{"owner_id": "alice", "item_id": "` + other.Id.String() + `", "collaborator": "mallory", "role": "editor"}`
+	tests := []struct {
+		actor  string
+		body   string
+		status int
+	}{
+		{"", list, http.StatusForbidden},
+		{"mallory", list, http.StatusForbidden},
+		{"mallory", itemShare, http.StatusForbidden},
+		{"alice", otherShare, http.StatusNotFound},
+		{"alice", list, http.StatusCreated},
+		{"alice", itemShare, http.StatusCreated},
+	}
+	for _, test := range tests {
+		if resp, _ := send(t, nil, http.MethodPost, srv.URL+"/v2/shares", test.actor, test.body); resp.StatusCode != test.status {
+			t.Errorf("%s sharing %s: Expected: %d, Got: %d", test.actor, test.body, test.status, resp.StatusCode)
+		}
+	}
+	if shares, _ := datastore.ListShares("alice", uuid.Nil); len(shares) != 1 {
+		t.Errorf("Expected only the share made by alice, Got: %+v", shares)
+	}
+
+	if resp, _ := send(t, nil, http.MethodDelete, srv.URL+"/v2/shares?user_id=alice&collaborator=mallory", "mallory", ""); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected: %d, Got: %d", http.StatusForbidden, resp.StatusCode)
+	}
+}
+
+func TestOnlyOwnerReassigns(t *testing.T) {
+	datastore := datastores.NewInMemDataStore()
+	item := datastore.AddItem(models.ToDo{UserId: "alice", Title: "test", Priority: "Low", Assignee: "bob"})
+	datastore.ShareItem(models.Share{OwnerId: "alice", Collaborator: "carol", Role: models.RoleEditor})
+	srv := testServer(t, datastore)
+
+	tests := []struct {
+		userId   string
+		assignee string
+		status   int
+	}{
+		{"bob", "mallory", http.StatusForbidden},
+		{"carol", "mallory", http.StatusForbidden},
+		{"carol", "", http.StatusForbidden},
+		{"bob", "bob", http.StatusOK},
+		{"carol", "bob", http.StatusOK},
+		{"alice", "carol", http.StatusOK},
+	}
+	for _, test := range tests {
+		body := `{"user_id": "` + test.userId + `", "id": "` + item.Id.String() + `", "title": "renamed", "priority": "Low", "complete": false, "assignee": "` + test.assignee + `"}`
+		if resp, _ := send(t, nil, http.MethodPut, srv.URL+"/v2/todo", test.userId, body); resp.StatusCode != test.status {
+			t.Errorf("%s assigning %q: Expected: %d, Got: %d", test.userId, test.assignee, test.status, resp.StatusCode)
+		}
+	}
+
+	// undoing the owner's reassignment is reassigning too
+	if resp, _ := send(t, nil, http.MethodPost, srv.URL+"/v2/todo/undo?user_id=carol&id="+item.Id.String(), "carol", ""); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected: %d, Got: %d", http.StatusForbidden, resp.StatusCode)
+	}
+}
+
+func TestOnlyOwnerUndoesCreate(t *testing.T) {
+	datastore := datastores.NewInMemDataStore()
+	item := datastores.WithHistory(context.Background(), datastore).AddItem(models.ToDo{UserId: "alice", Title: "test", Priority: "Low"})
+	datastore.ShareItem(models.Share{OwnerId: "alice", Collaborator: "carol", Role: models.RoleEditor})
+	srv := testServer(t, datastore)
+
+	undo := srv.URL + "/v2/todo/undo?user_id=alice&id=" + item.Id.String()
+	// undoing the create deletes the item, which only its owner may
+	if resp, _ := send(t, nil, http.MethodPost, undo, "carol", ""); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected: %d, Got: %d", http.StatusForbidden, resp.StatusCode)
+	}
+	if _, err := datastore.GetItem("alice", item.Id); err != nil {
+		t.Errorf("Expected: %+v, Got: %+v", nil, err)
+	}
+	if resp, _ := send(t, nil, http.MethodPost, undo, "alice", ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected: %d, Got: %d", http.StatusOK, resp.StatusCode)
+	}
+}
+
+func TestItemAccessFollowsCaller(t *testing.T) {
+	datastore := datastores.NewInMemDataStore()
+	item := datastore.AddItem(models.ToDo{UserId: "alice", Title: "test", Priority: "Low"})
+	datastore.ShareItem(models.Share{OwnerId: "alice", Collaborator: "victor", Role: models.RoleViewer})
+	srv := testServer(t, datastore)
+
+	query := "?user_id=alice&id=" + item.Id.String()
+	update := `{"user_id": "alice", "id": "` + item.Id.String() + `", "title": "renamed", "priority": "Low", "complete": false}`
+	tests := []struct {
+		method string
+		path   string
+		actor  string
+		body   string
+		status int
+	}{
+		// naming the owner with user_id grants nothing
+		{http.MethodGet, "/v2/todo" + query, "", "", http.StatusUnauthorized},
+		{http.MethodGet, "/v2/todo" + query, "mallory", "", http.StatusNotFound},
+		{http.MethodPut, "/v2/todo", "mallory", update, http.StatusNotFound},
+		{http.MethodDelete, "/v2/todo" + query, "mallory", "", http.StatusNotFound},
+		{http.MethodGet, "/v2/todo/history" + query, "mallory", "", http.StatusNotFound},
+		{http.MethodGet, "/v2/trash?user_id=alice", "mallory", "", http.StatusForbidden},
+		{http.MethodGet, "/v2/todos?user_id=alice", "mallory", "", http.StatusForbidden},
+		{http.MethodPost, "/v2/todo", "mallory", `{"user_id": "alice", "title": "test", "priority": "Low"}`, http.StatusForbidden},
+		{http.MethodGet, "/v2/todo" + query, "victor", "", http.StatusOK},
+		{http.MethodPut, "/v2/todo", "victor", update, http.StatusForbidden},
+		{http.MethodGet, "/v2/todo" + query, "alice", "", http.StatusOK},
+	}
+	for _, test := range tests {
+		if resp, _ := send(t, nil, test.method, srv.URL+test.path, test.actor, test.body); resp.StatusCode != test.status {
+			t.Errorf("%s %s as %q: Expected: %d, Got: %d", test.method, test.path, test.actor, test.status, resp.StatusCode)
+		}
+	}
+
+	batch := `{"operations": [
+		{"op": "create", "item": {"user_id": "alice", "title": "test", "priority": "Low"}},
+		{"op": "update", "item": ` + update + `},
+		{"op": "delete", "item": {"user_id": "alice", "id": "` + item.Id.String() + `"}}
+	]}`
+	_, data := send(t, nil, http.MethodPost, srv.URL+"/v2/todos:batch", "victor", batch)
+	var results batchResponse
+	json.Unmarshal(data, &results)
+	statuses := []int{}
+	for _, res := range results.Results {
+		statuses = append(statuses, res.Status)
+	}
+	if expected := []int{http.StatusForbidden, http.StatusForbidden, http.StatusForbidden}; !slices.Equal(statuses, expected) {
+		t.Errorf("Expected: %v, Got: %v", expected, statuses)
+	}
+	if current, _ := datastore.GetItem("alice", item.Id); current.Title != "test" || current.Deleted() {
+		t.Errorf("Expected the batch of a viewer to change nothing, Got: %+v", current)
+	}
+}
+
+func TestShortIdsResolveInReadableLists(t *testing.T) {
+	datastore := datastores.NewInMemDataStore()
+	item := datastore.AddItem(models.ToDo{UserId: "alice", Title: "test", Priority: "Low"})
+	datastore.ShareItem(models.Share{OwnerId: "alice", Collaborator: "carol", Role: models.RoleViewer})
+	datastore.ShareItem(models.Share{OwnerId: "alice", ItemId: &item.Id, Collaborator: "victor", Role: models.RoleViewer})
+	srv := testServer(t, datastore)
+
+	tests := []struct {
+		actor  string
+		ref    string
+		status int
+	}{
+		{"alice", item.ShortId(), http.StatusOK},
+		// the whole list is shared with carol, so she may look up its short ids
+		{"carol", item.ShortId(), http.StatusOK},
+		// others learn nothing of the ids in the list, whatever the prefix
+		{"mallory", item.ShortId(), http.StatusForbidden},
+		{"mallory", "ffff", http.StatusForbidden},
+		// an item shared on its own is named by its full id
+		{"victor", item.ShortId(), http.StatusForbidden},
+		{"victor", item.Id.String(), http.StatusOK},
+	}
+	for _, test := range tests {
+		if resp, _ := send(t, nil, http.MethodGet, srv.URL+"/v2/todo?user_id=alice&id="+test.ref, test.actor, ""); resp.StatusCode != test.status {
+			t.Errorf("%s getting %s: Expected: %d, Got: %d", test.actor, test.ref, test.status, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"go-to-do-app/to-do-lib/logging"
+)
+
+// TLSOptions configures https for a ToDoServer. TLS is disabled when CertFile
+// is empty. HTTP/2 is negotiated automatically over TLS.
+type TLSOptions struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile enables mutual TLS, verifying any client certificate
+	// against the CAs in this PEM file.
+	ClientCAFile string
+	// RequireClientCert rejects clients without a verified certificate,
+	// otherwise a certificate is optional.
+	RequireClientCert bool
+	// ClientUsers maps the common name of a verified client certificate to
+	// the user the client acts as. Names not in the map act as themselves.
+	ClientUsers map[string]string
+	// RedirectAddress, when set, is an http address redirecting every
+	// request to https.
+	RedirectAddress string
+}
+
+func (o TLSOptions) enabled() bool {
+	return o.CertFile != ""
+}
+
+func (o TLSOptions) config() (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if o.ClientCAFile == "" {
+		return config, nil
+	}
+	pem, err := os.ReadFile(o.ClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	config.ClientCAs = x509.NewCertPool()
+	if !config.ClientCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", o.ClientCAFile)
+	}
+	config.ClientAuth = tls.VerifyClientCertIfGiven
+	if o.RequireClientCert {
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+type contextKey string
+
+const certifiedUserKey = contextKey("certifiedUser")
+
+// withClientIdentity makes the user a verified client certificate is mapped
+// to the actor of the request, taking precedence over the X-User-Id header.
+func withClientIdentity(users map[string]string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+			name := r.TLS.VerifiedChains[0][0].Subject.CommonName
+			user, mapped := users[name]
+			if !mapped {
+				user = name
+			}
+			if user != "" {
+				ctx := logging.AddActor(r.Context(), user)
+				r = r.WithContext(context.WithValue(ctx, certifiedUserKey, user))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// certifiedUser returns the user named by the verified client certificate of
+// a request, or "" when it has none, whatever its X-User-Id header says.
+func certifiedUser(ctx context.Context) string {
+	user, _ := ctx.Value(certifiedUserKey).(string)
+	return user
+}
+
+// redirectToHTTPS sends http clients to the same path on the https address.
+func redirectToHTTPS(httpsAddress string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddress)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		// 308 keeps the method & body of the request
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
+func readCertificate(path string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("no certificate found in %s", path)
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// SelfSignedCertValidity is how long a generated development certificate
+// lasts.
+const SelfSignedCertValidity = 365 * 24 * time.Hour
+
+// EnsureSelfSignedCert writes a self-signed certificate for hosts to certFile
+// & keyFile, for local development. An existing certificate is kept until it
+// is about to expire. Clients can trust certFile as their CA.
+func EnsureSelfSignedCert(certFile, keyFile string, hosts []string) error {
+	if cert, err := readCertificate(certFile); err == nil && time.Until(cert.NotAfter) > 24*time.Hour {
+		if _, err := os.Stat(keyFile); err == nil {
+			return nil
+		}
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "to-do-server development", Organization: []string{"go-to-do-app"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(SelfSignedCertValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if err := writePEM(keyFile, "PRIVATE KEY", keyDER, 0o600); err != nil {
+		return err
+	}
+	return writePEM(certFile, "CERTIFICATE", der, 0o644)
+}
+
+func writePEM(path, blockType string, der []byte, perm os.FileMode) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if err := pem.Encode(file, &pem.Block{Type: blockType, Bytes: der}); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"go-to-do-app/to-do-lib/datastores"
+	"go-to-do-app/to-do-lib/events"
+	"go-to-do-app/to-do-lib/models"
+
+	"github.com/google/uuid"
+)
+
+// itemQuery reads the user_id & id query parameters that identify an item,
+// the id being the item's id or a short id of it. user_id is required by
+// every api after v1. The error response is written when they do not name an item.
+func itemQuery(datastore datastores.DataStore, w http.ResponseWriter, r *http.Request) (string, uuid.UUID, bool) {
+	userId := r.URL.Query().Get("user_id")
+	ver := strings.Split(r.URL.Path, "/")[1]
+	ref := r.URL.Query().Get("id")
+	if ref == "" || (userId == "" && ver != "v1") {
+		writeErrorResponse(w, r, http.StatusBadRequest, "missing 'user_id' or 'id' query paramater")
+		return "", uuid.Nil, false
+	}
+	id, ok := resolveId(datastore, w, r, userId, ref)
+	return userId, id, ok
+}
+
+// resolveId returns the id of the item ref names in the list of userId. A
+// short id is only looked up once the caller is known to be able to read
+// that list, so the ids of others' items cannot be guessed at by prefix.
+func resolveId(datastore datastores.DataStore, w http.ResponseWriter, r *http.Request, userId string, ref string) (uuid.UUID, bool) {
+	if id, err := uuid.Parse(ref); err == nil {
+		return id, true
+	}
+	if !listAccess(datastore, w, r, userId) {
+		return uuid.Nil, false
+	}
+	id, err := datastore.ResolveId(userId, ref)
+	if err != nil {
+		handleDataStoreError(w, r, err)
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func deleteToDo(datastore datastores.DataStore, w http.ResponseWriter, r *http.Request) {
+	userId, id, ok := sharedItemQuery(datastore, w, r, models.RoleOwner)
+	if !ok {
+		return
+	}
+	item, err := datastore.DeleteItem(userId, id)
+	if err != nil {
+		handleDataStoreError(w, r, err)
+		return
+	}
+	MarshalAndWrite(w, r, item, http.StatusOK)
+}
+
+func trashHTTPHandler(datastore datastores.DataStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+		userId := r.URL.Query().Get("user_id")
+		if userId == "" {
+			writeErrorResponse(w, r, http.StatusBadRequest, "missing 'user_id' query paramater")
+			return
+		}
+		if !actsAs(w, r, userId) {
+			return
+		}
+		items, err := datastore.ListTrash(userId)
+		if err != nil {
+			handleDataStoreError(w, r, err)
+			return
+		}
+		writeJSON(w, r, http.StatusOK, items)
+	}
+}
+
+func restoreHTTPHandler(datastore datastores.DataStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, r, http.MethodPost)
+			return
+		}
+		// only owners see their trash, to restore from it
+		userId, id, ok := itemQuery(datastore, w, r)
+		if !ok || !actsAs(w, r, userId) {
+			return
+		}
+		item, err := datastores.WithHistory(r.Context(), datastore).RestoreItem(userId, id)
+		if err != nil {
+			handleDataStoreError(w, r, err)
+			return
+		}
+		MarshalAndWrite(w, r, item, http.StatusOK)
+	}
+}
+
+func undoHTTPHandler(datastore datastores.DataStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, r, http.MethodPost)
+			return
+		}
+		ownerId, id, ok := sharedItemQuery(datastore, w, r, models.RoleEditor)
+		if !ok {
+			return
+		}
+		if ownerId != callerOf(r) && !undoAllowed(datastore, w, r, ownerId, id) {
+			return
+		}
+		item, err := datastores.UndoLastChange(datastores.WithHistory(r.Context(), datastore), ownerId, id)
+		if err != nil {
+			handleDataStoreError(w, r, err)
+			return
+		}
+		MarshalAndWrite(w, r, item, http.StatusOK)
+	}
+}
+
+// undoAllowed checks a collaborator's undo of the last change of an item
+// neither deletes it nor changes its assignee, as only its owner may.
+func undoAllowed(datastore datastores.DataStore, w http.ResponseWriter, r *http.Request, ownerId string, id uuid.UUID) bool {
+	entries, err := datastore.ListHistory(ownerId, id)
+	if err != nil {
+		handleDataStoreError(w, r, err)
+		return false
+	}
+	if len(entries) == 0 {
+		return true
+	}
+	last := entries[len(entries)-1]
+	if last.Action == events.Created || last.Action == events.Restored {
+		handleDataStoreError(w, r, errDeleteOwnerOnly)
+		return false
+	}
+	if len(models.AssignmentChanges([]models.HistoryEntry{last})) > 0 {
+		handleDataStoreError(w, r, errAssigneeOwnerOnly)
+		return false
+	}
+	return true
+}
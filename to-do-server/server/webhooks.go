@@ -0,0 +1,176 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+
+	"go-to-do-app/to-do-lib/datastores"
+	todoerrors "go-to-do-app/to-do-lib/errors"
+	"go-to-do-app/to-do-lib/webhooks"
+
+	"github.com/google/uuid"
+)
+
+func webhooksHTTPHandler(datastore datastores.DataStore, opts webhooks.Options) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			listWebhooks(datastore, w, r)
+		case http.MethodPost:
+			postWebhook(datastore, opts, w, r)
+		case http.MethodDelete:
+			deleteWebhook(datastore, w, r)
+		default:
+			methodNotAllowed(w, r, http.MethodGet, http.MethodPost, http.MethodDelete)
+		}
+	}
+}
+
+// validateWebhook checks the url of sub can be sent deliveries. Hosts named
+// by an address are checked here, while names are checked by the dispatcher
+// each time they are resolved.
+func validateWebhook(sub datastores.WebhookSubscription, opts webhooks.Options) error {
+	if sub.UserId == "" {
+		return &todoerrors.ValidationError{Field: "user_id", Err: errors.New("invalid user_id")}
+	}
+	u, err := url.Parse(sub.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return &todoerrors.ValidationError{Field: "url", Err: errors.New("url must be an absolute http or https url")}
+	}
+	if addr, err := netip.ParseAddr(u.Hostname()); err == nil && !opts.Allows(addr) {
+		return &todoerrors.ValidationError{Field: "url", Err: fmt.Errorf("webhooks may not be sent to %s", addr)}
+	}
+	return nil
+}
+
+func newWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// postWebhook creates a subscription. The secret used to sign deliveries is
+// only returned here, a new one is generated when none is supplied.
+func postWebhook(datastore datastores.DataStore, opts webhooks.Options, w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var sub datastores.WebhookSubscription
+	if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+	if err := validateWebhook(sub, opts); err != nil {
+		handleDataStoreError(w, r, err)
+		return
+	}
+	if !actsAs(w, r, sub.UserId) {
+		return
+	}
+	if sub.Secret == "" {
+		secret, err := newWebhookSecret()
+		if err != nil {
+			handleDataStoreError(w, r, err)
+			return
+		}
+		sub.Secret = secret
+	}
+	sub, err := datastore.AddWebhook(sub)
+	if err != nil {
+		handleDataStoreError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusCreated, sub)
+}
+
+func listWebhooks(datastore datastores.DataStore, w http.ResponseWriter, r *http.Request) {
+	userId := r.URL.Query().Get("user_id")
+	if userId == "" {
+		writeErrorResponse(w, r, http.StatusBadRequest, "missing 'user_id' query paramater")
+		return
+	}
+	if !actsAs(w, r, userId) {
+		return
+	}
+	subs, err := datastore.ListWebhooks(userId)
+	if err != nil {
+		handleDataStoreError(w, r, err)
+		return
+	}
+	for i := range subs {
+		subs[i].Secret = ""
+	}
+	writeJSON(w, r, http.StatusOK, subs)
+}
+
+func deleteWebhook(datastore datastores.DataStore, w http.ResponseWriter, r *http.Request) {
+	userId := r.URL.Query().Get("user_id")
+	id, err := uuid.Parse(r.URL.Query().Get("id"))
+	if userId == "" || err != nil {
+		writeErrorResponse(w, r, http.StatusBadRequest, "missing 'user_id' or 'id' query paramater")
+		return
+	}
+	if !actsAs(w, r, userId) {
+		return
+	}
+	if err := datastore.DeleteWebhook(userId, id); err != nil {
+		handleDataStoreError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// adminOnly rejects requests from anyone but admins, who must be named by a
+// verified client certificate as the X-User-Id header is anyone's to send.
+func adminOnly(admins []string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if user := certifiedUser(r.Context()); user == "" || !slices.Contains(admins, user) {
+			handleDataStoreError(w, r, &todoerrors.ForbiddenError{Message: "only admins may manage webhook deliveries, with a client certificate naming them"})
+			return
+		}
+		next(w, r)
+	}
+}
+
+func deliveriesHTTPHandler(dispatcher *webhooks.Dispatcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+		query := r.URL.Query()
+		deliveries, err := dispatcher.Deliveries(query.Get("user_id"), query.Get("status"))
+		if err != nil {
+			handleDataStoreError(w, r, err)
+			return
+		}
+		writeJSON(w, r, http.StatusOK, deliveries)
+	}
+}
+
+func redeliverHTTPHandler(dispatcher *webhooks.Dispatcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, r, http.MethodPost)
+			return
+		}
+		id, err := uuid.Parse(r.URL.Query().Get("id"))
+		if err != nil {
+			writeErrorResponse(w, r, http.StatusBadRequest, "missing 'id' query paramater")
+			return
+		}
+		delivery, err := dispatcher.Redeliver(id)
+		if err != nil {
+			handleDataStoreError(w, r, err)
+			return
+		}
+		writeJSON(w, r, http.StatusAccepted, delivery)
+	}
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/netip"
+	"testing"
+
+	"go-to-do-app/to-do-lib/datastores"
+	"go-to-do-app/to-do-lib/webhooks"
+
+	"github.com/google/uuid"
+)
+
+func TestAdminRoutesNeedAdmin(t *testing.T) {
+	if resp, _ := send(t, nil, http.MethodGet, testServer(t, datastores.NewInMemDataStore()).URL+"/v2/admin/deliveries", "ops", ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected admin routes to be unmounted without admins, Got: %d", resp.StatusCode)
+	}
+
+	opsCert, opsCA := clientCertificate(t, "ops")
+	malloryCert, malloryCA := clientCertificate(t, "mallory")
+	srv := testTLSServer(t, datastores.NewInMemDataStore(), Options{Admins: []string{"ops"}}, tls.VerifyClientCertIfGiven, opsCA, malloryCA)
+	tests := []struct {
+		name   string
+		certs  []tls.Certificate
+		actor  string
+		status int
+	}{
+		{"nobody", nil, "", http.StatusForbidden},
+		// the header alone never names an admin
+		{"header", nil, "ops", http.StatusForbidden},
+		{"mallory", []tls.Certificate{malloryCert}, "ops", http.StatusForbidden},
+		{"ops", []tls.Certificate{opsCert}, "", http.StatusOK},
+	}
+	for _, test := range tests {
+		for _, request := range []struct{ method, path string }{
+			{http.MethodGet, "/v2/admin/deliveries"},
+			{http.MethodPost, "/v2/admin/redeliver?id=" + uuid.NewString()},
+		} {
+			resp, _ := send(t, tlsClient(srv, test.certs...), request.method, srv.URL+request.path, test.actor, "")
+			// a retry by an admin gets as far as the missing delivery
+			status := test.status
+			if status == http.StatusOK && request.method == http.MethodPost {
+				status = http.StatusNotFound
+			}
+			if resp.StatusCode != status {
+				t.Errorf("%s %s %s: Expected: %d, Got: %d", test.name, request.method, request.path, status, resp.StatusCode)
+			}
+		}
+	}
+}
+
+func TestPostWebhookRefusesInternalAddresses(t *testing.T) {
+	srv := testServerWith(t, datastores.NewInMemDataStore(), Options{Webhooks: webhooks.Options{AllowedNetworks: []netip.Prefix{netip.MustParsePrefix("10.1.0.0/16")}}})
+	tests := []struct {
+		url    string
+		status int
+	}{
+		{"http://169.254.169.254/latest/meta-data", http.StatusBadRequest},
+		{"http://127.0.0.1:8081/v2/todos", http.StatusBadRequest},
+		{"http://[::1]/hook", http.StatusBadRequest},
+		{"https://10.0.0.1/hook", http.StatusBadRequest},
+		{"https://10.1.0.1/hook", http.StatusCreated},
+		{"https://example.com/hook", http.StatusCreated},
+	}
+	for _, test := range tests {
+		if resp, _ := send(t, nil, http.MethodPost, srv.URL+"/v2/webhooks", "alice", `{"user_id": "alice", "url": "`+test.url+`"}`); resp.StatusCode != test.status {
+			t.Errorf("%s: Expected: %d, Got: %d", test.url, test.status, resp.StatusCode)
+		}
+	}
+}
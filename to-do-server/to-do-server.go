@@ -5,42 +5,42 @@ import (
 	"database/sql"
 	"flag"
 	"fmt"
+	"go-to-do-app/to-do-lib/blobs"
+	"go-to-do-app/to-do-lib/config"
 	"go-to-do-app/to-do-lib/datastores"
+	"go-to-do-app/to-do-lib/events"
 	"go-to-do-app/to-do-lib/logging"
+	"go-to-do-app/to-do-lib/models"
 	"go-to-do-app/to-do-server/server"
 	"os"
 	"os/signal"
-	"path/filepath"
+	"slices"
 	"syscall"
 
-	_ "github.com/lib/pq"
+	"github.com/joho/godotenv"
+	"github.com/lib/pq"
 )
 
 var (
-	mode         = flag.String("mode", "", "set the mode the application should run in (in-mem, json-store, pgdb)")
-	addr         = flag.String("address", ":8081", "set the address for the server. Default is :8081")
-	jsonPath     = flag.String("json", "", "filepath of json file to use as datastore")
-	password     = flag.String("password", "", "database password")
-	user         = flag.String("user", "postgres", "database username")
+	configPath   = flag.String("config", "", "YAML or TOML config file (env TODO_CONFIG)")
 	create       = flag.Bool("pg-create", false, "Create ToDo database & items table with postgres connection")
 	shutdownChan = make(chan bool)
-	dbname       = "todo"
 )
 
-func createPostgresDB() {
-	connStr := fmt.Sprintf("postgres://%s:%s@localhost/%s?sslmode=disable", *user, *password, "")
+func createPostgresDB(cfg Config) {
+	connStr := fmt.Sprintf("postgres://%s:%s@localhost/%s?sslmode=disable", cfg.Database.User, cfg.Database.Password, "")
 	db, err := sql.Open("postgres", connStr)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 	defer db.Close()
-	_, err = db.Exec("CREATE DATABASE todo")
+	_, err = db.Exec("CREATE DATABASE " + pq.QuoteIdentifier(cfg.Database.Name))
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
-	todoConnStr := fmt.Sprintf("postgres://%s:%s@localhost/%s?sslmode=disable", *user, *password, "todo")
+	todoConnStr := fmt.Sprintf("postgres://%s:%s@localhost/%s?sslmode=disable", cfg.Database.User, cfg.Database.Password, cfg.Database.Name)
 	tododb, err := sql.Open("postgres", todoConnStr)
 	if err != nil {
 		fmt.Println(err)
@@ -48,53 +48,235 @@ func createPostgresDB() {
 	}
 	defer tododb.Close()
 	tododb.Exec("CREATE TABLE IF NOT EXISTS items (user_id TEXT, item_id TEXT, title TEXT, priority TEXT, complete BOOLEAN);")
+	tododb.Exec("ALTER TABLE items ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;")
+	tododb.Exec("CREATE TABLE IF NOT EXISTS idempotency_keys (idem_key TEXT PRIMARY KEY, request_hash TEXT, status_code INTEGER, body BYTEA, created_at TIMESTAMPTZ);")
+	tododb.Exec("CREATE TABLE IF NOT EXISTS webhooks (webhook_id TEXT PRIMARY KEY, user_id TEXT, url TEXT, secret TEXT, event_types TEXT[], priorities TEXT[]);")
+	tododb.Exec("CREATE TABLE IF NOT EXISTS webhook_deliveries (delivery_id TEXT PRIMARY KEY, subscription_id TEXT, user_id TEXT, url TEXT, event JSONB, status TEXT, attempts INT, response_code INT, last_error TEXT, created_at TIMESTAMPTZ, updated_at TIMESTAMPTZ);")
+	tododb.Exec("CREATE TABLE IF NOT EXISTS item_history (seq BIGSERIAL, entry_id TEXT PRIMARY KEY, user_id TEXT, item_id TEXT, action TEXT, before JSONB, after JSONB, actor TEXT, trace_id TEXT, created_at TIMESTAMPTZ);")
+	tododb.Exec("ALTER TABLE items ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '';")
+	tododb.Exec("ALTER TABLE items ADD COLUMN IF NOT EXISTS search TSVECTOR GENERATED ALWAYS AS (to_tsvector('simple', coalesce(title, '') || ' ' || description)) STORED;")
+	tododb.Exec("CREATE INDEX IF NOT EXISTS items_search_idx ON items USING GIN (search);")
+	tododb.Exec("ALTER TABLE items ADD COLUMN IF NOT EXISTS revision BIGINT NOT NULL DEFAULT 1;")
+	tododb.Exec("ALTER TABLE items ADD COLUMN IF NOT EXISTS tags TEXT[];")
+	tododb.Exec("ALTER TABLE items ADD COLUMN IF NOT EXISTS due TIMESTAMPTZ;")
+	tododb.Exec("ALTER TABLE items ADD COLUMN IF NOT EXISTS reminded_due TIMESTAMPTZ;")
+	tododb.Exec("ALTER TABLE items ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT '';")
+	tododb.Exec("CREATE TABLE IF NOT EXISTS attachments (attachment_id TEXT PRIMARY KEY, user_id TEXT, item_id TEXT, filename TEXT, content_type TEXT, size BIGINT, created_at TIMESTAMPTZ);")
+	// a share of a user's whole list has an empty item_id
+	tododb.Exec("CREATE TABLE IF NOT EXISTS shares (owner_id TEXT, item_id TEXT, collaborator TEXT, role TEXT, created_at TIMESTAMPTZ, PRIMARY KEY (owner_id, item_id, collaborator));")
+	tododb.Exec("CREATE INDEX IF NOT EXISTS shares_collaborator_idx ON shares (collaborator);")
+	tododb.Exec("ALTER TABLE items ADD COLUMN IF NOT EXISTS assignee TEXT NOT NULL DEFAULT '';")
+	tododb.Exec("CREATE INDEX IF NOT EXISTS items_assignee_idx ON items (assignee);")
+	tododb.Exec("CREATE TABLE IF NOT EXISTS events (event_id BIGSERIAL PRIMARY KEY, payload JSONB, created_at TIMESTAMPTZ);")
 	os.Exit(0)
 }
 
+func newBlobStore(cfg Config) (blobs.BlobStore, error) {
+	if s3 := cfg.Attachments.S3; s3.Endpoint != "" {
+		return blobs.NewS3BlobStore(blobs.S3Options{
+			Endpoint:  s3.Endpoint,
+			Bucket:    s3.Bucket,
+			Region:    s3.Region,
+			AccessKey: s3.AccessKey,
+			SecretKey: s3.SecretKey,
+		})
+	}
+	return blobs.NewFSBlobStore(cfg.Attachments.Dir)
+}
+
+// devHosts are the names a self-signed development certificate is valid for.
+func devHosts() []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if name, err := os.Hostname(); err == nil && name != "localhost" {
+		hosts = append(hosts, name)
+	}
+	return hosts
+}
+
+// parseConfig defines the flags of every setting, parses args and loads the
+// config they describe.
+func parseConfig(args []string) (Config, *config.Flags) {
+	defaults := defaultConfig()
+	flags, err := config.NewFlags(flag.CommandLine, &defaults)
+	if err != nil {
+		panic(err)
+	}
+	flag.CommandLine.Parse(args)
+	// a .env file is optional, and never overrides the real environment
+	godotenv.Load()
+	if *configPath == "" {
+		*configPath = os.Getenv("TODO_CONFIG")
+	}
+	cfg, err := loadConfig(*configPath, flags)
+	if err != nil {
+		fmt.Println("Error loading config: ", err)
+		os.Exit(1)
+	}
+	return cfg, flags
+}
+
+// printConfig writes the effective config as YAML, with secrets redacted.
+func printConfig(args []string) {
+	cfg, _ := parseConfig(args)
+	values, err := config.Dump(&cfg, true)
+	if err == nil {
+		err = config.WriteYAML(os.Stdout, values)
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, "invalid config:\n"+err.Error())
+		os.Exit(1)
+	}
+}
+
+// reloadConfig applies the settings that are safe to change while running,
+// and logs any others that were changed as needing a restart.
+func reloadConfig(srv *server.ToDoServer, current Config, flags *config.Flags) Config {
+	ctx := context.Background()
+	cfg, err := loadConfig(*configPath, flags)
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		logging.LogWithTrace(ctx, map[string]interface{}{"error": err.Error()}, "config not reloaded")
+		return current
+	}
+	level, _ := logging.ParseLevel(cfg.LogLevel)
+	logging.SetLevel(level)
+	srv.SetRateLimits(cfg.ipRateLimit(), cfg.userRateLimit())
+	levels, _ := models.ParsePriorityLevels(cfg.Priorities)
+	models.SetPriorityLevels(levels)
+	workflow, _ := cfg.workflow()
+	models.SetWorkflow(workflow)
+
+	var restart []string
+	for _, key := range changedSettings(current, cfg) {
+		if !slices.Contains(reloadable, key) {
+			restart = append(restart, key)
+		}
+	}
+	if len(restart) > 0 {
+		logging.LogWithTrace(ctx, map[string]interface{}{"settings": restart}, "changed settings need a restart to apply")
+	}
+	logging.LogWithTrace(ctx, map[string]interface{}{"logLevel": cfg.LogLevel}, "config reloaded")
+	// the running server keeps every other setting until it is restarted
+	current.LogLevel = cfg.LogLevel
+	current.Priorities = cfg.Priorities
+	current.Workflow = cfg.Workflow
+	current.Limits.IPRate, current.Limits.IPBurst = cfg.Limits.IPRate, cfg.Limits.IPBurst
+	current.Limits.UserRate, current.Limits.UserBurst = cfg.Limits.UserRate, cfg.Limits.UserBurst
+	return current
+}
+
 func run() {
-	flag.Parse()
+	if len(os.Args) > 2 && os.Args[1] == "config" && os.Args[2] == "print" {
+		printConfig(os.Args[3:])
+		return
+	}
+	cfg, flags := parseConfig(os.Args[1:])
 
 	var store datastores.DataStore
+	broker := events.NewBroker(events.DefaultHistorySize)
+	var publisher events.Publisher = broker
 	if *create {
-		createPostgresDB()
+		createPostgresDB(cfg)
 	}
-	if *mode == "" {
+	if err := cfg.Validate(); err != nil {
 		logging.LogWithTrace(
 			context.Background(),
-			map[string]interface{}{},
-			"no valid mode provided to start server with datastore",
+			map[string]interface{}{"error": err.Error()},
+			"invalid config, unable to start server",
 		)
 		os.Exit(1)
 	}
-	if *mode == "pgdb" {
-		store, err := datastores.NewPGDatastore(*user, *password, dbname)
+	level, _ := logging.ParseLevel(cfg.LogLevel)
+	logging.SetLevel(level)
+	levels, _ := models.ParsePriorityLevels(cfg.Priorities)
+	models.SetPriorityLevels(levels)
+	workflow, _ := cfg.workflow()
+	models.SetWorkflow(workflow)
+	if cfg.Mode == "pgdb" {
+		pg, err := datastores.NewPGDatastore(cfg.Database.User, cfg.Database.Password, cfg.Database.Name)
 		if err != nil {
 			fmt.Println("Error connecting to postgres: ", err)
 			os.Exit(1)
 		}
-		defer store.Close()
+		defer pg.Close()
+		// changes are shared with other servers on the same database through
+		// the events table, and reach this server's broker through the listener
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			if err := pg.(*datastores.PGDB).ListenEvents(ctx, broker); err != nil {
+				logging.LogWithTrace(ctx, map[string]interface{}{"error": err.Error()}, "unable to listen for postgres events")
+			}
+		}()
+		publisher = pg.(*datastores.PGDB)
+		store = pg
 	}
-	if *mode == "in-mem" {
+	if cfg.Mode == "in-mem" {
 		store = datastores.NewInMemDataStore()
 	}
-	if *mode == "json-store" {
-		if filepath.Ext(*jsonPath) != ".json" {
-			logging.LogWithTrace(
-				context.Background(),
-				map[string]interface{}{"path": *jsonPath},
-				"no valid path to json file provided",
-			)
+	if cfg.Mode == "json-store" {
+		store = datastores.NewJsonDatastore(cfg.JSONPath)
+		defer store.Close()
+	}
+	if cfg.TLS.SelfSigned {
+		if err := server.EnsureSelfSignedCert(cfg.TLS.Cert, cfg.TLS.Key, devHosts()); err != nil {
+			fmt.Println("Error generating self-signed certificate: ", err)
 			os.Exit(1)
 		}
-		store = datastores.NewJsonDatastore(*jsonPath)
-		defer store.Close()
 	}
-	srv := server.NewToDoServer(*addr, shutdownChan, store)
+	clientUsers, _ := cfg.clientUsers()
+	webhookOpts, _ := cfg.webhooks()
+	blobStore, err := newBlobStore(cfg)
+	if err != nil {
+		fmt.Println("Error opening attachment store: ", err)
+		os.Exit(1)
+	}
+	srv := server.NewToDoServer(cfg.Address, shutdownChan, store, server.Options{
+		IdempotencyWindow: cfg.IdempotencyWindow,
+		TrashRetention:    cfg.TrashRetention,
+		Broker:            broker,
+		Publisher:         publisher,
+		Webhooks:          webhookOpts,
+		Admins:            cfg.Admins,
+		Blobs:             blobStore,
+		MaxAttachmentSize: cfg.Attachments.MaxSize,
+		IPRateLimit:       cfg.ipRateLimit(),
+		UserRateLimit:     cfg.userRateLimit(),
+		MaxBodySize:       cfg.Limits.MaxBodySize,
+		CORS:              cfg.corsOptions(),
+		Assets:            serverAssets(cfg),
+		ReloadAssets:      cfg.Dev,
+		TLS: server.TLSOptions{
+			CertFile:          cfg.TLS.Cert,
+			KeyFile:           cfg.TLS.Key,
+			ClientCAFile:      cfg.TLS.ClientCA,
+			RequireClientCert: cfg.TLS.RequireClientCert,
+			ClientUsers:       clientUsers,
+			RedirectAddress:   cfg.TLS.RedirectAddress,
+		},
+		Notifications: cfg.notifications(),
+	})
 	go srv.Start()
-	fmt.Println("server running @", *addr)
+	scheme := "http"
+	if cfg.TLS.Cert != "" {
+		scheme = "https"
+	}
+	fmt.Printf("server running @ %s (%s)\n", cfg.Address, scheme)
 	interruptChannel := make(chan os.Signal, 1)
-	signal.Notify(interruptChannel, os.Interrupt, syscall.SIGTERM)
-	<-interruptChannel
+	signal.Notify(interruptChannel, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range interruptChannel {
+		if sig == syscall.SIGHUP {
+			cfg = reloadConfig(&srv, cfg, flags)
+			continue
+		}
+		break
+	}
 	srv.Shutdown()
 	srv.AwaitShutdown()
 }